@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	arSkipFrames   = 5 // frames skipped at disc start and end
+	arQueryTimeout = 30 * time.Second
+	arURLFormat    = "http://www.accuraterip.com/accuraterip/%x/%x/%x/dBAR-%03d-%08x-%08x-%08x.bin"
+)
+
+// arDisc is AccurateRip disc identification from track offsets in CD
+// frames and lead-out.
+type arDisc struct {
+	tracks   int
+	id1, id2 uint32
+	cddb     uint32
+}
+
+func newARDisc(offset []int64, leadOut int64) (d arDisc) {
+	var n int64
+
+	d.tracks = len(offset)
+	for i, o := range offset {
+		d.id1 += uint32(o)
+		d.id2 += uint32(max(o, 1)) * uint32(i+1)
+		for s := (o + cdLeadIn) / cdFramesPerSecond; s > 0; s /= 10 {
+			n += s % 10
+		}
+	}
+	d.id1 += uint32(leadOut)
+	d.id2 += uint32(leadOut) * uint32(len(offset)+1)
+	t := (leadOut+cdLeadIn)/cdFramesPerSecond - (offset[0]+cdLeadIn)/cdFramesPerSecond
+	d.cddb = uint32(n%255)<<24 | uint32(t)<<8 | uint32(len(offset))
+	return
+}
+
+func (d arDisc) String() string {
+	return fmt.Sprintf("%03d-%08x-%08x-%08x", d.tracks, d.id1, d.id2, d.cddb)
+}
+
+func (d arDisc) url() string {
+	return fmt.Sprintf(arURLFormat, d.id1&0xf, d.id1>>4&0xf, d.id1>>8&0xf,
+		d.tracks, d.id1, d.id2, d.cddb)
+}
+
+// arCRC returns AccurateRip v1 and v2 checksums of 16-bit stereo PCM. The
+// first and the last disc tracks skip 5 frames at disc edge.
+func arCRC(pcm []byte, first, last bool) (crc1, crc2 uint32) {
+	n := len(pcm) / 4
+	from, to := 1, n
+	if first {
+		from = arSkipFrames*cdSamplesPerFrame - 1
+	}
+	if last {
+		to = n - arSkipFrames*cdSamplesPerFrame
+	}
+	for i := from; i <= to; i++ {
+		s := binary.LittleEndian.Uint32(pcm[(i-1)*4:])
+		p := uint64(s) * uint64(i)
+		crc1 += uint32(p)
+		crc2 += uint32(p) + uint32(p>>32)
+	}
+	return
+}
+
+// arEntry is checksums of one pressing in AccurateRip database.
+type arEntry struct {
+	confidence []int
+	crc        []uint32
+}
+
+// parseARDatabase parses dBAR file: per pressing disc header followed by
+// confidence, CRC and frame 450 CRC of every track.
+func parseARDatabase(data []byte, tracks int) (entry []arEntry, err error) {
+	const hdrLen, trackLen = 13, 9
+
+	for len(data) > 0 {
+		if len(data) < hdrLen || int(data[0]) != tracks ||
+			len(data) < hdrLen+tracks*trackLen {
+			return nil, fmt.Errorf("wrong AccurateRip response")
+		}
+		data = data[hdrLen:]
+		var e arEntry
+		for range tracks {
+			e.confidence = append(e.confidence, int(data[0]))
+			e.crc = append(e.crc, binary.LittleEndian.Uint32(data[1:]))
+			data = data[trackLen:]
+		}
+		entry = append(entry, e)
+	}
+	return
+}
+
+func queryAccurateRip(d arDisc) (entry []arEntry, err error) {
+	client := http.Client{Timeout: arQueryTimeout}
+	resp, err := client.Get(d.url())
+	if err != nil {
+		return nil, fmt.Errorf("query AccurateRip: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("query AccurateRip: %v", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("query AccurateRip: %w", err)
+	}
+	if entry, err = parseARDatabase(data, d.tracks); err != nil {
+		return nil, fmt.Errorf("query AccurateRip: %w", err)
+	}
+	return
+}
+
+// readPCM decodes audio part to 44.1 kHz 16-bit stereo PCM.
+func readPCM(filePath string, start, dur Timestamp) ([]byte, error) {
+	out, err := runCommand("ffmpeg",
+		"-hide_banner",
+		"-v", "error",
+		"-ss", formatTimeSec(start),
+		"-i", filePath,
+		"-t", formatTimeSec(dur),
+		"-map", "0:a",
+		"-f", "s16le", "-ac", "2", "-ar", strconv.Itoa(cdSampleRate),
+		"-")
+	if err != nil {
+		return nil, fmt.Errorf("decode '%v': ffmpeg: %w", filePath, err)
+	}
+	return out, nil
+}
+
+func doCmdAccurateRip(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		idsOnly       bool
+		query         bool
+		cueRd         io.ReadCloser
+		end           Timestamp
+		entry         []arEntry
+		bad           int
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "cue audio file")
+	fl.BoolVar(&idsOnly, "ids", false, "print disc ID only, without decoding audio")
+	fl.BoolVar(&query, "query", false, "verify track checksums with AccurateRip database")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if audioFilePath == "" {
+		panic("No audio file, use -audio")
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	label := parseCue(cueRd, cueAudioFile).label
+	if len(label) == 0 || len(label) > maxCueTracks {
+		panic(fmt.Sprintf("AccurateRip needs 1-%d tracks", maxCueTracks))
+	}
+	end, err = getMediaDuration(audioFilePath)
+	panicIfError(err)
+	track := cueTrackLengths(label, end)
+
+	offset := make([]int64, len(label))
+	for i, l := range label {
+		offset[i] = l.start.Frames(RoundDown)
+	}
+	disc := newARDisc(offset, end.Frames(RoundDown))
+	if outputFormat != outputJSON {
+		_, err = fmt.Printf("Disc ID: %v\nURL: %v\n", disc, disc.url())
+		panicIfError(err)
+	}
+	if query && !idsOnly {
+		if entry, err = queryAccurateRip(disc); err != nil {
+			logWarningMessage(err.Error())
+		} else if entry == nil {
+			logWarningMessage("disc is not in AccurateRip database")
+		}
+	}
+
+	js := jsonAccurateRip{DiscID: disc.String(), URL: disc.url()}
+	for i, l := range label {
+		if idsOnly {
+			break
+		}
+		pcm, err := readPCM(audioFilePath, l.start, track[i].duration)
+		panicIfError(err)
+		t := jsonARTrack{Track: l.num}
+		crc1, crc2 := arCRC(pcm, i == 0, i == len(label)-1)
+		t.CRC1, t.CRC2 = fmt.Sprintf("%08x", crc1), fmt.Sprintf("%08x", crc2)
+		if entry != nil {
+			for _, e := range entry {
+				if e.crc[i] == crc1 || e.crc[i] == crc2 {
+					t.Confidence = max(t.Confidence, e.confidence[i])
+				}
+			}
+			t.Status = "accurate"
+			if t.Confidence == 0 {
+				t.Status = "not accurate"
+				bad++
+			}
+		}
+		js.Tracks = append(js.Tracks, t)
+		if outputFormat != outputJSON {
+			_, err = fmt.Printf("%02d  v1 %v  v2 %v", t.Track, t.CRC1, t.CRC2)
+			panicIfError(err)
+			if t.Status != "" {
+				_, err = fmt.Printf("  %v (confidence %d)", t.Status, t.Confidence)
+				panicIfError(err)
+			}
+			_, err = fmt.Println()
+			panicIfError(err)
+		}
+	}
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, js)
+	}
+	if bad > 0 {
+		panic(fmt.Sprintf("%d track(s) not accurate", bad))
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestNewARDisc(t *testing.T) {
+	for _, c := range []struct {
+		offset  []int64
+		leadOut int64
+		id, url string
+	}{
+		{[]int64{0, 44792, 61155, 72605, 96210, 130335}, 242307,
+			"006-0009e0ec-0035c077-5c0c9e06",
+			"http://www.accuraterip.com/accuraterip/c/e/0/dBAR-006-0009e0ec-0035c077-5c0c9e06.bin"},
+		{[]int64{0}, 20000,
+			"001-00004e20-00009c41-02010a01",
+			"http://www.accuraterip.com/accuraterip/0/2/e/dBAR-001-00004e20-00009c41-02010a01.bin"},
+		{[]int64{32, 15000, 30000}, 45000,
+			"003-00015fb0-00049400-0c025803",
+			"http://www.accuraterip.com/accuraterip/0/b/f/dBAR-003-00015fb0-00049400-0c025803.bin"},
+	} {
+		d := newARDisc(c.offset, c.leadOut)
+		if s := d.String(); s != c.id {
+			t.Errorf("disc %v/%d ID = %v, want %v", c.offset, c.leadOut, s, c.id)
+		}
+		if u := d.url(); u != c.url {
+			t.Errorf("disc %v/%d URL = %v, want %v", c.offset, c.leadOut, u, c.url)
+		}
+	}
+}
+
+func TestARCRC(t *testing.T) {
+	pcm := make([]byte, 12*cdSamplesPerFrame*4)
+	for i := range len(pcm) / 4 {
+		binary.LittleEndian.PutUint32(pcm[i*4:], uint32(i)*2654435761+12345)
+	}
+	for _, c := range []struct {
+		first, last bool
+		crc1, crc2  uint32
+	}{
+		{false, false, 0x7b70ebd8, 0x7c2ed625},
+		{false, true, 0x8bf259e6, 0x8c32fb73},
+		{true, false, 0x577cbca7, 0x5819bb70},
+		{true, true, 0x67fe2ab5, 0x681de0be},
+	} {
+		crc1, crc2 := arCRC(pcm, c.first, c.last)
+		if crc1 != c.crc1 || crc2 != c.crc2 {
+			t.Errorf("arCRC(first %v, last %v) = %08x, %08x, want %08x, %08x",
+				c.first, c.last, crc1, crc2, c.crc1, c.crc2)
+		}
+	}
+}
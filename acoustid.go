@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	acoustIDURL          = "https://api.acoustid.org/v2/lookup"
+	acoustIDKeyEnv       = "ACOUSTID_KEY"
+	acoustIDQueryTimeout = 30 * time.Second
+	// acoustIDInterval keeps queries under AcoustID limit of 3 per second.
+	acoustIDInterval = 350 * time.Millisecond
+	// acoustIDMinScore is the lowest fingerprint match score accepted.
+	acoustIDMinScore = 0.5
+)
+
+// untitledRe matches titles made from file names without a real title, like
+// "01", "Track 01" or "Audio Track 3".
+var untitledRe = regexp.MustCompile(`(?i)^[\s\d._()\[\]-]*((audio\s*)?track|untitled|unknown|piste|titel)?[\s\d._()\[\]-]*$`)
+
+// fingerprint is Chromaprint fingerprint made by fpcalc.
+type fingerprint struct {
+	Duration    float64 `json:"duration"`
+	Fingerprint string  `json:"fingerprint"`
+}
+
+func getFingerprint(filePath string) (fp fingerprint, err error) {
+	out, err := runCommand("fpcalc", "-json", filePath)
+	if err != nil {
+		return fp, fmt.Errorf("fingerprint: fpcalc: %w", err)
+	}
+	if err = json.Unmarshal(out, &fp); err != nil {
+		return fp, fmt.Errorf("fingerprint: %w", err)
+	}
+	if fp.Fingerprint == "" {
+		return fp, fmt.Errorf("fingerprint: no fingerprint of '%v'", filePath)
+	}
+	return
+}
+
+// acoustIDMatch is the best recording matching fingerprint.
+type acoustIDMatch struct {
+	title  string
+	artist string
+	score  float64
+}
+
+func queryAcoustID(key string, fp fingerprint) (m acoustIDMatch, found bool, err error) {
+	var js struct {
+		Status string `json:"status"`
+		Error  struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Results []struct {
+			Score      float64 `json:"score"`
+			Recordings []struct {
+				Title   string `json:"title"`
+				Artists []struct {
+					Name       string `json:"name"`
+					JoinPhrase string `json:"joinphrase"`
+				} `json:"artists"`
+			} `json:"recordings"`
+		} `json:"results"`
+	}
+
+	client := http.Client{Timeout: acoustIDQueryTimeout}
+	// fingerprints are too long for GET query
+	resp, err := client.PostForm(acoustIDURL, url.Values{
+		"client":      {key},
+		"meta":        {"recordings"},
+		"duration":    {strconv.Itoa(int(fp.Duration + 0.5))},
+		"fingerprint": {fp.Fingerprint},
+	})
+	if err != nil {
+		return m, false, fmt.Errorf("query AcoustID: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return m, false, fmt.Errorf("query AcoustID: %w", err)
+	}
+	if err = json.Unmarshal(data, &js); err != nil {
+		return m, false, fmt.Errorf("query AcoustID: %v: %w", resp.Status, err)
+	}
+	if js.Status != "ok" {
+		return m, false, fmt.Errorf("query AcoustID: %v", js.Error.Message)
+	}
+	for _, r := range js.Results {
+		if r.Score < acoustIDMinScore || r.Score <= m.score {
+			continue
+		}
+		for _, rec := range r.Recordings {
+			if rec.Title == "" {
+				continue
+			}
+			var artist strings.Builder
+			for i, a := range rec.Artists {
+				artist.WriteString(a.Name)
+				switch {
+				case a.JoinPhrase != "":
+					artist.WriteString(a.JoinPhrase)
+				case i < len(rec.Artists)-1:
+					artist.WriteString(", ")
+				}
+			}
+			m, found = acoustIDMatch{rec.Title, artist.String(), r.Score}, true
+			break
+		}
+	}
+	return
+}
+
+// lookupFingerprint finds recording of track by its fingerprint.
+func lookupFingerprint(key, filePath string) (m acoustIDMatch, found bool, err error) {
+	fp, err := getFingerprint(filePath)
+	if err != nil {
+		return
+	}
+	return queryAcoustID(key, fp)
+}
+
+// applyFingerprints fills titles and performers of untitled tracks with
+// recordings found by their AcoustID fingerprints with -fingerprint. Tracks
+// not found keep their titles.
+func (opt *cueOptions) applyFingerprints(trackFilePath, title []string) {
+	if !opt.fingerprint {
+		return
+	}
+	key := os.Getenv(acoustIDKeyEnv)
+	if key == "" {
+		panic("Option -fingerprint requires AcoustID API key in " + acoustIDKeyEnv)
+	}
+	opt.trackPerformer = make([]string, len(title))
+	queried := false
+	for i, path := range trackFilePath {
+		if !untitledRe.MatchString(denumRe.ReplaceAllString(title[i], "$1")) {
+			continue
+		}
+		if queried {
+			time.Sleep(acoustIDInterval)
+		}
+		queried = true
+		m, found, err := lookupFingerprint(key, path)
+		switch {
+		case err != nil:
+			logWarningMessage(fmt.Sprintf("track %d '%v': %v", opt.numStart+i, path, err))
+		case !found:
+			logWarningMessage(fmt.Sprintf("track %d '%v': no AcoustID match", opt.numStart+i, path))
+		default:
+			logMessage(fmt.Sprintf("track %d '%v': %v - %v (score %.2f)", opt.numStart+i,
+				path, m.artist, m.title, m.score))
+			title[i], opt.trackPerformer[i] = m.title, m.artist
+		}
+	}
+}
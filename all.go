@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var allArtifactExt = map[string]string{
+	"cue":    ".cue",
+	"labels": ".txt",
+	"m3u":    ".m3u",
+	"ffmeta": ".ffmeta",
+	"concat": ".ffconcat",
+}
+
+// doCmdMakeAll probes tracks once and writes several artifacts from the
+// same track times.
+func doCmdMakeAll(arg []string) {
+	var (
+		basePath      string
+		emit          string
+		opt           cueOptions
+		trackFilePath []string
+		start         []Timestamp
+		end           Timestamp
+		title         []string
+		skipped       int
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&basePath, "o", "", "output file path without extension")
+	fl.StringVar(&emit, "emit", "cue,labels", "artifacts to write: cue,labels,m3u,ffmeta,concat")
+	opt.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	trackFilePath = opt.tracks(fl.Args())
+	if len(trackFilePath) == 0 {
+		panic("No input track(s)")
+	}
+	if basePath == "" {
+		panic("No output file path")
+	}
+	artifact := strings.Split(emit, ",")
+	for _, a := range artifact {
+		if _, ok := allArtifactExt[a]; !ok {
+			panic("Unknown artifact: '" + a + "'")
+		}
+		if a == "cue" && opt.numStart+len(trackFilePath)-1 > maxCueTracks {
+			panic(fmt.Sprintf("Cue sheet supports at most %d tracks", maxCueTracks))
+		}
+	}
+	opt.check()
+	opt.sortTracks(trackFilePath)
+	trackFilePath, skipped = opt.readableTracks(trackFilePath)
+	opt.validateAudio(trackFilePath)
+	opt.inferMetadata(trackFilePath)
+	opt.setTitle(filepath.Base(basePath))
+	opt.cueDir = filepath.Dir(basePath)
+	if opt.cover != "" {
+		// artifacts reference the cover copy next to them
+		cover := basePath + strings.ToLower(filepath.Ext(opt.cover))
+		if path := createToolOutput(cover); path != "" {
+			if err := copyFile(path, opt.cover); err != nil {
+				panic("Cannot copy cover: " + err.Error())
+			}
+		}
+		opt.cover = cover
+	}
+
+	start, end = trackStartTimes(opt.shiftStart(), opt.overlap, trackFilePath, true)
+	trackFilePath, start = opt.dedupeBoundaries(trackFilePath, start)
+	title = opt.trackTitles(trackFilePath)
+	opt.applyNFO(title, trackDurations(start, end, opt.overlap))
+	opt.applyFingerprints(trackFilePath, title)
+	opt.dedupTitles(title)
+
+	for _, a := range artifact {
+		var err error
+		f := createOutput(basePath + allArtifactExt[a])
+		defer f.Close()
+		switch a {
+		case "cue":
+			writeCue(f, &opt, title, start, 0)
+			if opt.db != "" {
+				part := []cuePart{{path: basePath + allArtifactExt[a], title: opt.title,
+					hi: len(start), num: opt.numStart}}
+				dur := trackDurations(start, end, opt.overlap)
+				afterCommit(func() error {
+					return opt.recordCue(part, trackFilePath, dur, title, start)
+				})
+			}
+		case "labels":
+			label := make([]cueLabel, len(start))
+			for i := range start {
+				label[i] = cueLabel{num: i + 1, start: start[i], title: title[i]}
+			}
+			numerateLabel(label, defaultNumStart, opt.num)
+			writeLabel(f, label, false)
+		case "m3u":
+			err = writeM3U(f, filepath.Dir(basePath), trackFilePath, title,
+				trackDurations(start, end, opt.overlap))
+		case "ffmeta":
+			err = writeFFMetadata(f, opt.title, makeChapters(start, end, title))
+		case "concat":
+			err = writeConcatList(f, trackFilePath, trackDurations(start, end, opt.overlap))
+		}
+		panicIfError(err)
+	}
+	reportSkipped(skipped)
+}
+
+// trackDurations returns track lengths from start times with overlaps added
+// back.
+func trackDurations(start []Timestamp, end, overlap Timestamp) (dur []Timestamp) {
+	for i := range start {
+		if i < len(start)-1 {
+			dur = append(dur, start[i+1]-start[i]+overlap)
+		} else {
+			dur = append(dur, end-start[i])
+		}
+	}
+	return
+}
+
+func makeChapters(start []Timestamp, end Timestamp, title []string) (chap []chapter) {
+	for i := range start {
+		c := chapter{start: start[i], end: end, title: title[i]}
+		if i < len(start)-1 {
+			c.end = start[i+1]
+		}
+		chap = append(chap, c)
+	}
+	return
+}
+
+// writeM3U writes extended M3U playlist with paths relative to dir.
+func writeM3U(w io.Writer, dir string, trackFilePath, title []string,
+	dur []Timestamp) (err error) {
+	if _, err = fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return
+	}
+	for i, path := range trackFilePath {
+		if rel, err := relPath(dir, path); err == nil {
+			path = rel
+		}
+		_, err = fmt.Fprintf(w, "#EXTINF:%d,%v\n%v\n",
+			dur[i].Seconds(RoundNearest), title[i], path)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// relPath returns path relative to dir.
+func relPath(dir, path string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absDir, absPath)
+}
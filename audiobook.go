@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultAudiobookBitrate = "64k"
+
+type chapter struct {
+	start, end Timestamp
+	title      string
+}
+
+func doCmdMakeAudiobook(arg []string) {
+	var (
+		bookFilePath  string
+		bookTitle     string
+		coverFilePath string
+		bitrate       string
+		titleOpt      titleOptions
+		chapterPath   []string
+		title         []string
+		chap          []chapter
+		start         []Timestamp
+		end           Timestamp
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&bookFilePath, "o", "", "output audiobook file path")
+	fl.StringVar(&bookTitle, "title", "", "audiobook title, output file name by default")
+	fl.StringVar(&coverFilePath, "cover", "", "cover art image file path")
+	fl.StringVar(&bitrate, "b", defaultAudiobookBitrate, "AAC bitrate")
+	addProbeFlags(fl)
+	titleOpt.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	chapterPath = fl.Args()
+	if len(chapterPath) == 0 {
+		panic("No input chapter(s)")
+	}
+	if bookFilePath == "" {
+		panic("No output audiobook file")
+	}
+	if bookTitle == "" {
+		bookTitle = fileTitle(bookFilePath)
+	}
+
+	start, end = trackStartTimes(0, 0, chapterPath, true)
+	for i, path := range chapterPath {
+		title = append(title, formatTrackTitle(i+1, path, &titleOpt))
+	}
+	titleOpt.dedupTitles(title)
+	chap = makeChapters(start, end, title)
+
+	if path := createToolOutput(bookFilePath); path != "" {
+		err = makeAudiobook(path, bookTitle, coverFilePath, bitrate, chapterPath,
+			trackDurations(start, end, 0), chap)
+		panicIfError(err)
+	}
+}
+
+func makeAudiobook(bookFilePath, bookTitle, coverFilePath, bitrate string,
+	chapterPath []string, dur []Timestamp, chap []chapter) (err error) {
+	var tmpDir, listPath, metaPath string
+
+	tmpDir, err = os.MkdirTemp("", "cue-maker-")
+	if err != nil {
+		return fmt.Errorf("make audiobook: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	listPath = filepath.Join(tmpDir, "list.txt")
+	if err = writeFile(listPath, func(w io.Writer) error {
+		return writeConcatList(w, chapterPath, dur)
+	}); err != nil {
+		return fmt.Errorf("make audiobook: %w", err)
+	}
+	metaPath = filepath.Join(tmpDir, "meta.txt")
+	if err = writeFile(metaPath, func(w io.Writer) error {
+		return writeFFMetadata(w, bookTitle, chap)
+	}); err != nil {
+		return fmt.Errorf("make audiobook: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-v", "error",
+		"-y",
+		"-f", "concat", "-safe", "0", "-i", listPath,
+		"-i", metaPath,
+	}
+	if coverFilePath != "" {
+		args = append(args, "-i", coverFilePath)
+	}
+	args = append(args,
+		"-map", "0:a",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-c:a", "aac", "-b:a", bitrate)
+	if coverFilePath != "" {
+		args = append(args,
+			"-map", "2:v",
+			"-c:v", "copy",
+			"-disposition:v:0", "attached_pic")
+	}
+	if deterministic {
+		// no encoder version and creation time in output
+		args = append(args, "-fflags", "+bitexact", "-flags:a", "+bitexact")
+	}
+	args = append(args, "-f", "ipod", bookFilePath)
+
+	if _, err = runCommand("ffmpeg", args...); err != nil {
+		return fmt.Errorf("make audiobook: ffmpeg: %w", err)
+	}
+	return
+}
+
+// writeConcatList writes ffmpeg concat demuxer file list. With durations
+// ffmpeg takes file lengths from the list, the same the cue times are made of.
+func writeConcatList(w io.Writer, filePath []string, dur []Timestamp) (err error) {
+	var abs string
+
+	if _, err = fmt.Fprintln(w, "ffconcat version 1.0"); err != nil {
+		return
+	}
+	for i, path := range filePath {
+		if abs, err = filepath.Abs(path); err != nil {
+			return
+		}
+		abs = strings.ReplaceAll(abs, "'", `'\''`)
+		if _, err = fmt.Fprintf(w, "file '%v'\n", abs); err != nil {
+			return
+		}
+		if dur != nil {
+			if _, err = fmt.Fprintf(w, "duration %v\n", formatTimeSec(dur[i])); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// writeFFMetadata writes ffmpeg metadata file with chapters.
+func writeFFMetadata(w io.Writer, title string, chap []chapter) (err error) {
+	if _, err = fmt.Fprintf(w, ";FFMETADATA1\ntitle=%v\n", escapeFFMetadata(title)); err != nil {
+		return
+	}
+	for _, c := range chap {
+		_, err = fmt.Fprintf(w, "\n[CHAPTER]\nTIMEBASE=1/%d\nSTART=%d\nEND=%d\ntitle=%v\n",
+			uSecInSecond, c.start, c.end, escapeFFMetadata(c.title))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func escapeFFMetadata(s string) string {
+	return ffMetadataEscaper.Replace(s)
+}
+
+var ffMetadataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"=", `\=`,
+	";", `\;`,
+	"#", `\#`,
+	"\n", "\\\n")
+
+func writeFile(path string, write func(io.Writer) error) (err error) {
+	var f *os.File
+
+	if f, err = os.Create(path); err != nil {
+		return
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+func copyFile(dst, src string) (err error) {
+	var f *os.File
+
+	if f, err = os.Open(src); err != nil {
+		return
+	}
+	defer f.Close()
+	return writeFile(dst, func(w io.Writer) (err error) {
+		_, err = io.Copy(w, f)
+		return
+	})
+}
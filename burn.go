@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// cdMinTrackSectors is the shortest track allowed on audio CD, 4 seconds.
+	cdMinTrackSectors = 4 * cdFramesPerSecond
+	// cdrSectors is capacity of 80 minute CD-R.
+	cdrSectors = 80 * 60 * cdFramesPerSecond
+)
+
+type jsonBurn struct {
+	Cue     string   `json:"cue"`
+	Image   string   `json:"image"`
+	Changes []string `json:"changes"`
+}
+
+// doCmdBurn converts cue audio file to CD-R image: 44.1 kHz 16-bit stereo
+// audio padded to whole sectors, and cue with tracks aligned to sectors.
+func doCmdBurn(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		basePath     string
+		wav          bool
+		cueRd        io.ReadCloser
+		info         mediaInfo
+		change       []string
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&basePath, "o", "", "output base path for cue and image files")
+	fl.BoolVar(&wav, "wav", false, "write WAV image instead of raw BIN")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 1 {
+		panic("Expected one audio file")
+	}
+	if isStdio(basePath) {
+		panic("No output base path, use -o")
+	}
+	audioFilePath := fl.Arg(0)
+	changed := func(format string, a ...any) {
+		change = append(change, fmt.Sprintf(format, a...))
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	sheet := parseCue(cueRd, cueAudioFile)
+	if len(sheet.label) == 0 || len(sheet.label) > maxCueTracks {
+		panic(fmt.Sprintf("Audio CD needs 1-%d tracks", maxCueTracks))
+	}
+	info, err = getMediaInfo(audioFilePath)
+	panicIfError(err)
+	end := Timestamp(info.Duration)
+
+	switch {
+	case info.SampleRate == 0:
+		changed("audio converted to %d Hz 16-bit stereo", cdSampleRate)
+	default:
+		if info.SampleRate != cdSampleRate {
+			changed("audio resampled from %d Hz to %d Hz", info.SampleRate, cdSampleRate)
+		}
+		if info.Channels != 2 {
+			changed("audio converted from %d channel(s) to stereo", info.Channels)
+		}
+		if info.SampleFmt != "s16" && info.SampleFmt != "s16p" {
+			changed("audio converted from %v samples to 16-bit", info.SampleFmt)
+		}
+	}
+	endSectors := end.Frames(RoundUp)
+	samples := end.Samples(cdSampleRate, RoundNearest)
+	if pad := endSectors*cdSamplesPerFrame - samples; pad > 0 {
+		changed("audio padded with %d samples of silence to whole sector", pad)
+	}
+	if endSectors > cdrSectors {
+		logWarningMessage(fmt.Sprintf("audio length %v exceeds 80 minute CD-R",
+			newMSF(endSectors)))
+	}
+
+	sheet.cover = ""
+	for i, l := range sheet.label {
+		if t := l.start.AlignToFrame(); t != l.start {
+			changed("track %02d start moved from %v to %v", i+1,
+				l.start.String(), t.CueTime())
+			sheet.label[i].start = t
+		}
+		if t := l.index00.AlignToFrame(); l.index00 >= 0 && t != l.index00 {
+			changed("track %02d INDEX 00 moved from %v to %v", i+1,
+				l.index00.String(), t.CueTime())
+			sheet.label[i].index00 = t
+		}
+	}
+	for i, l := range sheet.label {
+		next := endSectors
+		if i < len(sheet.label)-1 {
+			next = sheet.label[i+1].start.Frames(RoundDown)
+		}
+		if next-l.start.Frames(RoundDown) < cdMinTrackSectors {
+			panic(fmt.Sprintf("Track %02d is shorter than 4 seconds", i+1))
+		}
+	}
+
+	ext, fileType := ".bin", "BINARY"
+	if wav {
+		ext, fileType = ".wav", "WAVE"
+	}
+	imagePath, cuePath := basePath+ext, basePath+".cue"
+	args := []string{
+		"-hide_banner",
+		"-v", "error",
+		"-y",
+		"-i", audioFilePath,
+		"-map", "0:a",
+		"-map_metadata", "-1",
+		"-af", fmt.Sprintf("aresample=%d,aformat=sample_fmts=s16:channel_layouts=stereo,"+
+			"apad=whole_len=%d,atrim=end_sample=%d", cdSampleRate,
+			endSectors*cdSamplesPerFrame, endSectors*cdSamplesPerFrame),
+		"-c:a", "pcm_s16le",
+	}
+	if wav {
+		args = append(args, "-f", "wav")
+	} else {
+		args = append(args, "-f", "s16le")
+	}
+	if deterministic {
+		args = append(args, "-fflags", "+bitexact", "-flags:a", "+bitexact")
+	}
+	if path := createToolOutput(imagePath); path != "" {
+		if _, err = runCommand("ffmpeg", append(args, path)...); err != nil {
+			panic("Cannot write CD image: ffmpeg: " + err.Error())
+		}
+	}
+
+	cueWr := createOutput(cuePath)
+	writeCueSheet(cueWr, sheet, filepath.Base(imagePath), fileType)
+	panicIfError(cueWr.Close())
+
+	if outputFormat == outputJSON {
+		if change == nil {
+			change = []string{}
+		}
+		writeJSON(os.Stdout, jsonBurn{cuePath, imagePath, change})
+		return
+	}
+	for _, c := range change {
+		_, err = fmt.Println(c)
+		panicIfError(err)
+	}
+}
+
+// redBookProblems returns how audio differs from CD audio, 44.1 kHz 16-bit
+// stereo.
+func redBookProblems(info mediaInfo) (p []string) {
+	if info.SampleRate == 0 {
+		return []string{"unknown audio format"}
+	}
+	if info.SampleRate != cdSampleRate {
+		p = append(p, fmt.Sprintf("%d Hz", info.SampleRate))
+	}
+	if info.Channels != 2 {
+		p = append(p, fmt.Sprintf("%d channel(s)", info.Channels))
+	}
+	if info.SampleFmt != "s16" && info.SampleFmt != "s16p" {
+		p = append(p, info.SampleFmt+" samples")
+	}
+	return
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationCache keeps probed durations between runs with -cache. Entries
+// are found by absolute path and probe mode, and are valid while file size
+// and modification time stay the same.
+var durationCache struct {
+	sync.Mutex
+	loaded bool
+	dirty  bool
+	entry  map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	Path     string   `json:"path"`
+	Mode     string   `json:"mode"`
+	Size     int64    `json:"size"`
+	ModTime  string   `json:"mtime"`
+	Duration jsonTime `json:"duration"`
+}
+
+type cacheFile struct {
+	Files []cacheEntry `json:"files"`
+}
+
+// durationCachePath returns cache file path, CUE_MAKER_CACHE or in user
+// cache directory.
+func durationCachePath() (string, error) {
+	if path := os.Getenv("CUE_MAKER_CACHE"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cue-maker", "durations.json"), nil
+}
+
+// probeMode names probe options changing durations, so durations probed
+// differently are cached apart.
+func probeMode() string {
+	var mode []string
+
+	for _, m := range []struct {
+		set  bool
+		name string
+	}{
+		{useNativeProbe(), "native"},
+		{probeOpt.gapless, "gapless"},
+		{probeOpt.exact, "exact"},
+		{probeOpt.count, "count"},
+	} {
+		if m.set {
+			mode = append(mode, m.name)
+		}
+	}
+	if len(mode) == 0 {
+		return "ffprobe"
+	}
+	return strings.Join(mode, ",")
+}
+
+func cacheKey(path, mode string) string {
+	return path + "\x00" + mode
+}
+
+func readDurationCache() (entry map[string]cacheEntry, err error) {
+	var cache cacheFile
+
+	path, err := durationCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]cacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	entry = make(map[string]cacheEntry, len(cache.Files))
+	for _, e := range cache.Files {
+		entry[cacheKey(e.Path, e.Mode)] = e
+	}
+	return entry, nil
+}
+
+func writeDurationCache(entry map[string]cacheEntry) error {
+	var cache cacheFile
+
+	path, err := durationCachePath()
+	if err != nil {
+		return err
+	}
+	for _, k := range slices.Sorted(maps.Keys(entry)) {
+		cache.Files = append(cache.Files, entry[k])
+	}
+	data, err := json.MarshalIndent(cache, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%v.%d.tmp", path, os.Getpid())
+	if err = os.WriteFile(tmp, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// fileCacheEntry returns cache entry of file without duration.
+func fileCacheEntry(filePath string) (e cacheEntry, ok bool) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return
+	}
+	st, err := os.Stat(abs)
+	if err != nil || !st.Mode().IsRegular() {
+		return
+	}
+	return cacheEntry{Path: abs, Mode: probeMode(), Size: st.Size(),
+		ModTime: st.ModTime().UTC().Format(time.RFC3339Nano)}, true
+}
+
+// isRefreshed reports whether file matches -refresh pattern, by path or
+// base name.
+func isRefreshed(filePath string) bool {
+	if probeOpt.refresh == "" {
+		return false
+	}
+	for _, name := range []string{filePath, filepath.Base(filePath)} {
+		if ok, _ := filepath.Match(probeOpt.refresh, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func loadDurationCache() {
+	if durationCache.loaded {
+		return
+	}
+	durationCache.loaded = true
+	entry, err := readDurationCache()
+	if err != nil {
+		logWarningMessage("duration cache ignored: " + err.Error())
+		entry = map[string]cacheEntry{}
+	}
+	durationCache.entry = entry
+}
+
+// getCachedDuration returns duration of unchanged file from -cache.
+func getCachedDuration(filePath string) (dur Timestamp, ok bool) {
+	if !probeOpt.cache || isRefreshed(filePath) {
+		return
+	}
+	e, ok := fileCacheEntry(filePath)
+	if !ok {
+		return
+	}
+	durationCache.Lock()
+	defer durationCache.Unlock()
+	loadDurationCache()
+	c, ok := durationCache.entry[cacheKey(e.Path, e.Mode)]
+	if ok = ok && c.Size == e.Size && c.ModTime == e.ModTime; ok {
+		dur = Timestamp(c.Duration)
+	}
+	return
+}
+
+func addCachedDuration(filePath string, dur Timestamp) {
+	if !probeOpt.cache {
+		return
+	}
+	e, ok := fileCacheEntry(filePath)
+	if !ok {
+		return
+	}
+	e.Duration = jsonTime(dur)
+	durationCache.Lock()
+	defer durationCache.Unlock()
+	loadDurationCache()
+	durationCache.entry[cacheKey(e.Path, e.Mode)] = e
+	durationCache.dirty = true
+}
+
+// saveDurationCache writes durations probed with -cache.
+func saveDurationCache() {
+	durationCache.Lock()
+	defer durationCache.Unlock()
+	if durationCache.dirty {
+		durationCache.dirty = false
+		if err := writeDurationCache(durationCache.entry); err != nil {
+			logWarningMessage("cannot save duration cache: " + err.Error())
+		}
+	}
+}
+
+// doCmdCache prints duration cache statistics or removes its entries.
+func doCmdCache(arg []string) {
+	if len(arg) < 2 {
+		panic("Expected stats or clear")
+	}
+	path, err := durationCachePath()
+	panicIfError(err)
+	entry, err := readDurationCache()
+	if err != nil {
+		panic("Cannot read duration cache: " + err.Error())
+	}
+
+	switch arg[1] {
+	case "stats":
+		if len(arg) > 2 {
+			panic("No arguments expected")
+		}
+		var stale, missing int
+		for _, e := range entry {
+			st, err := os.Stat(e.Path)
+			switch {
+			case err != nil:
+				missing++
+			case st.Size() != e.Size || st.ModTime().UTC().Format(time.RFC3339Nano) != e.ModTime:
+				stale++
+			}
+		}
+		if outputFormat == outputJSON {
+			writeJSON(os.Stdout, jsonCacheStats{path, len(entry), stale, missing})
+			return
+		}
+		_, err = fmt.Fprintf(os.Stdout, "cache:   %v\nentries: %d\nstale:   %d\nmissing: %d\n",
+			path, len(entry), stale, missing)
+		panicIfError(err)
+	case "clear":
+		// without patterns all entries are removed
+		n := len(entry)
+		for k, e := range entry {
+			match := len(arg) == 2
+			for _, p := range arg[2:] {
+				for _, name := range []string{e.Path, filepath.Base(e.Path)} {
+					ok, err := filepath.Match(p, name)
+					if err != nil {
+						panic("Wrong pattern: " + p)
+					}
+					match = match || ok
+				}
+			}
+			if match {
+				delete(entry, k)
+			}
+		}
+		if len(entry) < n {
+			panicIfError(writeDurationCache(entry))
+		}
+		_, err = fmt.Fprintf(os.Stdout, "removed %d of %d entries\n", n-len(entry), n)
+		panicIfError(err)
+	default:
+		panic("Wrong cache command: " + arg[1])
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	cdTextMaxLen   = 80  // max characters in one CD-TEXT item
+	cdTextPackText = 12  // text bytes in one pack
+	cdTextMaxPacks = 255 // packs in one block including 3 size info packs
+	cdTextInfoPack = 3
+)
+
+func truncateCDText(s string) string {
+	if utf8.RuneCountInString(s) <= cdTextMaxLen {
+		return s
+	}
+	return string([]rune(s)[:cdTextMaxLen])
+}
+
+// checkCDText warns about items longer than CD-TEXT limit and about items
+// of one type (e.g. all titles) not fitting in one CD-TEXT block.
+func checkCDText(item []string, truncated bool) {
+	var size int
+
+	for _, s := range item {
+		n := utf8.RuneCountInString(s)
+		if n > cdTextMaxLen && !truncated {
+			logWarningMessage(fmt.Sprintf("CD-TEXT item is %d characters long, max %d: %q",
+				n, cdTextMaxLen, s))
+		}
+		size += n + 1 // null terminated
+	}
+	packs := (size+cdTextPackText-1)/cdTextPackText + cdTextInfoPack
+	if packs > cdTextMaxPacks {
+		logWarningMessage(fmt.Sprintf("CD-TEXT needs %d packs, max %d", packs, cdTextMaxPacks))
+	}
+}
+
+const (
+	cdTextPackSize     = 18
+	cdTextTypeTitle    = 0x80
+	cdTextTypePerf     = 0x81
+	cdTextTypeSizeInfo = 0x8f
+	cdTextCharset8859  = 0x00
+	cdTextLangEnglish  = 0x09
+)
+
+func doCmdMakeCDText(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		cdtFilePath  string
+		cueRd        io.ReadCloser
+		sheet        cueSheet
+		data         []byte
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&cdtFilePath, "o", "", "output CD-TEXT file path")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if cdtFilePath == "" {
+		panic("No output CD-TEXT file")
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	sheet = parseCue(cueRd, cueAudioFile)
+	if len(sheet.label) > maxCueTracks {
+		panic(fmt.Sprintf("CD-TEXT supports at most %d tracks", maxCueTracks))
+	}
+
+	data = makeCDText(sheet)
+	f := createOutput(cdtFilePath)
+	defer f.Close()
+	_, err = f.Write(data)
+	panicIfError(err)
+}
+
+// makeCDText returns CD-TEXT file with one ISO-8859-1 English block: 4 byte
+// length header followed by TITLE, PERFORMER and size info packs.
+func makeCDText(sheet cueSheet) []byte {
+	var (
+		pack   [][cdTextPackSize]byte
+		counts [16]byte
+	)
+
+	title := []string{sheet.title}
+	perf := []string{sheet.performer}
+	hasPerf := sheet.performer != ""
+	for _, l := range sheet.label {
+		title = append(title, l.title)
+		perf = append(perf, l.performer)
+		hasPerf = hasPerf || l.performer != ""
+	}
+
+	for _, t := range []struct {
+		typ  byte
+		item []string
+		use  bool
+	}{
+		{cdTextTypeTitle, title, true},
+		{cdTextTypePerf, perf, hasPerf},
+	} {
+		if !t.use {
+			continue
+		}
+		p := cdTextTextPacks(t.typ, t.item, len(pack))
+		counts[t.typ-0x80] = byte(len(p))
+		pack = append(pack, p...)
+	}
+	if len(pack)+cdTextInfoPack > cdTextMaxPacks {
+		panic(fmt.Sprintf("CD-TEXT needs %d packs, max %d",
+			len(pack)+cdTextInfoPack, cdTextMaxPacks))
+	}
+	counts[cdTextTypeSizeInfo-0x80] = cdTextInfoPack
+
+	var info [36]byte
+	info[0] = cdTextCharset8859
+	info[1] = 1
+	info[2] = byte(len(sheet.label))
+	copy(info[4:20], counts[:])
+	info[20] = byte(len(pack) + cdTextInfoPack - 1) // last sequence number
+	info[28] = cdTextLangEnglish
+	for i := 0; i < cdTextInfoPack; i++ {
+		var p [cdTextPackSize]byte
+		p[0] = cdTextTypeSizeInfo
+		p[1] = byte(i)
+		p[2] = byte(len(pack))
+		copy(p[4:16], info[i*cdTextPackText:])
+		pack = append(pack, p)
+	}
+
+	data := make([]byte, 4, 4+len(pack)*cdTextPackSize)
+	binary.BigEndian.PutUint16(data, uint16(len(pack)*cdTextPackSize+2))
+	for _, p := range pack {
+		crc := ^crc16CCITT(p[:16])
+		binary.BigEndian.PutUint16(p[16:], crc)
+		data = append(data, p[:]...)
+	}
+	return data
+}
+
+// cdTextTextPacks splits null terminated items into packs. Item 0 is the
+// disc, items 1.. are tracks.
+func cdTextTextPacks(typ byte, item []string, seq int) (pack [][cdTextPackSize]byte) {
+	var (
+		text  []byte
+		owner []int // item index of every text byte
+		pos   []int // position of every text byte in its item
+	)
+
+	for i, s := range item {
+		b := toLatin1(s)
+		for j := range len(b) + 1 {
+			owner = append(owner, i)
+			pos = append(pos, j)
+		}
+		text = append(text, b...)
+		text = append(text, 0)
+	}
+	for off := 0; off < len(text); off += cdTextPackText {
+		var p [cdTextPackSize]byte
+		p[0] = typ
+		p[1] = byte(owner[off])
+		p[2] = byte(seq + len(pack))
+		p[3] = byte(min(pos[off], 15))
+		copy(p[4:16], text[off:])
+		pack = append(pack, p)
+	}
+	return
+}
+
+// toLatin1 converts s to ISO-8859-1 transliterating other characters.
+func toLatin1(s string) (b []byte) {
+	for _, r := range s {
+		if r > 0xff {
+			t := transliterate(string(r))
+			if t != string(r) {
+				for _, r := range t {
+					b = append(b, latin1Byte(r))
+				}
+				continue
+			}
+		}
+		b = append(b, latin1Byte(r))
+	}
+	return
+}
+
+func latin1Byte(r rune) byte {
+	if r > 0xff {
+		return '?'
+	}
+	return byte(r)
+}
+
+// crc16CCITT computes CRC-16 with polynomial 0x1021 and zero initial value.
+func crc16CCITT(data []byte) (crc uint16) {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return
+}
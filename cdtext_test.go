@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		crc  uint16
+	}{
+		{"", 0},
+		{"123456789", 0x31c3},
+		{"\x80\x00\x00\x00Album\x00Song\x00\x00", 0x7e56},
+	} {
+		if crc := crc16CCITT([]byte(c.data)); crc != c.crc {
+			t.Errorf("crc16CCITT(%q) = %04x, want %04x", c.data, crc, c.crc)
+		}
+	}
+}
+
+func TestMakeCDText(t *testing.T) {
+	sheet := cueSheet{title: "Album", label: []cueLabel{{num: 1, title: "Song"}}}
+	want := "004a0000" +
+		"80000000416c62756d00536f6e670000" + "81a9" + // TITLE of disc and track
+		"8f000100000101000100000000000000" + "052a" + // size info: charset, tracks, pack counts
+		"8f010200000000000000000303000000" + "a3a4" + // last sequence number
+		"8f020300000000000900000000000000" + "17e7" // language
+	if got := hex.EncodeToString(makeCDText(sheet)); got != want {
+		t.Errorf("makeCDText =\n%v, want\n%v", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// CD audio: 75 frames (sectors) per second of 588 stereo 16-bit samples.
+const (
+	cdFramesPerSecond = 75
+	cdSamplesPerFrame = 588
+	cdSampleRate      = cdFramesPerSecond * cdSamplesPerFrame
+	cdBytesPerSector  = 2352
+	cdLeadIn          = 2 * cdFramesPerSecond // pregap of the first track
+)
+
+// cdMSF is CD time in minutes, seconds and frames.
+type cdMSF struct {
+	min, sec, frame int64
+}
+
+func newMSF(sectors int64) cdMSF {
+	sec := sectors / cdFramesPerSecond
+	return cdMSF{sec / 60, sec % 60, sectors % cdFramesPerSecond}
+}
+
+func (m cdMSF) sectors() int64 {
+	return (m.min*60+m.sec)*cdFramesPerSecond + m.frame
+}
+
+func (m cdMSF) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", m.min, m.sec, m.frame)
+}
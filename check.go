@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// isrcRe matches ISRC: country code, registrant code, year and designation.
+var isrcRe = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+func doCmdCheck(arg []string) {
+	var (
+		cueFilePath string
+		cueRd       io.ReadCloser
+		problem     []string
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+
+	problem = checkCue(cueRd)
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, append([]string{}, problem...))
+	} else {
+		for _, p := range problem {
+			_, err := fmt.Println(p)
+			panicIfError(err)
+		}
+	}
+	if len(problem) > 0 {
+		panic(fmt.Sprintf("%d problem(s) found", len(problem)))
+	}
+}
+
+// checkCue validates cue fields line by line and returns problems found.
+func checkCue(cue io.Reader) (problem []string) {
+	name := inputName(cue)
+	report := func(line int, format string, a ...any) {
+		problem = append(problem, fmt.Sprintf("%v:%d: ", name, line)+fmt.Sprintf(format, a...))
+	}
+
+	scan := bufio.NewScanner(cue)
+	for n := 1; scan.Scan(); n++ {
+		field := strings.Fields(scan.Text())
+		if len(field) == 0 {
+			continue
+		}
+		switch field[0] {
+		case "CATALOG":
+			if len(field) != 2 || len(field[1]) != 13 {
+				report(n, "CATALOG must be 13 digits: %q", strings.Join(field[1:], " "))
+			} else if _, err := normalizeCatalog(field[1]); err != nil {
+				report(n, "wrong CATALOG: %v", err)
+			}
+		case "FLAGS":
+			for _, f := range field[1:] {
+				if !cueFlags[f] {
+					report(n, "unknown flag %q", f)
+				}
+			}
+		case "ISRC":
+			if len(field) != 2 || !isrcRe.MatchString(field[1]) {
+				report(n, "wrong ISRC %q", strings.Join(field[1:], " "))
+			}
+		}
+	}
+	if err := scan.Err(); err != nil {
+		panic("Read cue " + name + ": " + err.Error())
+	}
+	return
+}
+
+var cueFlags = map[string]bool{"DCP": true, "4CH": true, "PRE": true, "SCMS": true}
+
+// parseTrackFlags parses [track:]flag,... values, flags without track number
+// apply to all tracks (key 0).
+func parseTrackFlags(value []string) (flags map[int][]string, err error) {
+	flags = make(map[int][]string)
+	for _, v := range value {
+		track := 0
+		if n, f, ok := strings.Cut(v, ":"); ok {
+			if track, err = strconv.Atoi(n); err != nil || track < 1 {
+				return nil, fmt.Errorf("wrong track number in '%v'", v)
+			}
+			v = f
+		}
+		for _, f := range strings.Split(v, ",") {
+			f = strings.ToUpper(strings.TrimSpace(f))
+			if !cueFlags[f] {
+				return nil, fmt.Errorf("unknown flag '%v'", f)
+			}
+			flags[track] = append(flags[track], f)
+		}
+	}
+	return
+}
+
+// normalizeCatalog validates EAN-13 or UPC-A barcode check digit and returns
+// it as 13 digits.
+func normalizeCatalog(code string) (string, error) {
+	var sum int
+
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 {
+		return "", fmt.Errorf("'%v' is not 13 digit EAN or 12 digit UPC", code)
+	}
+	for i, c := range code {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("'%v' is not a number", code)
+		}
+		if i < 12 {
+			sum += int(c-'0') * (1 + 2*(i%2))
+		}
+	}
+	if check := (10 - sum%10) % 10; int(code[12]-'0') != check {
+		return "", fmt.Errorf("'%v' check digit must be %d", code, check)
+	}
+	return code, nil
+}
+
+// makeISRCs returns n ISRCs incrementing designation code of base.
+func makeISRCs(base string, n int) (isrc []string, err error) {
+	if !isrcRe.MatchString(base) {
+		return nil, fmt.Errorf("'%v' is not ISRC", base)
+	}
+	first, _ := strconv.Atoi(base[7:])
+	if first+n-1 > 99999 {
+		return nil, fmt.Errorf("designation code overflow")
+	}
+	for i := range n {
+		isrc = append(isrc, fmt.Sprintf("%v%05d", base[:7], first+i))
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestISRC(t *testing.T) {
+	for s, want := range map[string]bool{
+		"USRC17607839":    true,
+		"GBAYE0601498":    true,
+		"FR6V82300123":    true,
+		"usrc17607839":    false,
+		"US-RC1-76-07839": false,
+		"USRC1760783":     false,
+		"USRC176078390":   false,
+		"1SRC17607839":    false,
+		"USRC1760783X":    false,
+	} {
+		if got := isrcRe.MatchString(s); got != want {
+			t.Errorf("ISRC %q valid = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestMakeISRCs(t *testing.T) {
+	isrc, err := makeISRCs("USRC17607839", 3)
+	want := []string{"USRC17607839", "USRC17607840", "USRC17607841"}
+	if err != nil || !slices.Equal(isrc, want) {
+		t.Errorf("makeISRCs = %v, %v, want %v", isrc, err, want)
+	}
+	if isrc, err = makeISRCs("USRC17699998", 2); err != nil || isrc[1] != "USRC17699999" {
+		t.Errorf("makeISRCs up to 99999 = %v, %v", isrc, err)
+	}
+	if _, err = makeISRCs("USRC17699999", 2); err == nil {
+		t.Error("makeISRCs accepted designation code overflow")
+	}
+	if _, err = makeISRCs("USRC1760783", 1); err == nil {
+		t.Error("makeISRCs accepted wrong ISRC")
+	}
+}
+
+func TestNormalizeCatalog(t *testing.T) {
+	for _, c := range []struct {
+		code, want string
+	}{
+		{"4006381333931", "4006381333931"},
+		{"9780306406157", "9780306406157"},
+		{"036000291452", "0036000291452"},
+		{"0000000000000", "0000000000000"},
+		{"4006381333932", ""},
+		{"036000291453", ""},
+		{"400638133393", ""},
+		{"40063813339310", ""},
+		{"400638133393X", ""},
+	} {
+		got, err := normalizeCatalog(c.code)
+		if got != c.want || (err == nil) != (c.want != "") {
+			t.Errorf("normalizeCatalog(%q) = %q, %v, want %q", c.code, got, err, c.want)
+		}
+	}
+}
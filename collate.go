@@ -0,0 +1,125 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// collateTailoring lists letters sorted after z in some languages, and
+// Spanish ñ sorted after n. Other languages use the common order: letters
+// with diacritics sort with their base letters, case and accents only
+// break ties.
+var collateTailoring = map[string][]string{
+	"da": {"z", "æ", "ø", "å"},
+	"es": {"n", "ñ"},
+	"fi": {"z", "å", "ä", "ö"},
+	"nb": {"z", "æ", "ø", "å"},
+	"nn": {"z", "æ", "ø", "å"},
+	"no": {"z", "æ", "ø", "å"},
+	"sv": {"z", "å", "ä", "ö"},
+}
+
+// collator compares strings in three levels: base letters, accents, case.
+// Scripts without decompositions, like CJK, sort by code point.
+type collator struct {
+	tailor map[rune]int // primary weights of tailored letters
+}
+
+// newCollator returns collator for locale like "de", "sv_SE" or "ru-RU".
+// C and POSIX locales give nil collator meaning byte order.
+func newCollator(locale string) (c *collator, ok bool) {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-."); i >= 0 {
+		lang = lang[:i]
+	}
+	switch {
+	case lang == "c" || lang == "posix":
+		return nil, true
+	case lang != "root" && (len(lang) < 2 || len(lang) > 3 ||
+		strings.Trim(lang, "abcdefghijklmnopqrstuvwxyz") != ""):
+		return nil, false
+	}
+	c = &collator{tailor: make(map[rune]int)}
+	if t, ok := collateTailoring[lang]; ok {
+		base := []rune(t[0])[0]
+		for i, s := range t[1:] {
+			c.tailor[[]rune(s)[0]] = c.primary(base) + i + 1
+		}
+	}
+	return c, true
+}
+
+func (c *collator) primary(r rune) int {
+	return int(unicode.ToLower(r)) * 4
+}
+
+// keys returns collation weights of s: base letters, accents and case.
+func (c *collator) keys(s string) (prim, sec, ter []int) {
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+		if w, ok := c.tailor[lower]; ok {
+			prim, sec = append(prim, w), append(sec, 0)
+			ter = append(ter, boolInt(lower != r))
+			continue
+		}
+		d, ok := unicodeDecomp[r]
+		if !ok {
+			d = string(r)
+		}
+		for i, r := range d {
+			if i > 0 && unicode.Is(unicode.Mn, r) {
+				sec[len(sec)-1] = sec[len(sec)-1]<<16 | int(r)
+				continue
+			}
+			prim, sec = append(prim, c.primary(r)), append(sec, 0)
+			ter = append(ter, boolInt(unicode.IsUpper(r)))
+		}
+	}
+	return
+}
+
+func (c *collator) compare(a, b string) int {
+	pa, sa, ta := c.keys(a)
+	pb, sb, tb := c.keys(b)
+	return cmp.Or(slices.Compare(pa, pb), slices.Compare(sa, sb),
+		slices.Compare(ta, tb), strings.Compare(a, b))
+}
+
+// sortTracks sorts track file paths with -collate locale, then puts them in
+// -order file order and reverses with -reverse.
+func (opt *cueOptions) sortTracks(trackFilePath []string) {
+	if opt.collate != "" {
+		c, ok := newCollator(opt.collate)
+		if !ok {
+			panic("Wrong locale: " + opt.collate)
+		}
+		if c == nil {
+			slices.Sort(trackFilePath)
+		} else {
+			slices.SortStableFunc(trackFilePath, func(a, b string) int {
+				return c.compare(a, b)
+			})
+		}
+	}
+	if opt.order != "" {
+		name, err := readOrderFile(opt.order)
+		if err == nil {
+			err = orderTracks(trackFilePath, name)
+		}
+		if err != nil {
+			panic("Wrong track order: " + err.Error())
+		}
+	}
+	if opt.reverse {
+		slices.Reverse(trackFilePath)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+func init() {
+	commandTab["completion"] = doCmdCompletion
+}
+
+// completionFlags is the first flag set made by a command run to list its
+// options for completion, nil when not listing.
+var completionFlags **flag.FlagSet
+
+// newFlagSet returns command option set. Commands make it before doing
+// anything, so completion can list their options.
+func newFlagSet() *flag.FlagSet {
+	fl := flag.NewFlagSet("", flag.ContinueOnError)
+	// parseFlags reports errors and prints help
+	fl.SetOutput(io.Discard)
+	fl.Usage = func() {}
+	if completionFlags != nil && *completionFlags == nil {
+		*completionFlags = fl
+	}
+	return fl
+}
+
+// commandFlags returns options of command, made by running it with -h.
+func commandFlags(name string) (fl *flag.FlagSet) {
+	completionFlags = &fl
+	defer func() {
+		completionFlags = nil
+		switch r := recover(); r.(type) {
+		case nil, string, helpShown:
+		default:
+			panic(r)
+		}
+	}()
+	commandTab[name]([]string{name, "-h"})
+	return
+}
+
+// completionOption is option name and whether it takes a value.
+type completionOption struct {
+	name  string
+	usage string
+	value bool
+}
+
+func completionOptions(fl *flag.FlagSet) (opt []completionOption) {
+	if fl == nil {
+		return
+	}
+	fl.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		opt = append(opt, completionOption{"-" + f.Name, f.Usage, !ok || !b.IsBoolFlag()})
+	})
+	return
+}
+
+func optionNames(opt []completionOption) string {
+	var name []string
+
+	for _, o := range opt {
+		name = append(name, o.name)
+	}
+	return strings.Join(name, " ")
+}
+
+// doCmdCompletion writes shell completion script of commands and their
+// options.
+func doCmdCompletion(arg []string) {
+	var err error
+
+	if len(arg) != 2 {
+		panic("Expected shell: bash, zsh, fish or powershell")
+	}
+	global := completionOptions(globalFlagSet())
+	cmd := slices.Sorted(maps.Keys(commandTab))
+	cmd = slices.DeleteFunc(cmd, func(c string) bool { return strings.HasPrefix(c, "-") })
+	opt := make(map[string][]completionOption)
+	for _, c := range cmd {
+		if c != arg[0] {
+			opt[c] = completionOptions(commandFlags(c))
+		}
+	}
+
+	var b strings.Builder
+	switch arg[1] {
+	case "bash":
+		writeBashCompletion(&b, global, cmd, opt)
+	case "zsh":
+		b.WriteString("#compdef cue-maker\nautoload -U bashcompinit && bashcompinit\n")
+		writeBashCompletion(&b, global, cmd, opt)
+	case "fish":
+		writeFishCompletion(&b, global, cmd, opt)
+	case "powershell":
+		writePowerShellCompletion(&b, global, cmd, opt)
+	default:
+		panic("Wrong shell: " + arg[1])
+	}
+	_, err = io.WriteString(os.Stdout, b.String())
+	panicIfError(err)
+}
+
+func writeBashCompletion(b *strings.Builder, global []completionOption, cmd []string,
+	opt map[string][]completionOption) {
+	var value []string
+
+	for _, o := range global {
+		if o.value {
+			value = append(value, o.name)
+		}
+	}
+	fmt.Fprintf(b, `_cue_maker() {
+	local cur=${COMP_WORDS[COMP_CWORD]} cmd= i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		%v) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	case $cmd in
+	"") COMPREPLY=($(compgen -W "%v %v" -- "$cur")) ;;
+`, strings.Join(value, "|"), optionNames(global), strings.Join(cmd, " "))
+	for _, c := range cmd {
+		if len(opt[c]) > 0 {
+			fmt.Fprintf(b, "\t%v) [[ $cur == -* ]] && COMPREPLY=($(compgen -W \"%v\" -- \"$cur\")) ;;\n",
+				c, optionNames(opt[c]))
+		}
+	}
+	b.WriteString("\tesac\n}\ncomplete -o default -F _cue_maker cue-maker\n")
+}
+
+func writeFishCompletion(b *strings.Builder, global []completionOption, cmd []string,
+	opt map[string][]completionOption) {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	}
+	option := func(cond string, o completionOption) {
+		fmt.Fprintf(b, "complete -c cue-maker -n %v -o %v -d %v", quote(cond),
+			strings.TrimPrefix(o.name, "-"), quote(o.usage))
+		if o.value {
+			b.WriteString(" -r")
+		}
+		b.WriteString("\n")
+	}
+
+	for _, o := range global {
+		option("__fish_use_subcommand", o)
+	}
+	fmt.Fprintf(b, "complete -c cue-maker -f -n __fish_use_subcommand -a %v\n",
+		quote(strings.Join(cmd, " ")))
+	for _, c := range cmd {
+		for _, o := range opt[c] {
+			option("__fish_seen_subcommand_from "+c, o)
+		}
+	}
+}
+
+func writePowerShellCompletion(b *strings.Builder, global []completionOption, cmd []string,
+	opt map[string][]completionOption) {
+	list := func(o []completionOption) string {
+		var name []string
+		for _, o := range o {
+			name = append(name, "'"+o.name+"'")
+		}
+		return "@(" + strings.Join(name, ", ") + ")"
+	}
+
+	b.WriteString("Register-ArgumentCompleter -Native -CommandName cue-maker -ScriptBlock {\n" +
+		"\tparam($wordToComplete, $commandAst, $cursorPosition)\n\t$options = @{\n")
+	for _, c := range cmd {
+		fmt.Fprintf(b, "\t\t'%v' = %v\n", c, list(opt[c]))
+	}
+	fmt.Fprintf(b, "\t}\n\t$global = %v\n", list(global))
+	b.WriteString(`	$cmd = $commandAst.CommandElements | Select-Object -Skip 1 |
+		ForEach-Object { $_.ToString() } | Where-Object { $options.ContainsKey($_) } |
+		Select-Object -First 1
+	if ($cmd) {
+		$candidates = $options[$cmd]
+	} else {
+		$candidates = $global + $options.Keys
+	}
+	$candidates | Where-Object { $_ -like "$wordToComplete*" } | Sort-Object | ForEach-Object {
+		[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+	}
+}
+`)
+}
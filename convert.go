@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// doCmdConvert passes parsed cue as JSON to an external converter on stdin.
+// Converter 'name' is looked up as 'cue-maker-name' first.
+func doCmdConvert(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		outFilePath  string
+		via          string
+		filter       trackFilter
+		cueRd        io.ReadCloser
+		outWr        io.WriteCloser
+		js           []byte
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&outFilePath, "o", "", "output file path")
+	fl.StringVar(&via, "via", "", "external converter executable")
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	if via == "" {
+		panic("No converter, use -via")
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	js, err = cueJSON(cueRd, cueAudioFile, &filter)
+	panicIfError(err)
+
+	outWr = createOutput(outFilePath)
+	defer outWr.Close()
+
+	path, err := exec.LookPath("cue-maker-" + via)
+	if err != nil {
+		if path, err = exec.LookPath(via); err != nil {
+			panic("Converter not found: " + via)
+		}
+	}
+	t := time.Now()
+	stdout, stderr, err := runner.Run(context.Background(), bytes.NewReader(js),
+		path, fl.Args()...)
+	addCommandTiming(path, t)
+	logLines(string(stderr))
+	if err != nil {
+		panic("Converter '" + via + "': " + err.Error())
+	}
+	_, err = outWr.Write(stdout)
+	panicIfError(err)
+}
+
+// cueJSON returns JSON passed to converters of tracks of cue audio file
+// selected by filter.
+func cueJSON(cue io.Reader, cueAudioFile int, filter *trackFilter) ([]byte, error) {
+	sheet := parseCue(cue, cueAudioFile)
+	sheet.label = filter.apply(sheet.label)
+	return json.Marshal(newJSONCueSheet(sheet))
+}
@@ -2,34 +2,59 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"cmp"
+	"context"
+	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
 )
 
-const usage = `cue-maker command [args]
-   cue      [-o cue_file -denum -num start -shift sec -shift-f file] tracks...
-   label    [-i cue_file -a audio_file_index -o label_file
-             -num start -num-digits digits]
-   sec2cue  seconds...
-   cue2sec  cue_times...
-   -h`
-
 var commandTab = map[string]func([]string){
-	"cue":     doCmdMakeCue,
-	"label":   doCmdMakeLabel,
-	"sec2cue": doCmdSecToCueTime,
-	"cue2sec": doCmdCueTimeToSec,
-	"-h":      doCmdHelp,
+	"cue":          doCmdMakeCue,
+	"all":          doCmdMakeAll,
+	"label":        doCmdMakeLabel,
+	"label2cue":    doCmdLabelToCue,
+	"simple2cue":   doCmdSimpleToCue,
+	"len":          doCmdTrackLength,
+	"verify-times": doCmdVerifyTimes,
+	"verify-merge": doCmdVerifyMerge,
+	"convert":      doCmdConvert,
+	"check":        doCmdCheck,
+	"merge-titles": doCmdMergeTitles,
+	"accuraterip":  doCmdAccurateRip,
+	"burn":         doCmdBurn,
+	"cdtext":       doCmdMakeCDText,
+	"tagfiles":     doCmdTagFiles,
+	"audiobook":    doCmdMakeAudiobook,
+	"probe":        doCmdProbe,
+	"discogs":      doCmdDiscogs,
+	"id3chap":      doCmdID3Chapters,
+	"vorbischap":   doCmdVorbisChapters,
+	"split":        doCmdSplit,
+	"points":       doCmdPoints,
+	"query":        doCmdQuery,
+	"mixxx":        doCmdMixxx,
+	"sec2cue":      doCmdSecToCueTime,
+	"cue2sec":      doCmdCueTimeToSec,
+	"timecalc":     doCmdTimeCalc,
+	"set":          doCmdSet,
+	"retime":       doCmdRetime,
+	"cache":        doCmdCache,
+	"selftest":     doCmdSelftest,
+	"version":      doCmdVersion,
 }
 
 var (
@@ -41,230 +66,1641 @@ const (
 	uSecInSecond     = 1000000
 	defaultNumStart  = 1
 	defaultNumDigits = 4
+	maxCueTracks     = 99
+
+	defaultMaxDuration = 1000 * 3600 * uSecInSecond
+)
+
+// maxDuration is the longest time accepted from arguments, cues and probes,
+// set by the global -max-duration option.
+var maxDuration Timestamp = defaultMaxDuration
+
+// FILE path modes.
+const (
+	filePathBase = "basename"
+	filePathRel  = "relative"
+	filePathAbs  = "absolute"
 )
 
+type cueOptions struct {
+	titleOptions
+	title     string
+	discTitle string
+	performer string
+	date      string
+	fileName  string
+	cover     string
+	filePath  string         // FILE path mode
+	cueDir    string         // output cue directory
+	sideMark  map[int]string // vinyl side of the first side tracks
+	db        string
+	numStart  int
+	precise   bool
+	translit  bool
+	truncate  bool
+	overlap   Timestamp
+	isrcBase  string
+	catalog   string
+	flags     stringList
+	trkFlags  map[int][]string
+	collate   string
+	playlist  string
+	nfo       string
+	skipErrs  bool
+	redBook   bool
+	order     string
+	reverse   bool
+
+	// fingerprint looks up untitled tracks in AcoustID, trackPerformer
+	// holds performers found, by track index
+	fingerprint    bool
+	trackPerformer []string
+
+	// dedupeBoundary leaves out tracks starting at the next track index
+	dedupeBoundary bool
+
+	// subindex holds INDEX 02 and later times by track index
+	subindex [][]Timestamp
+
+	inferMeta   bool
+	metaPattern string
+	metaRe      *regexp.Regexp
+
+	shiftTime   string
+	shiftFile   stringList
+	overlapTime string
+}
+
+func (opt *cueOptions) addFlags(fl *flag.FlagSet) {
+	opt.titleOptions.addFlags(fl)
+	fl.StringVar(&opt.discTitle, "title", "", "disc title, default is output file name")
+	fl.StringVar(&opt.discTitle, "album", "", "alias for -title")
+	fl.StringVar(&opt.performer, "performer", "", "disc performer")
+	fl.StringVar(&opt.date, "date", "", "disc release date, written as REM DATE")
+	fl.BoolVar(&opt.inferMeta, "infer-meta", false,
+		"take title, performer and date from track directory path")
+	fl.StringVar(&opt.metaPattern, "meta-pattern", defaultMetaPattern,
+		"-infer-meta directory pattern with {artist}, {year} and {album}")
+	fl.StringVar(&opt.fileName, "file", "", "FILE name, default is title with .mka")
+	fl.StringVar(&opt.cover, "cover", "", "cover art image file, written as REM COVER")
+	fl.StringVar(&opt.filePath, "file-path", filePathBase,
+		"FILE path: basename, relative to cue or absolute")
+	fl.IntVar(&opt.numStart, "num", 1, "cue tracks start number")
+	fl.StringVar(&opt.shiftTime, "shift", "", "shift cue start time in seconds or mm:ss:ff")
+	fl.Var(&opt.shiftFile, "shift-f", "shift cue start time by file duration, may be repeated")
+	fl.StringVar(&opt.overlapTime, "overlap", "", "crossfade duration between tracks in seconds")
+	fl.BoolVar(&opt.precise, "precise", false, "add REM INDEX01-SEC lines with microsecond times")
+	fl.BoolVar(&opt.translit, "translit", false, "transliterate titles to Latin")
+	fl.BoolVar(&opt.truncate, "truncate", false, "truncate titles to CD-TEXT limit")
+	fl.StringVar(&opt.catalog, "catalog", "", "disc UPC/EAN barcode")
+	fl.Var(&opt.flags, "flags", "track FLAGS as [track:]PRE,DCP,4CH,SCMS, may be repeated")
+	fl.StringVar(&opt.isrcBase, "isrc-base", "", "first track ISRC, incremented for next tracks")
+	fl.StringVar(&opt.playlist, "from-playlist", "", "take tracks from M3U or PLS playlist")
+	fl.StringVar(&opt.nfo, "nfo", "", "take titles from NFO tracklist, checking track lengths")
+	fl.StringVar(&opt.db, "db", "", "record cue in SQLite catalog database")
+	fl.StringVar(&opt.collate, "collate", "", "sort tracks by name in locale order, like de or sv_SE")
+	fl.StringVar(&opt.order, "order", "", "track order file with a file name per line")
+	fl.BoolVar(&opt.reverse, "reverse", false, "reverse track order")
+	fl.BoolVar(&opt.skipErrs, "skip-errors", false, "leave out unreadable tracks and fail at the end")
+	fl.BoolVar(&opt.fingerprint, "fingerprint", false,
+		"fill in untitled tracks from AcoustID by fpcalc fingerprints, key in "+acoustIDKeyEnv)
+	fl.BoolVar(&opt.redBook, "validate-audio", false,
+		"warn about tracks not 44.1 kHz 16-bit stereo, rejected with -strict")
+	fl.BoolVar(&opt.dedupeBoundary, "dedupe-boundary", false,
+		"merge tracks starting at the same time into the later one")
+	addProbeFlags(fl)
+}
+
+// setTitle sets disc title from -title option or default title.
+func (opt *cueOptions) setTitle(def string) {
+	opt.title = cmp.Or(opt.discTitle, def)
+}
+
+// cueFileName returns FILE name: -file option, with .mka added if it has no
+// extension, or disc title. The path is written as -file-path requests.
+func (opt *cueOptions) cueFileName() (name string) {
+	switch {
+	case opt.fileName == "":
+		name = opt.title + ".mka"
+	case filepath.Ext(opt.fileName) == "":
+		name = opt.fileName + ".mka"
+	default:
+		name = opt.fileName
+	}
+	return opt.cuePath(name)
+}
+
+// cuePath returns path of a file referenced by cue in -file-path mode.
+func (opt *cueOptions) cuePath(name string) string {
+	switch opt.filePath {
+	case filePathRel:
+		if rel, err := relPath(opt.cueDir, name); err == nil {
+			return rel
+		}
+	case filePathAbs:
+		if abs, err := filepath.Abs(name); err == nil {
+			return abs
+		}
+	}
+	return filepath.Base(name)
+}
+
+// readableTracks probes tracks with -skip-errors and returns the readable
+// ones and the number of the left out ones, which are warned about.
+func (opt *cueOptions) readableTracks(trackFilePath []string) (ok []string, skipped int) {
+	if !opt.skipErrs {
+		return trackFilePath, 0
+	}
+	for _, path := range trackFilePath {
+		if _, err := getMediaDuration(path); err != nil {
+			logFileWarning(path, 0, fmt.Sprintf("skipped '%v': %v", path, err))
+			skipped++
+		} else {
+			ok = append(ok, path)
+		}
+	}
+	if len(ok) == 0 {
+		panic("No readable track(s)")
+	}
+	return
+}
+
+// validateAudio checks tracks are CD audio with -validate-audio. Cue times of
+// tracks with other sample rates do not match the resampled concat.
+func (opt *cueOptions) validateAudio(trackFilePath []string) {
+	if !opt.redBook {
+		return
+	}
+	for _, path := range trackFilePath {
+		info, err := getMediaInfo(path)
+		panicIfError(err)
+		if info.SampleRate == 0 && useNativeProbe() {
+			panic("Option -validate-audio without ffprobe checks only PCM WAV and FLAC tracks: " +
+				path)
+		}
+		p := redBookProblems(info)
+		if len(p) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("'%v' is not CD audio: %v", path, strings.Join(p, ", "))
+		if strictCue {
+			panic("Track " + msg)
+		}
+		logFileWarning(path, 0, msg)
+	}
+}
+
+// reportSkipped fails the command after its outputs are written if tracks
+// were left out.
+func reportSkipped(skipped int) {
+	if skipped > 0 {
+		outputFailure = fmt.Sprintf("%d unreadable track(s) skipped", skipped)
+	}
+}
+
+// trackFlags returns FLAGS of cue track number n without duplicates.
+func (opt *cueOptions) trackFlags(n int) (flags []string) {
+	for _, f := range append(opt.trkFlags[0], opt.trkFlags[n]...) {
+		if !slices.Contains(flags, f) {
+			flags = append(flags, f)
+		}
+	}
+	return
+}
+
+// check validates options before any probing.
+func (opt *cueOptions) check() {
+	var err error
+
+	if opt.catalog != "" {
+		if opt.catalog, err = normalizeCatalog(opt.catalog); err != nil {
+			panic("Wrong catalog: " + err.Error())
+		}
+	}
+	if opt.isrcBase != "" && !isrcRe.MatchString(opt.isrcBase) {
+		panic("Wrong ISRC base: " + opt.isrcBase)
+	}
+	if opt.trkFlags, err = parseTrackFlags(opt.flags); err != nil {
+		panic("Wrong flags: " + err.Error())
+	}
+	switch opt.filePath {
+	case filePathBase, filePathRel, filePathAbs:
+	default:
+		panic("Wrong FILE path mode: " + opt.filePath)
+	}
+	if opt.inferMeta {
+		if opt.metaRe, err = metaPatternRe(opt.metaPattern); err != nil {
+			panic("Wrong metadata pattern: " + err.Error())
+		}
+	}
+}
+
+// shiftStart parses time options and returns the first track start time.
+func (opt *cueOptions) shiftStart() (shiftStart Timestamp) {
+	var err error
+
+	if opt.shiftTime != "" {
+		shiftStart, err = parseTime(opt.shiftTime)
+		if err != nil {
+			panic("Wrong shift time: " + err.Error())
+		}
+	}
+	if opt.overlapTime != "" {
+		opt.overlap, err = parseTime(opt.overlapTime)
+		if err != nil {
+			panic("Wrong overlap time: " + err.Error())
+		}
+	}
+	for _, path := range opt.shiftFile {
+		d, err := getMediaDuration(path)
+		panicIfError(err)
+		shiftStart += d
+	}
+	return
+}
+
+// stringList is a flag value collecting repeated flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 type cueLabel struct {
-	start int64
-	title string
+	num       int // track number in audio file starting at 1
+	start     Timestamp
+	index00   Timestamp   // -1 if no INDEX 00
+	subindex  []Timestamp // INDEX 02 and later, like movements within a track
+	title     string
+	performer string
+	isrc      string
+	flags     []string
+}
+
+type cueSheet struct {
+	catalog   string
+	title     string
+	performer string
+	cover     string
+	file      string // FILE name of the parsed audio file
+	label     []cueLabel
+	warning   []cueWarning
+}
+
+// cueWarning is a problem in cue line tolerated by parser. Parsed sheets keep
+// their warnings, and commands log them or pass them on in JSON output.
+type cueWarning struct {
+	file string
+	line int
+	msg  string
+}
+
+func (w cueWarning) String() string {
+	return fmt.Sprintf("%v:%d: %v", w.file, w.line, w.msg)
+}
+
+func main() {
+	var (
+		cmd func([]string)
+		arg []string
+	)
+
+	defer checkPanic()
+
+	timingStats.start = time.Now()
+	cmd, arg = parseArgv()
+	runCmd(cmd, arg)
+	if timings {
+		logTimings()
+	}
+}
+
+// outputFailure is error reported after command outputs are written.
+var outputFailure string
+
+// runCmd runs command. Its output files replace existing ones only if it
+// succeeds, so a failure does not leave truncated outputs behind.
+func runCmd(cmd func([]string), arg []string) {
+	prev := logCommand
+	logCommand.name, logCommand.start = arg[0], time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			discardOutputs()
+			saveDurationCache()
+			panic(r)
+		}
+	}()
+	cmd(arg)
+	commitOutputs()
+	saveDurationCache()
+	if msg := outputFailure; msg != "" {
+		outputFailure = ""
+		panic(msg)
+	}
+	logCommandDone()
+	logCommand = prev
+}
+
+// globalFlagSet returns global options given before command.
+func globalFlagSet() *flag.FlagSet {
+	fl := newFlagSet()
+	fl.StringVar(&outputFormat, "format", outputText, "output format: text or json")
+	fl.BoolVar(&deterministic, "deterministic", false,
+		"byte-identical output for identical inputs")
+	fl.StringVar(&logFormat, "log-format", logText, "diagnostics format: text or json lines")
+	fl.BoolVar(&timings, "timings", false, "print time spent probing, in commands and writing")
+	fl.BoolVar(&strictCue, "strict", false, "reject malformed cue instead of warning")
+	fl.BoolVar(&forceOverwrite, "f", false, "overwrite existing output files")
+	fl.BoolVar(&noOverwrite, "n", false, "never overwrite existing output files")
+	fl.StringVar(&decimalSep, "decimal", decimalAuto,
+		"decimal separator of input times: auto, point or comma")
+	fl.StringVar(&namePolicy, "names", namesWindows,
+		"output file names made from titles: windows, posix or conservative")
+	fl.Func("max-duration", fmt.Sprintf("longest accepted time in seconds (default %d)",
+		defaultMaxDuration/uSecInSecond), func(v string) error {
+		f, err := parseDecimal(v)
+		if err != nil || !(f > 0 && f < math.MaxInt64/uSecInSecond/cdFramesPerSecond) {
+			return errors.New("must be positive number of seconds")
+		}
+		maxDuration = Timestamp(f * uSecInSecond)
+		return nil
+	})
+	return fl
+}
+
+func parseArgv() (cmd func([]string), arg []string) {
+	var ok bool
+
+	arg = os.Args[1:]
+	if len(arg) > 0 && arg[0] != "-h" {
+		fl := globalFlagSet()
+		parseFlags(fl, arg)
+		if outputFormat != outputText && outputFormat != outputJSON {
+			panic("Wrong output format: " + outputFormat)
+		}
+		if logFormat != logText && logFormat != logJSON {
+			panic("Wrong log format: " + logFormat)
+		}
+		if forceOverwrite && noOverwrite {
+			panic("Options -f and -n are exclusive")
+		}
+		switch decimalSep {
+		case decimalAuto, decimalPoint, decimalComma:
+		default:
+			panic("Wrong decimal separator: " + decimalSep)
+		}
+		switch namePolicy {
+		case namesWindows, namesPOSIX, namesConservative:
+		default:
+			panic("Wrong file name policy: " + namePolicy)
+		}
+		arg = fl.Args()
+	}
+	if len(arg) < 1 {
+		panic("no command to execute")
+	}
+
+	// command -h works also for commands without options
+	if len(arg) == 2 && (arg[1] == "-h" || arg[1] == "-help" || arg[1] == "--help") {
+		arg = []string{"help", arg[0]}
+	}
+	cmd, ok = commandTab[arg[0]]
+	if !ok {
+		panic(unknownCommand(arg[0]))
+	}
+	return
+}
+
+func doCmdMakeCue(arg []string) {
+	var (
+		cueFilePath   string
+		trackFilePath []string
+		cueWr         io.WriteCloser
+		opt           cueOptions
+		start         []Timestamp
+		end           Timestamp
+		title         []string
+		rollover      bool
+		expand        bool
+		chapterIndex  bool
+		sidesSpec     string
+		sideLen       string
+		firstSide     string
+		splitSides    bool
+		sides         vinylSides
+		sideFirst     []int
+		chapterPath   string
+		skipped       int
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "o", "", "output cue file path")
+	fl.StringVar(&chapterPath, "chapters", "", "also write ffmpeg metadata chapters file, e.g. for merged video")
+	opt.addFlags(fl)
+	fl.BoolVar(&rollover, "rollover", false, "write tracks past 99 to additional cue files")
+	fl.BoolVar(&expand, "expand-chapters", false, "make a cue track of every chapter in tracks")
+	fl.BoolVar(&chapterIndex, "chapter-index", false, "write chapters in tracks as INDEX 02 and later")
+	fl.StringVar(&sidesSpec, "sides", "", "vinyl tracks per side, like 5,4")
+	fl.StringVar(&sideLen, "side-len", "", "recorded side lengths, like 22:31.5,21:05")
+	fl.StringVar(&firstSide, "side", "A", "first side letter")
+	fl.BoolVar(&splitSides, "split-sides", false, "write a cue file per side")
+	parseFlags(fl, arg[1:])
+	trackFilePath = opt.tracks(fl.Args())
+	if len(trackFilePath) == 0 {
+		panic("No input track(s)")
+	}
+	if opt.numStart < 1 || opt.numStart > maxCueTracks {
+		panic(fmt.Sprintf("Cue tracks number must be in range 1-%d", maxCueTracks))
+	}
+	opt.check()
+	opt.sortTracks(trackFilePath)
+	trackFilePath, skipped = opt.readableTracks(trackFilePath)
+	opt.validateAudio(trackFilePath)
+	if opt.numStart+len(trackFilePath)-1 > maxCueTracks {
+		if !rollover {
+			panic(fmt.Sprintf("Cue sheet supports at most %d tracks, use -rollover",
+				maxCueTracks))
+		}
+		if isStdio(cueFilePath) {
+			panic("Option -rollover requires output cue file")
+		}
+	}
+	if expand && opt.fingerprint {
+		panic("Options -fingerprint and -expand-chapters cannot be used together")
+	}
+	if expand && chapterIndex {
+		panic("Options -chapter-index and -expand-chapters cannot be used together")
+	}
+	if sidesSpec != "" {
+		if expand {
+			panic("Options -sides and -expand-chapters cannot be used together")
+		}
+		if opt.dedupeBoundary {
+			panic("Options -sides and -dedupe-boundary cannot be used together")
+		}
+		if sides, err = parseSides(sidesSpec, sideLen, firstSide); err == nil {
+			sideFirst, err = sides.firstTracks(len(trackFilePath))
+		}
+		if err != nil {
+			panic("Wrong sides: " + err.Error())
+		}
+		if splitSides && isStdio(cueFilePath) {
+			panic("Option -split-sides requires output cue file")
+		}
+	} else if splitSides || sideLen != "" {
+		panic("Side options require -sides")
+	}
+
+	if !splitSides {
+		cueWr = createOutput(cueFilePath)
+		defer cueWr.Close()
+	}
+	opt.cueDir = fileDir(cueFilePath)
+	opt.inferMetadata(trackFilePath)
+	if isStdio(cueFilePath) {
+		opt.setTitle("FILE")
+	} else {
+		opt.setTitle(fileTitle(strings.TrimSuffix(cueFilePath, gzipExt)))
+	}
+
+	start, end = trackStartTimes(opt.shiftStart(), opt.overlap, trackFilePath,
+		opt.db != "" || chapterPath != "" || opt.nfo != "")
+	trackFilePath, start = opt.dedupeBoundaries(trackFilePath, start)
+	dur := trackDurations(start, end, opt.overlap)
+	title = opt.trackTitles(trackFilePath)
+	opt.applyNFO(title, dur)
+	opt.applyFingerprints(trackFilePath, title)
+	if sidesSpec != "" {
+		if err = sides.applyLengths(start, sideFirst); err != nil {
+			panic("Wrong side lengths: " + err.Error())
+		}
+		opt.sideMark = make(map[int]string)
+		for k, i := range sideFirst {
+			opt.sideMark[opt.numStart+i] = sides.name(k)
+		}
+	}
+	if expand {
+		if start, title, err = expandChapters(trackFilePath, start, title); err != nil {
+			panic(err.Error())
+		}
+		if opt.numStart+len(start)-1 > maxCueTracks && (!rollover || isStdio(cueFilePath)) {
+			panic(fmt.Sprintf("Cue sheet with chapters has %d tracks, use -rollover "+
+				"with output cue file", len(start)))
+		}
+	}
+	if chapterIndex {
+		if opt.subindex, err = chapterSubindexes(trackFilePath, start); err != nil {
+			panic(err.Error())
+		}
+	}
+	opt.dedupTitles(title)
+	if chapterPath != "" {
+		chapWr := createOutput(chapterPath)
+		panicIfError(writeFFMetadata(chapWr, opt.title, makeChapters(start, end, title)))
+		panicIfError(chapWr.Close())
+	}
+	part := opt.rolloverParts(cueFilePath, len(start))
+	if splitSides {
+		part = sides.parts(cueFilePath, &opt, sideFirst, start)
+	}
+	if opt.db != "" {
+		afterCommit(func() error { return opt.recordCue(part, trackFilePath, dur, title, start) })
+	}
+	if splitSides {
+		writeSideCues(&opt, &sides, part, title, start)
+		reportSkipped(skipped)
+		return
+	}
+	for k, p := range part {
+		if k > 0 {
+			cueWr = createOutput(p.path)
+			defer cueWr.Close()
+		}
+		writeCue(cueWr, &opt, title[p.lo:p.hi], start[p.lo:p.hi], p.lo)
+		if opt.trackPerformer != nil {
+			opt.trackPerformer = opt.trackPerformer[p.hi-p.lo:]
+		}
+		if opt.subindex != nil {
+			opt.subindex = opt.subindex[p.hi-p.lo:]
+		}
+	}
+	reportSkipped(skipped)
+}
+
+// cuePart is cue file of tracks lo to hi, like a rollover part or a side.
+type cuePart struct {
+	path   string
+	title  string
+	lo, hi int
+	num    int       // TRACK number of track lo
+	shift  Timestamp // subtracted from track start times
+}
+
+// rolloverParts returns cue file of all tracks, or with -rollover the cue
+// files of up to 99 tracks they are written to, numbered from 01 after the
+// first one.
+func (opt *cueOptions) rolloverParts(cueFilePath string, tracks int) (part []cuePart) {
+	for lo := 0; ; {
+		hi := min(tracks, lo+maxCueTracks-(opt.numStart+lo-1)%maxCueTracks)
+		p := cuePart{path: cueFilePath, title: opt.title, lo: lo, hi: hi,
+			num: (opt.numStart+lo-1)%maxCueTracks + 1}
+		if lo > 0 {
+			p.path = cuePartPath(cueFilePath, strconv.Itoa(len(part)+1))
+		}
+		if part = append(part, p); hi >= tracks {
+			return
+		}
+		lo = hi
+	}
+}
+
+func doCmdMakeLabel(arg []string) {
+	var (
+		cueFilePath         string
+		cueAudioFile        string
+		cueFileName         string
+		labelFilePath       string
+		numStart, numDigits int
+		index               string
+		titleFormat         string
+		numFmt              string
+		cumulative          bool
+		fileLenSpec         string
+		dedupe              bool
+		fileLen             []Timestamp
+		endLabel            bool
+		freq                bool
+		subindex            bool
+		endSpec             string
+		end                 Timestamp
+		opt                 labelOptions
+		filter              trackFilter
+		file                []int
+		tmpl                *template.Template
+		err                 error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.StringVar(&cueAudioFile, "a", "0", "input cue audio file index starting at 0 or all")
+	fl.StringVar(&cueFileName, "file-name", "", "input cue audio file name, glob or substring")
+	fl.BoolVar(&cumulative, "cumulative", false, "label all cue files as played back-to-back")
+	fl.StringVar(&fileLenSpec, "file-len", "", "cue file durations for -cumulative instead of probing")
+	fl.BoolVar(&dedupe, "dedupe-boundary", false,
+		"merge -cumulative labels starting at the same time where files join")
+	fl.StringVar(&labelFilePath, "o", "",
+		"output label file path or name template like '{{.Album}}/{{.File}}.txt'")
+	fl.BoolVar(&endLabel, "end-label", false, "append label at the end of audio")
+	fl.StringVar(&endSpec, "end", "", "audio duration for -end-label instead of probing")
+	fl.BoolVar(&freq, "freq", false, "write zero frequency range of labels for spectral selection")
+	fl.BoolVar(&subindex, "subindex", false, "add labels at INDEX 02 and later, like movements")
+	fl.IntVar(&numStart, "num", defaultNumStart, "start track number or -1")
+	fl.IntVar(&numDigits, "num-digits", defaultNumDigits, "min digits in track number")
+	fl.StringVar(&index, "index", "01", "cue index for label times: 00 or 01")
+	fl.StringVar(&numFmt, "num-format", "", "track number format like %02d. or vinyl:6,5")
+	fl.StringVar(&titleFormat, "title-format", "",
+		"label template with .Num .Title .Performer .ISRC .Start .Duration")
+	filter.addFlags(fl)
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if cumulative && cueFileName != "" {
+		panic("Options -cumulative and -file-name are exclusive")
+	}
+	if fileLenSpec != "" {
+		if !cumulative {
+			panic("Option -file-len requires -cumulative")
+		}
+		for _, v := range strings.Split(fileLenSpec, ",") {
+			d, err := parseTime(strings.TrimSpace(v))
+			if err != nil || d <= 0 {
+				panic("Wrong file length: " + v)
+			}
+			fileLen = append(fileLen, d)
+		}
+	}
+	if dedupe && !cumulative {
+		panic("Option -dedupe-boundary requires -cumulative")
+	}
+	if endSpec != "" {
+		if !endLabel {
+			panic("Option -end requires -end-label")
+		}
+		if end, err = parseTime(endSpec); err != nil || end <= 0 {
+			panic("Wrong audio duration: " + endSpec)
+		}
+	}
+	if index != "00" && index != "01" {
+		panic("Wrong cue index: " + index)
+	}
+	if cumulative {
+		// labels are numbered over all cue files, past 99 like rollover parts
+		filter.maxTrack = math.MaxInt
+	}
+	opt = labelOptions{index00: index == "00", numStart: numStart, filter: filter,
+		endLabel: endLabel, end: end, freq: freq, subindex: subindex}
+	if titleFormat != "" {
+		if opt.tmpl, err = template.New("").Parse(titleFormat); err != nil {
+			panic("Wrong title format: " + err.Error())
+		}
+	}
+	if numDigits <= 0 {
+		panic("Wrong track number digits")
+	}
+	if opt.num, err = parseNumFormat(numFmt, numDigits); err != nil {
+		panic("Wrong number format: " + err.Error())
+	}
+
+	// stdin is kept in memory only if read more than once
+	in := newRereadInput(cueFilePath, cumulative || cueFileName != "" || cueAudioFile == "all")
+	if isNameTemplate(labelFilePath) {
+		if tmpl, err = parseNameTemplate(labelFilePath); err != nil {
+			panic("Wrong output name template: " + err.Error())
+		}
+	}
+	if cumulative {
+		opt.writeCumulative(in, labelFilePath, fileLen, dedupe)
+		return
+	}
+	var fileName []string
+	switch {
+	case cueFileName != "":
+		fileName = in.cueFiles()
+		i, err := findCueFile(fileName, cueFileName)
+		if err != nil {
+			panic("Wrong cue audio file name: " + err.Error())
+		}
+		file = []int{i}
+	case cueAudioFile == "all":
+		fileName = in.cueFiles()
+		if len(fileName) > 1 && isStdio(labelFilePath) {
+			panic("Option -a all requires output label file")
+		}
+		if len(fileName) > 1 && end > 0 {
+			panic("Option -end requires one cue file")
+		}
+		for i := range fileName {
+			file = append(file, i)
+		}
+	default:
+		i, err := strconv.Atoi(cueAudioFile)
+		if err != nil {
+			panic("Wrong cue audio file index: " + cueAudioFile)
+		}
+		file = []int{i}
+	}
+
+	// labelPath returns output path of labels of cue file i named name
+	labelPath := func(i int, name string, header *cueSheet) string {
+		if tmpl != nil {
+			n := outputName{Album: header.title, Performer: header.performer,
+				Disc: strconv.Itoa(i + 1), File: fileTitle(name)}
+			path, err := n.path(tmpl)
+			if err != nil {
+				panic("Wrong output name template: " + err.Error())
+			}
+			return path
+		}
+		if len(file) > 1 {
+			return cuePartPath(labelFilePath, safeFileName(fileTitle(name)))
+		}
+		return labelFilePath
+	}
+	// output paths of several cue files are checked before any label is
+	// written, the only one is known as its header is parsed
+	path := make([]string, len(file))
+	if len(file) > 1 {
+		r := in.open()
+		header := scanCueSheet(r, file[0], func(*cueSheet, cueLabel) {})
+		r.Close()
+		for k, i := range file {
+			path[k] = labelPath(i, fileName[i], &header)
+			if j := slices.Index(path[:k], path[k]); j >= 0 && !isStdio(path[k]) {
+				panic(fmt.Sprintf("Cue files %d and %d have the same output file: %v",
+					file[j], i, path[k]))
+			}
+		}
+	}
+	for k, i := range file {
+		lw := opt.newLabelWriter(func(sheet *cueSheet) io.WriteCloser {
+			if path[k] == "" {
+				path[k] = labelPath(i, sheet.file, sheet)
+			}
+			return createOutput(path[k])
+		})
+		r := in.open()
+		sheet := scanCue(r, i, lw.put)
+		r.Close()
+		lw.finish(opt.endTime(cueFilePath, sheet.file, 0))
+	}
+}
+
+// labelOptions is how label makes labels from cue tracks.
+type labelOptions struct {
+	index00  bool
+	numStart int
+	num      numFormat
+	tmpl     *template.Template
+	filter   trackFilter
+	endLabel bool
+	end      Timestamp // audio duration for end label, probed if 0
+	freq     bool      // write spectral selection lines
+	subindex bool      // add labels at INDEX 02 and later
+}
+
+// endLabelTitle is title of label at the end of audio.
+const endLabelTitle = "END"
+
+// labelWriter writes labels of cue tracks put one by one as they are parsed,
+// so memory stays bounded for recordings with thousands of tracks. The last
+// track is held until the next one starts, for -title-format durations and
+// labels starting where the next one does.
+type labelWriter struct {
+	opt    *labelOptions
+	create func(sheet *cueSheet) io.WriteCloser
+	out    io.WriteCloser
+	w      *bufio.Writer
+	match  func(l cueLabel) bool
+	json   []cueLabel // labels of JSON output
+
+	boundary bool // check labels starting at the same time
+	merge    bool // leave out such labels
+
+	header   cueSheet
+	held     cueLabel
+	tracks   int // tracks put, less merged ones
+	merged   int
+	selected int
+}
+
+// newLabelWriter returns label writer creating output by the cue sheet header
+// as the first track is put.
+func (opt *labelOptions) newLabelWriter(create func(sheet *cueSheet) io.WriteCloser) *labelWriter {
+	return &labelWriter{opt: opt, create: create, match: opt.filter.matcher(),
+		held: cueLabel{start: -1}}
+}
+
+// put takes the next cue track.
+func (lw *labelWriter) put(sheet *cueSheet, l cueLabel) {
+	if lw.out == nil {
+		lw.header = *sheet
+		lw.out = lw.create(sheet)
+		lw.w = bufio.NewWriter(lw.out)
+	}
+	if h := lw.held; h.start >= 0 {
+		switch {
+		case !lw.boundary || h.start != l.start:
+			lw.write(h, l.start-h.start)
+		case lw.merge:
+			logMessage(fmt.Sprintf("label %d '%v' merged into label %d at %v", h.num,
+				h.title, h.num+1, h.start.String()))
+			lw.merged++
+			lw.tracks--
+		default:
+			logWarningMessage(fmt.Sprintf("labels %d and %d both start at %v, use -dedupe-boundary",
+				h.num, h.num+1, h.start.String()))
+			lw.write(h, 0)
+		}
+	}
+	lw.held = l
+	lw.tracks++
+}
+
+// write writes labels of track l lasting dur, or -1 if unknown.
+func (lw *labelWriter) write(l cueLabel, dur Timestamp) {
+	opt := lw.opt
+	l.num -= lw.merged
+	if !lw.match(l) {
+		return
+	}
+	lw.selected++
+	l.performer = cmp.Or(l.performer, lw.header.performer)
+	if opt.index00 && l.index00 >= 0 {
+		l.start = l.index00
+	}
+	label := []cueLabel{l}
+	switch {
+	case opt.tmpl != nil:
+		panicIfError(formatLabelTitles(label, []trackLength{{duration: dur}}, opt.tmpl,
+			max(opt.numStart, defaultNumStart), opt.num))
+	case opt.numStart >= 0:
+		numerateLabel(label, opt.numStart, opt.num)
+	}
+	if opt.subindex {
+		label = subindexLabels(label)
+	}
+	lw.writeLines(label)
+}
+
+func (lw *labelWriter) writeLines(label []cueLabel) {
+	if outputFormat == outputJSON {
+		lw.json = append(lw.json, label...)
+		return
+	}
+	for _, l := range label {
+		panicIfError(writeLabelLine(lw.w, l, lw.opt.freq))
+	}
+}
+
+// finish writes the last track and end label at end time if it is not
+// negative.
+func (lw *labelWriter) finish(end Timestamp) {
+	last := lw.held
+	lw.write(last, -1)
+	if lw.selected == 0 {
+		panic("No tracks selected")
+	}
+	if end >= 0 {
+		if end <= last.start {
+			panic("Audio duration " + formatTimeSec(end) + " is before the last track")
+		}
+		lw.writeLines([]cueLabel{{num: lw.tracks + 1, start: end, index00: -1,
+			title: endLabelTitle}})
+	}
+	if outputFormat == outputJSON {
+		writeLabel(lw.w, lw.json, lw.opt.freq)
+	}
+	panicIfError(lw.w.Flush())
+	panicIfError(lw.out.Close())
+}
+
+// subindexLabels adds a label at every INDEX 02 and later after its track
+// label, titled by the track label and the index number.
+func subindexLabels(label []cueLabel) (sub []cueLabel) {
+	for _, l := range label {
+		sub = append(sub, l)
+		for k, t := range l.subindex {
+			sub = append(sub, cueLabel{num: l.num, start: t, index00: -1,
+				title: fmt.Sprintf("%v (index %02d)", l.title, k+2)})
+		}
+	}
+	return
+}
+
+// endTime returns end of audio file name of cue cuePath starting at offset,
+// or -1 without -end-label.
+func (opt *labelOptions) endTime(cuePath, name string, offset Timestamp) Timestamp {
+	if !opt.endLabel {
+		return -1
+	}
+	if opt.end > 0 {
+		return opt.end
+	}
+	if name == "" {
+		panic("No cue FILE to probe, audio duration is required")
+	}
+	d, err := getMediaDuration(cueMediaPath(cuePath, name))
+	panicIfError(err)
+	if d > maxDuration-offset {
+		panic("Total duration exceeds maximum " + formatTimeSec(maxDuration))
+	}
+	return offset + d
+}
+
+// writeCumulative writes labels of all cue files as played back-to-back,
+// offset by lengths of preceding files taken from fileLen or probed relative
+// to the cue. Labels starting at the same time as the next one, like a track
+// index at the end of a file and the first track of the next file, are
+// warned about, or left out with dedupe.
+func (opt *labelOptions) writeCumulative(in *rereadInput, labelFilePath string,
+	fileLen []Timestamp, dedupe bool) {
+	var offset Timestamp
+
+	fileName := in.cueFiles()
+	if len(fileName) == 0 {
+		panic("No cue files found")
+	}
+	if len(fileLen) == 0 {
+		for _, name := range fileName[:len(fileName)-1] {
+			d, err := getMediaDuration(cueMediaPath(in.path, name))
+			panicIfError(err)
+			fileLen = append(fileLen, d)
+		}
+	} else if len(fileLen) < len(fileName)-1 {
+		panic(fmt.Sprintf("Expected %d file lengths", len(fileName)-1))
+	}
+	lw := opt.newLabelWriter(func(*cueSheet) io.WriteCloser {
+		return createOutput(labelFilePath)
+	})
+	lw.boundary, lw.merge = true, dedupe
+	num := 0
+	for i := range fileName {
+		r := in.open()
+		scanCue(r, i, func(sheet *cueSheet, l cueLabel) {
+			l.start += offset
+			if l.index00 >= 0 {
+				l.index00 += offset
+			}
+			l.subindex = shiftTimes(l.subindex, offset)
+			num++
+			l.num = num
+			lw.put(sheet, l)
+		})
+		r.Close()
+		if i < len(fileName)-1 {
+			if fileLen[i] > maxDuration-offset {
+				panic("Total duration exceeds maximum " + formatTimeSec(maxDuration))
+			}
+			offset += fileLen[i]
+		}
+	}
+	lw.finish(opt.endTime(in.path, fileName[len(fileName)-1], offset))
+}
+
+// cueMediaPath returns path of cue FILE name relative to the cue.
+func cueMediaPath(cuePath, name string) string {
+	if filepath.IsAbs(name) || isStdio(cuePath) {
+		return name
+	}
+	return filepath.Join(fileDir(cuePath), name)
+}
+
+// cueFileArg returns file name of FILE command arguments.
+func cueFileArg(s string) string {
+	if t := unQuotRe.FindStringSubmatch(s); len(t) == 2 {
+		return t[1]
+	}
+	if f := strings.Fields(s); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// parseCueFiles returns cue FILE names in order.
+func parseCueFiles(cue io.Reader) (name []string) {
+	scan := bufio.NewScanner(cue)
+	for n := 1; scan.Scan(); n++ {
+		s, ok := strings.CutPrefix(strings.TrimSpace(scan.Text()), "FILE")
+		if !ok {
+			continue
+		}
+		if f := cueFileArg(s); f != "" {
+			name = append(name, f)
+		} else {
+			panic(fmt.Sprintf("%v:%d: FILE without name", inputName(cue), n))
+		}
+	}
+	if err := scan.Err(); err != nil {
+		panic("Read cue " + inputName(cue) + ": " + err.Error())
+	}
+	return
+}
+
+// findCueFile returns index of the only FILE name matching glob pattern or
+// containing it.
+func findCueFile(name []string, pattern string) (index int, err error) {
+	index = -1
+	for i, n := range name {
+		m, err := filepath.Match(pattern, filepath.Base(n))
+		if err != nil {
+			return -1, err
+		}
+		if m || strings.Contains(n, pattern) {
+			if index >= 0 {
+				return -1, fmt.Errorf("'%v' matches '%v' and '%v'", pattern, name[index], n)
+			}
+			index = i
+		}
+	}
+	if index < 0 {
+		return -1, fmt.Errorf("no FILE matches '%v'", pattern)
+	}
+	return
+}
+
+// labelTitle is -title-format template data.
+type labelTitle struct {
+	Num       int
+	No        string // Num in -num-format
+	Title     string
+	Performer string
+	ISRC      string
+	Start     string
+	Duration  string // empty for the last track
+}
+
+func formatLabelTitles(label []cueLabel, track []trackLength, tmpl *template.Template,
+	numStart int, nf numFormat) error {
+	var b strings.Builder
+
+	for i, l := range label {
+		t := labelTitle{
+			Num:       numStart + l.num - 1,
+			No:        nf.number(numStart + l.num - 1),
+			Title:     l.title,
+			Performer: l.performer,
+			ISRC:      l.isrc,
+			Start:     formatMinSec(l.start),
+		}
+		if track[i].duration >= 0 {
+			t.Duration = formatMinSec(track[i].duration)
+		}
+		b.Reset()
+		if err := tmpl.Execute(&b, t); err != nil {
+			return fmt.Errorf("format label title: %w", err)
+		}
+		label[i].title = b.String()
+	}
+	return nil
+}
+
+func doCmdSecToCueTime(arg []string) {
+	convertTimes(arg, parseTimeSec, formatCueTime)
+}
+
+func doCmdCueTimeToSec(arg []string) {
+	convertTimes(arg, parseCueTime, formatTimeSec)
+}
+
+// doCmdTimeCalc adds times to the first one or subtracts them from it. The
+// result is cue time if the first time is, otherwise seconds.
+func doCmdTimeCalc(arg []string) {
+	var (
+		to  string
+		t   Timestamp
+		sum Timestamp
+		err error
+	)
+
+	// negative seconds are arguments, not options
+	if len(arg) > 1 && strings.HasPrefix(arg[1], "-") && !isNumber(arg[1]) {
+		fl := newFlagSet()
+		fl.StringVar(&to, "to", "", "result format: cue or sec, default is first time format")
+		parseFlags(fl, arg[1:])
+		arg = append(arg[:1], fl.Args()...)
+	}
+	if len(arg) < 4 {
+		panic("Expected operation and at least two times")
+	}
+	op := arg[1]
+	if op != "add" && op != "sub" {
+		panic("Wrong operation: " + op)
+	}
+	switch to {
+	case "":
+		to = "sec"
+		if strings.Contains(arg[2], ":") {
+			to = "cue"
+		}
+	case "cue", "sec":
+	default:
+		panic("Wrong result format: " + to)
+	}
+
+	for i, s := range arg[2:] {
+		t, err = parseTime(s)
+		if err != nil {
+			panic("Wrong time: " + err.Error())
+		}
+		switch {
+		case i == 0:
+			sum = t
+		case op == "add":
+			sum += t
+		default:
+			sum -= t
+		}
+		if err = checkDuration(max(sum, -sum)); err != nil {
+			panic("Wrong result: " + err.Error())
+		}
+	}
+	if outputFormat == outputJSON {
+		if sum < 0 {
+			panic("Negative result " + formatTimeSec(sum) + " has no cue time")
+		}
+		writeJSON(os.Stdout, jsonTimeConv{jsonTime(sum), sum.AlignToFrame().CueTime()})
+		return
+	}
+	if to == "sec" {
+		_, err = fmt.Println(formatTimeSec(sum))
+	} else if sum < 0 {
+		panic("Negative result " + formatTimeSec(sum) + " has no cue time")
+	} else {
+		// frame times are rounded up to microseconds, so the result is
+		// rounded to the nearest frame
+		_, err = fmt.Println(sum.AlignToFrame().CueTime())
+	}
+	panicIfError(err)
 }
 
-func main() {
+// convertTimes converts time arguments, or with -csv a column of CSV or TSV
+// stream passing other columns through.
+func convertTimes(arg []string, parse func(string) (Timestamp, error),
+	format func(Timestamp) string) {
 	var (
-		cmd func([]string)
-		arg []string
+		csvMode bool
+		header  bool
+		col     int
+		sep     string
+		inPath  string
+		outPath string
+		t       Timestamp
+		conv    []jsonTimeConv
+		err     error
 	)
 
-	defer checkPanic()
+	// negative seconds are arguments, not options
+	if len(arg) > 1 && strings.HasPrefix(arg[1], "-") && !isNumber(arg[1]) {
+		fl := newFlagSet()
+		fl.BoolVar(&csvMode, "csv", false, "convert column of CSV stream")
+		fl.IntVar(&col, "col", 1, "CSV column number starting at 1")
+		fl.StringVar(&sep, "sep", ",", "CSV field separator, \\t for TSV")
+		fl.BoolVar(&header, "header", false, "pass the first CSV row through")
+		fl.StringVar(&inPath, "i", "", "input CSV file path")
+		fl.StringVar(&outPath, "o", "", "output CSV file path")
+		parseFlags(fl, arg[1:])
+		arg = append(arg[:1], fl.Args()...)
+	}
+	if csvMode {
+		if len(arg) > 1 {
+			panic("No arguments expected with -csv")
+		}
+		if col < 1 {
+			panic("Wrong column number: " + strconv.Itoa(col))
+		}
+		if sep == `\t` {
+			sep = "\t"
+		}
+		if utf8.RuneCountInString(sep) != 1 {
+			panic("Wrong CSV separator: " + sep)
+		}
+		in, out := openInput(inPath), createOutput(outPath)
+		defer in.Close()
+		defer out.Close()
+		err = convertCSVColumn(in, out, []rune(sep)[0], col-1, header,
+			func(s string) (string, error) {
+				t, err := parse(s)
+				return format(t), err
+			})
+		panicIfError(err)
+		return
+	}
 
-	cmd, arg = parseArgv()
-	cmd(arg)
+	for _, s := range arg[1:] {
+		t, err = parse(s)
+		panicIfError(err)
+		if outputFormat == outputJSON {
+			conv = append(conv, jsonTimeConv{jsonTime(t), formatCueTime(t)})
+			continue
+		}
+		_, err = fmt.Println(format(t))
+		panicIfError(err)
+	}
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, conv)
+	}
 }
 
-func parseArgv() (cmd func([]string), arg []string) {
-	var ok bool
+// convertCSVColumn converts column col of every row. Rows without the
+// column are passed through.
+func convertCSVColumn(r io.Reader, w io.Writer, sep rune, col int, header bool,
+	conv func(string) (string, error)) (err error) {
+	var rec []string
 
-	if len(os.Args) < 2 {
-		panic("no command to execute")
+	cr, cw := csv.NewReader(r), csv.NewWriter(w)
+	cr.Comma, cw.Comma = sep, sep
+	cr.FieldsPerRecord, cr.LazyQuotes = -1, true
+	for n := 1; ; n++ {
+		if rec, err = cr.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("convert CSV: %w", err)
+		}
+		if col < len(rec) && !(header && n == 1) {
+			if rec[col], err = conv(strings.TrimSpace(rec[col])); err != nil {
+				return fmt.Errorf("convert CSV: row %d: %w", n, err)
+			}
+		}
+		if err = cw.Write(rec); err != nil {
+			return fmt.Errorf("convert CSV: %w", err)
+		}
 	}
-	arg = os.Args[1:]
+	cw.Flush()
+	return cw.Error()
+}
 
-	cmd, ok = commandTab[arg[0]]
-	if !ok {
-		panic("no such command: '" + arg[0] + "'")
+func isNumber(s string) bool {
+	_, err := parseDecimal(s)
+	return err == nil
+}
+
+// trackTitles returns cue track titles made from track file names.
+func (opt *cueOptions) trackTitles(trackFilePath []string) (title []string) {
+	for i, track := range trackFilePath {
+		title = append(title, formatTrackTitle(opt.numStart+i, track, &opt.titleOptions))
 	}
 	return
 }
 
-func doCmdMakeCue(arg []string) {
+// writeCue writes cue of tracks from offset in all tracks, the tracks written
+// to earlier rollover parts. ISRCs, FLAGS and side marks follow track numbers
+// counted from opt.numStart over all parts, while TRACK numbers of rollover
+// parts start again from 01.
+func writeCue(cue io.Writer, opt *cueOptions, trackTitle []string, start []Timestamp, offset int) {
 	var (
-		cueFilePath          string
-		trackFilePath        []string
-		denum                bool
-		cueWr                io.Writer
-		cueTitle             string
-		cueNumStart          int
-		shiftStart           int64
-		shiftTime, shiftFile string
-		err                  error
+		title, text []string
+		isrc        []string
+		err         error
 	)
 
-	fl := flag.NewFlagSet("", flag.ContinueOnError)
-	fl.StringVar(&cueFilePath, "o", "", "output cue file path")
-	fl.BoolVar(&denum, "denum", false, "remove track numbers from file names")
-	fl.IntVar(&cueNumStart, "num", 1, "cue tracks start number")
-	fl.StringVar(&shiftTime, "shift", "", "shift cue start time")
-	fl.StringVar(&shiftFile, "shift-f", "", "shift cue start time by file duration")
-	if err = fl.Parse(arg[1:]); err != nil {
-		panic("")
-	}
-	trackFilePath = fl.Args()
-	if len(trackFilePath) == 0 {
-		panic("No input track(s)")
+	if opt.numStart < 1 {
+		panic("Cue tracks number must starts from minimum 1")
+	}
+	// number of track i over all parts and its TRACK number in this part
+	num := func(i int) int { return opt.numStart + offset + i }
+	trackNum := func(i int) int { return (num(i)-1)%maxCueTracks + 1 }
+	for i, t := range start {
+		if checkDuration(t) != nil || i > 0 && t < start[i-1] {
+			panic(fmt.Sprintf("Wrong track %d start time %v", num(i), formatTimeSec(t)))
+		}
 	}
 
-	if cueFilePath != "" {
-		f, err := os.Create(cueFilePath)
+	title = append([]string{normalize(opt.title, opt.norm)}, trackTitle...)
+	for _, t := range title {
+		text = append(text, opt.cdText(t))
+	}
+	checkCDText(text, opt.truncate)
+	if opt.isrcBase != "" {
+		isrc, err = makeISRCs(opt.isrcBase, offset+len(trackTitle))
 		if err != nil {
-			panic("Cannot create output file: " + err.Error())
+			panic("Wrong ISRC base: " + err.Error())
 		}
-		defer f.Close()
-		cueWr = f
-		cueTitle = fileTitle(cueFilePath)
-	} else {
-		cueWr = os.Stdout
-		cueTitle = "FILE"
+		isrc = isrc[offset:]
 	}
 
-	if shiftTime != "" {
-		shiftStart, err = parseTimeSec(shiftTime)
-		if err != nil {
-			panic("Wrong shift time: " + err.Error())
+	if opt.catalog != "" {
+		_, err = fmt.Fprintf(cue, "CATALOG %v\n", opt.catalog)
+		panicIfError(err)
+	}
+	if opt.date != "" {
+		_, err = fmt.Fprintf(cue, "REM DATE %v\n", opt.date)
+		panicIfError(err)
+	}
+	if opt.performer != "" {
+		_, err = fmt.Fprintf(cue, "PERFORMER %q\n", opt.cdText(normalize(opt.performer, opt.norm)))
+		panicIfError(err)
+	}
+	writeCueTitle(cue, "", title[0], text[0])
+	if opt.cover != "" {
+		_, err = fmt.Fprintf(cue, "REM COVER %q\n", opt.cuePath(opt.cover))
+		panicIfError(err)
+	}
+	_, err = fmt.Fprintf(cue, "FILE %q WAVE\n", opt.cueFileName())
+	panicIfError(err)
+	for i := range trackTitle {
+		_, err = fmt.Fprintf(cue, "  TRACK %02d AUDIO\n", trackNum(i))
+		panicIfError(err)
+		if side, ok := opt.sideMark[num(i)]; ok {
+			_, err = fmt.Fprintf(cue, "    REM SIDE %v\n", side)
+			panicIfError(err)
 		}
-	} else if shiftFile != "" {
-		shiftStart, err = getMediaDuration(shiftFile)
+		writeCueTitle(cue, "    ", title[i+1], text[i+1])
+		if i < len(opt.trackPerformer) && opt.trackPerformer[i] != "" {
+			_, err = fmt.Fprintf(cue, "    PERFORMER %q\n",
+				opt.cdText(normalize(opt.trackPerformer[i], opt.norm)))
+			panicIfError(err)
+		}
+		if isrc != nil {
+			_, err = fmt.Fprintf(cue, "    ISRC %v\n", isrc[i])
+			panicIfError(err)
+		}
+		if f := opt.trackFlags(num(i)); len(f) > 0 {
+			_, err = fmt.Fprintf(cue, "    FLAGS %v\n", strings.Join(f, " "))
+			panicIfError(err)
+		}
+		_, err = fmt.Fprintf(cue, "    INDEX 01 %v\n", formatCueTime(start[i]))
 		panicIfError(err)
+		if opt.precise {
+			_, err = fmt.Fprintf(cue, "    REM INDEX01-SEC %v\n", formatTimeSec(start[i]))
+			panicIfError(err)
+		}
+		if i < len(opt.subindex) {
+			for k, t := range opt.subindex[i] {
+				_, err = fmt.Fprintf(cue, "    INDEX %02d %v\n", k+2, formatCueTime(t))
+				panicIfError(err)
+			}
+		}
 	}
+}
 
-	writeCue(cueWr, cueTitle, cueNumStart, shiftStart, trackFilePath, denum)
+// cdText returns title as written to cue: transliterated and truncated to
+// CD-TEXT limit if requested.
+func (opt *cueOptions) cdText(title string) string {
+	if opt.translit {
+		title = transliterate(title)
+	}
+	if opt.truncate {
+		title = truncateCDText(title)
+	}
+	return title
 }
 
-func doCmdMakeLabel(arg []string) {
+// writeCueSheet writes parsed cue sheet with one audio file of fileType like
+// WAVE or BINARY.
+func writeCueSheet(cue io.Writer, sheet cueSheet, fileName, fileType string) {
+	var err error
+
+	write := func(format string, a ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(cue, format, a...)
+		}
+	}
+	if sheet.catalog != "" {
+		write("CATALOG %v\n", sheet.catalog)
+	}
+	if sheet.cover != "" {
+		write("REM COVER %q\n", sheet.cover)
+	}
+	if sheet.performer != "" {
+		write("PERFORMER %q\n", sheet.performer)
+	}
+	if sheet.title != "" {
+		write("TITLE %q\n", sheet.title)
+	}
+	write("FILE %q %v\n", fileName, fileType)
+	for i, l := range sheet.label {
+		write("  TRACK %02d AUDIO\n    TITLE %q\n", i+1, l.title)
+		if l.performer != "" {
+			write("    PERFORMER %q\n", l.performer)
+		}
+		if l.isrc != "" {
+			write("    ISRC %v\n", l.isrc)
+		}
+		if len(l.flags) > 0 {
+			write("    FLAGS %v\n", strings.Join(l.flags, " "))
+		}
+		if l.index00 >= 0 {
+			write("    INDEX 00 %v\n", l.index00.CueTime())
+		}
+		write("    INDEX 01 %v\n", l.start.CueTime())
+		if t, _ := parseCueTime(l.start.CueTime()); t != l.start {
+			write("    REM INDEX01-SEC %v\n", l.start.String())
+		}
+		for k, t := range l.subindex {
+			write("    INDEX %02d %v\n", k+2, t.CueTime())
+		}
+	}
+	panicIfError(err)
+}
+
+// writeCueTitle writes TITLE text, keeping the original title in
+// REM ORIGINAL-TITLE if it differs.
+func writeCueTitle(cue io.Writer, indent, title, text string) {
+	var err error
+
+	if text != title {
+		_, err = fmt.Fprintf(cue, "%vTITLE %q\n%vREM ORIGINAL-TITLE %q\n",
+			indent, text, indent, title)
+	} else {
+		_, err = fmt.Fprintf(cue, "%vTITLE %q\n", indent, title)
+	}
+	panicIfError(err)
+}
+
+// trackStartTimes probes tracks and returns their start times. The end time
+// is the end of the last track if probeLast is set, otherwise its start time.
+// Each next track starts overlap earlier to account for crossfades.
+func trackStartTimes(shiftStart, overlap Timestamp, trackFilePath []string,
+	probeLast bool) (start []Timestamp, end Timestamp) {
 	var (
-		cueFilePath         string
-		cueAudioFile        int
-		labelFilePath       string
-		numStart, numDigits int
-		cueRd               io.Reader
-		labelWr             io.Writer
-		label               []cueLabel
+		d   Timestamp
+		err error
 	)
 
-	fl := flag.NewFlagSet("", flag.ContinueOnError)
-	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
-	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
-	fl.StringVar(&labelFilePath, "o", "", "output label file path")
-	fl.IntVar(&numStart, "num", defaultNumStart, "start track number or -1")
-	fl.IntVar(&numDigits, "num-digits", defaultNumDigits, "min digits in track number")
-	if err := fl.Parse(arg[1:]); err != nil {
-		panic("")
+	if shiftStart < 0 {
+		panic("Shift time is negative: " + formatTimeSec(shiftStart))
 	}
-	if fl.NArg() != 0 {
-		panic("No arguments expected")
+	if overlap < 0 {
+		panic("Overlap time is negative: " + formatTimeSec(overlap))
 	}
-
-	if cueFilePath != "" {
-		f, err := os.Open(cueFilePath)
-		if err != nil {
-			panic("Cannot open input file: " + err.Error())
+	if shiftStart > maxDuration {
+		panic("Shift time exceeds maximum " + formatTimeSec(maxDuration))
+	}
+	end = shiftStart
+	for i, track := range trackFilePath {
+		if i > 0 {
+			if d <= overlap {
+				panic(fmt.Sprintf("Track '%v' is shorter than overlap", trackFilePath[i-1]))
+			}
+			end -= overlap
 		}
-		defer f.Close()
-		cueRd = f
-	} else {
-		cueRd = os.Stdin
+		if t, ok := sidecarStart(track); ok {
+			if i > 0 && t < start[i-1] {
+				panic(fmt.Sprintf("Track '%v' sidecar start is before previous track", track))
+			}
+			end = t
+		}
+		start = append(start, end)
+		if i < len(trackFilePath)-1 || probeLast {
+			d, err = getMediaDuration(track)
+			panicIfError(err)
+			if d > maxDuration-end {
+				panic("Total duration exceeds maximum " + formatTimeSec(maxDuration))
+			}
+			end += d
+		}
+	}
+	return
+}
+
+// sidecarStart reads explicit track start time from "track.flac.start" or
+// "track.start" file with -sidecar extension.
+func sidecarStart(trackFilePath string) (start Timestamp, ok bool) {
+	if probeOpt.sidecar == "" {
+		return
+	}
+	ext := probeOpt.sidecar
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
 	}
-	if labelFilePath != "" {
-		f, err := os.Create(labelFilePath)
+	for _, path := range []string{trackFilePath + ext,
+		strings.TrimSuffix(trackFilePath, filepath.Ext(trackFilePath)) + ext} {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
 		if err != nil {
-			panic("Cannot create output file: " + err.Error())
+			panic("Cannot read sidecar: " + err.Error())
 		}
-		defer f.Close()
-		labelWr = f
-	} else {
-		labelWr = os.Stdout
+		if start, err = parseTime(strings.TrimSpace(string(data))); err != nil || start < 0 {
+			panic(fmt.Sprintf("Wrong start time in '%v'", path))
+		}
+		return start, true
 	}
+	return
+}
+
+// shiftTimes returns times moved by offset.
+func shiftTimes(t []Timestamp, offset Timestamp) (shifted []Timestamp) {
+	for _, v := range t {
+		shifted = append(shifted, v+offset)
+	}
+	return
+}
 
-	label = parseCue(cueRd, cueAudioFile)
-	if numStart >= 0 {
-		if numDigits <= 0 {
-			panic("Wrong track number digits")
+// boundaryDuplicates returns indexes of tracks starting at the same time as
+// the next one, like a zero length track or a sidecar start repeating the
+// previous index where joined material begins.
+func boundaryDuplicates(start []Timestamp) (dup []int) {
+	for i := 1; i < len(start); i++ {
+		if start[i] == start[i-1] {
+			dup = append(dup, i-1)
 		}
-		numerateLabel(label, numStart, numDigits)
 	}
-	writeLabel(labelWr, label)
+	return
 }
 
-func doCmdSecToCueTime(arg []string) {
-	var t int64
-	var err error
+// dedupeBoundaries warns about tracks starting at the same time as the next
+// one. With -dedupe-boundary such tracks are left out and the next track
+// takes their index.
+func (opt *cueOptions) dedupeBoundaries(trackFilePath []string,
+	start []Timestamp) ([]string, []Timestamp) {
 
-	for _, secTime := range arg[1:] {
-		t, err = parseTimeSec(secTime)
-		panicIfError(err)
-		_, err = fmt.Println(formatCueTime(t))
-		panicIfError(err)
+	dup := boundaryDuplicates(start)
+	for _, i := range dup {
+		if opt.dedupeBoundary {
+			logMessage(fmt.Sprintf("track %d '%v' merged into track %d at %v", opt.numStart+i,
+				trackFilePath[i], opt.numStart+i+1, formatTimeSec(start[i])))
+		} else {
+			logWarningMessage(fmt.Sprintf("tracks %d and %d both start at %v, use -dedupe-boundary",
+				opt.numStart+i, opt.numStart+i+1, formatTimeSec(start[i])))
+		}
 	}
+	if !opt.dedupeBoundary {
+		return trackFilePath, start
+	}
+	for k := len(dup) - 1; k >= 0; k-- {
+		trackFilePath = slices.Delete(trackFilePath, dup[k], dup[k]+1)
+		start = slices.Delete(start, dup[k], dup[k]+1)
+	}
+	return trackFilePath, start
 }
 
-func doCmdCueTimeToSec(arg []string) {
-	var t int64
-	var err error
+// parseCue parses tracks of audio file cueAudioFile and reports problems
+// found as warnings.
+func parseCue(cue io.Reader, cueAudioFile int) (sheet cueSheet) {
+	return scanCue(cue, cueAudioFile, appendLabel)
+}
 
-	for _, cueTime := range arg[1:] {
-		t, err = parseCueTime(cueTime)
-		panicIfError(err)
-		_, err = fmt.Println(formatTimeSec(t))
-		panicIfError(err)
+// scanCue is scanCueSheet reporting problems found as warnings.
+func scanCue(cue io.Reader, cueAudioFile int,
+	put func(sheet *cueSheet, l cueLabel)) (sheet cueSheet) {
+	sheet = scanCueSheet(cue, cueAudioFile, put)
+	for _, w := range sheet.warning {
+		logFileWarning(w.file, w.line, w.msg)
 	}
+	return
 }
 
-func doCmdHelp(arg []string) {
-	if len(arg) > 1 {
-		panic("No arguments expected")
-	}
-	logMessage(usage)
+// parseCueSheet parses tracks of audio file cueAudioFile. Problems found are
+// returned in sheet warnings, or panic with -strict.
+func parseCueSheet(cue io.Reader, cueAudioFile int) (sheet cueSheet) {
+	return scanCueSheet(cue, cueAudioFile, appendLabel)
+}
+
+// appendLabel keeps track parsed by scanCueSheet in sheet labels.
+func appendLabel(sheet *cueSheet, l cueLabel) {
+	sheet.label = append(sheet.label, l)
 }
 
-func writeCue(cue io.Writer, cueTitle string, cueNumStart int, shiftStart int64,
-	trackFilePath []string, denum bool) {
+// scanCueSheet parses cue like parseCueSheet, but passes every track of audio
+// file cueAudioFile to put as soon as it is parsed instead of keeping it in
+// sheet labels. The sheet header is parsed before the first track.
+func scanCueSheet(cue io.Reader, cueAudioFile int,
+	put func(sheet *cueSheet, l cueLabel)) (sheet cueSheet) {
 	var (
-		title  string
-		dur, d int64
-		err    error
+		audioFile, audioTrack  int
+		n, trackLine, indexNum int
+		tracks                 int
+		indexTime              Timestamp
+		s                      string
+		ok, precise            bool
+		l                      cueLabel
+		emptyL                 = cueLabel{start: -1, index00: -1}
+		err                    error
 	)
-
-	if cueNumStart < 1 {
-		panic("Cue tracks number must starts from minimum 1")
+	name := inputName(cue)
+	report := func(line int, format string, a ...any) {
+		w := cueWarning{name, line, fmt.Sprintf(format, a...)}
+		if strictCue {
+			panic(w.String())
+		}
+		sheet.warning = append(sheet.warning, w)
 	}
-	if shiftStart < 0 {
-		panic("Shift time is negative: " + formatTimeSec(shiftStart))
+	fail := func(format string, a ...any) {
+		panic(fmt.Sprintf("%v:%d: ", name, n) + fmt.Sprintf(format, a...))
 	}
-	dur = shiftStart
-
-	_, err = fmt.Fprintf(cue, "TITLE %q\n", cueTitle)
-	panicIfError(err)
-	_, err = fmt.Fprintf(cue, "FILE %q WAVE\n", cueTitle+".mka")
-	panicIfError(err)
-	for i, track := range trackFilePath {
-		_, err = fmt.Fprintf(cue, "  TRACK %02d AUDIO\n", cueNumStart+i)
-		panicIfError(err)
-		title = formatTrackTitle(cueNumStart+i, track, denum)
-		_, err = fmt.Fprintf(cue, "    TITLE %q\n", title)
-		panicIfError(err)
-		_, err = fmt.Fprintf(cue, "    INDEX 01 %v\n", formatCueTime(dur))
-		panicIfError(err)
-		if i < len(trackFilePath)-1 {
-			d, err = getMediaDuration(track)
-			panicIfError(err)
-			dur += d
+	cueString := func(s, field string) string {
+		if t := unQuotRe.FindStringSubmatch(s); len(t) == 2 {
+			return t[1]
 		}
+		if t := strings.TrimSpace(s); t != "" {
+			report(n, "unquoted %v", field)
+			return t
+		}
+		fail("empty %v", field)
+		return ""
 	}
-}
-
-func parseCue(cue io.Reader, cueAudioFile int) (label []cueLabel) {
-	var (
-		audioFile, audioTrack int
-		s                     string
-		ok                    bool
-		l                     cueLabel
-		emptyL                = cueLabel{start: -1}
-		err                   error
-	)
 	putLabel := func(l *cueLabel) {
+		if audioFile == cueAudioFile && audioTrack >= 0 && l.start < 0 {
+			report(trackLine, "TRACK without INDEX 01 skipped")
+		}
 		if l.start >= 0 {
 			if l.title == "" {
+				report(trackLine, "TRACK without TITLE")
 				l.title = strconv.Itoa(audioTrack)
 			}
-			label = append(label, *l)
+			l.num = audioTrack + 1
+			put(&sheet, *l)
+			tracks++
 			*l = emptyL
 		}
 	}
@@ -272,176 +1708,362 @@ func parseCue(cue io.Reader, cueAudioFile int) (label []cueLabel) {
 	audioTrack = -1
 	l = emptyL
 	scan := bufio.NewScanner(cue)
-	for scan.Scan() {
+	for n = 1; scan.Scan(); n++ {
 		s = scan.Text()
 		s = strings.TrimSpace(s)
 		if s == "" {
 			continue
 		}
-		if strings.HasPrefix(s, "FILE") {
+		// only the header and the selected file are checked
+		if field := strings.Fields(s); audioFile < 0 || audioFile == cueAudioFile {
+			if !cueCommands[field[0]] {
+				report(n, "unknown command %v", field[0])
+			} else if field[0] == "INDEX" {
+				num, t, err := parseCueIndex(field[1:])
+				if err != nil {
+					report(n, "wrong INDEX: %v", err)
+				} else if num <= indexNum || t < indexTime {
+					report(n, "INDEX %02d out of order", num)
+				} else {
+					indexNum, indexTime = num, t
+					if num > 1 && audioFile == cueAudioFile && audioTrack >= 0 {
+						l.subindex = append(l.subindex, t)
+					}
+				}
+			}
+		}
+		if s, ok = strings.CutPrefix(s, "FILE"); ok {
 			putLabel(&l)
 			audioFile++
+			if audioFile == cueAudioFile {
+				sheet.file = cueFileArg(s)
+			}
 			audioTrack = -1
+			indexNum, indexTime = -1, -1
 		} else if strings.HasPrefix(s, "TRACK") {
 			putLabel(&l)
 			audioTrack++
+			trackLine, indexNum = n, -1
+			precise = false
 		} else if s, ok = strings.CutPrefix(s, "TITLE"); ok {
 			if audioFile == cueAudioFile && audioTrack >= 0 {
-				var t = unQuotRe.FindStringSubmatch(s)
-				if len(t) != 2 {
-					panic("Wrong cue title:\n" + s)
-				}
-				l.title = t[1]
+				l.title = cueString(s, "title")
+			} else if audioFile <= 0 && audioTrack < 0 {
+				sheet.title = cueString(s, "title")
+			}
+		} else if s, ok = strings.CutPrefix(s, "PERFORMER"); ok {
+			if audioFile == cueAudioFile && audioTrack >= 0 {
+				l.performer = cueString(s, "performer")
+			} else if audioFile <= 0 && audioTrack < 0 {
+				sheet.performer = cueString(s, "performer")
 			}
 		} else if s, ok = strings.CutPrefix(s, "INDEX 01"); ok {
+			if audioFile == cueAudioFile && audioTrack >= 0 && !precise {
+				t, err := parseCueTime(s)
+				l.start = t
+				if err != nil {
+					fail("wrong INDEX 01 time %q", strings.TrimSpace(s))
+				}
+			}
+		} else if s, ok = strings.CutPrefix(s, "REM COVER"); ok {
+			if audioFile < 0 {
+				sheet.cover = cueString(s, "cover")
+			}
+		} else if s, ok = strings.CutPrefix(s, "CATALOG"); ok {
+			sheet.catalog = strings.TrimSpace(s)
+		} else if s, ok = strings.CutPrefix(s, "ISRC"); ok {
+			if audioFile == cueAudioFile && audioTrack >= 0 {
+				l.isrc = strings.TrimSpace(s)
+			}
+		} else if s, ok = strings.CutPrefix(s, "FLAGS"); ok {
+			if audioFile == cueAudioFile && audioTrack >= 0 {
+				l.flags = strings.Fields(s)
+			}
+		} else if s, ok = strings.CutPrefix(s, "INDEX 00"); ok {
+			if audioFile == cueAudioFile && audioTrack >= 0 {
+				t, err := parseCueTime(s)
+				l.index00 = t
+				if err != nil {
+					fail("wrong INDEX 00 time %q", strings.TrimSpace(s))
+				}
+			}
+		} else if s, ok = strings.CutPrefix(s, "REM INDEX01-SEC"); ok {
 			if audioFile == cueAudioFile && audioTrack >= 0 {
-				l.start, err = parseCueTime(s)
+				t, err := parseToolTime(strings.TrimSpace(s))
+				l.start = t
 				if err != nil {
-					panic("Wrong cue INDEX 01 time:\n" + s)
+					fail("wrong REM INDEX01-SEC time %q", strings.TrimSpace(s))
 				}
+				precise = true
 			}
 		}
 	}
 	if err = scan.Err(); err != nil {
-		panic("Read cue: " + err.Error())
+		panic("Read cue " + name + ": " + err.Error())
 	}
 	putLabel(&l)
-	if len(label) == 0 {
-		panic("No cue tracks found")
+	if tracks == 0 {
+		panic("No cue tracks found in " + name)
 	}
 	return
 }
 
-func formatTrackTitle(nTrack int, fileName string, denum bool) (title string) {
-	title = fileTitle(fileName)
-	if title == "" {
-		title = fmt.Sprintf("%0*d", defaultNumDigits, nTrack)
-		return
+// strictCue is set by the global -strict option: problems in input cue and
+// duplicate titles are errors, otherwise they are reported as warnings.
+var strictCue bool
+
+var cueCommands = map[string]bool{
+	"CATALOG": true, "CDTEXTFILE": true, "FILE": true, "FLAGS": true,
+	"INDEX": true, "ISRC": true, "PERFORMER": true, "POSTGAP": true,
+	"PREGAP": true, "REM": true, "SONGWRITER": true, "TITLE": true,
+	"TRACK": true,
+}
+
+// maxCueIndex is the highest INDEX number.
+const maxCueIndex = 99
+
+// parseCueIndex parses INDEX number and time fields.
+func parseCueIndex(field []string) (num int, t Timestamp, err error) {
+	if len(field) != 2 {
+		return 0, 0, fmt.Errorf("expected number and time")
+	}
+	if num, err = strconv.Atoi(field[0]); err != nil || num < 0 || num > maxCueIndex {
+		return 0, 0, fmt.Errorf("wrong number '%v'", field[0])
 	}
-	if denum {
+	t, err = parseCueTime(field[1])
+	return
+}
+
+func formatTrackTitle(nTrack int, fileName string, opt *titleOptions) (title string) {
+	title = fileTitle(fileName)
+	if opt.denum {
 		var t = denumRe.FindStringSubmatch(title)
 		if len(t) == 2 {
 			title = t[1]
 		}
 	}
+	title = opt.clean(title)
+	if title == "" {
+		title = opt.num.number(nTrack)
+	}
 	return
 }
 
-func numerateLabel(label []cueLabel, numStart, numDigits int) {
+func numerateLabel(label []cueLabel, numStart int, nf numFormat) {
 	for i, l := range label {
-		label[i].title = fmt.Sprintf("%0*d %v", numDigits, numStart+i, l.title)
+		label[i].title = nf.number(numStart+l.num-1) + " " + l.title
 	}
 }
 
-func writeLabel(labelWr io.Writer, label []cueLabel) {
-	var (
-		t   string
-		err error
-	)
-
+// writeLabel writes Audacity label file, with zero frequency line after every
+// label if freq is set.
+func writeLabel(labelWr io.Writer, label []cueLabel, freq bool) {
+	if outputFormat == outputJSON {
+		js := make([]jsonLabel, 0, len(label))
+		for _, l := range label {
+			js = append(js, jsonLabel{jsonTime(l.start), jsonTime(l.start), l.title})
+		}
+		writeJSON(labelWr, js)
+		return
+	}
 	for _, l := range label {
-		t = formatTimeSec(l.start)
-		_, err = fmt.Fprintf(labelWr, "%v\t%v\t%v\n", t, t, l.title)
-		panicIfError(err)
+		panicIfError(writeLabelLine(labelWr, l, freq))
 	}
 }
 
-func getMediaDuration(filePath string) (dur int64, err error) {
-	var out []byte
-	var js struct {
-		Format struct {
-			Duration *string `json:"duration"`
-			Start    *string `json:"start_time"`
-		} `json:"format"`
+// writeLabelLine writes Audacity label line, followed by zero frequency line
+// if freq is set.
+func writeLabelLine(labelWr io.Writer, l cueLabel, freq bool) (err error) {
+	t := l.start.String()
+	_, err = fmt.Fprintf(labelWr, "%v\t%v\t%v\n", t, t, labelEscaper.Replace(l.title))
+	if err == nil && freq {
+		_, err = io.WriteString(labelWr, labelFreqLine)
 	}
-	var start int64
+	return
+}
 
-	out, err = runCommand("ffprobe",
-		"-hide_banner",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-i", filePath)
-	if err != nil {
-		err = fmt.Errorf("get media duration: ffprobe: %w", err)
-		return
+// probeOpt holds media probing options shared by all commands.
+var probeOpt struct {
+	gapless  bool
+	exact    bool
+	count    bool
+	native   bool
+	manifest string
+	sidecar  string
+	cache    bool
+	refresh  string // file pattern
+	timeout  time.Duration
+}
+
+func addProbeFlags(fl *flag.FlagSet) {
+	fl.BoolVar(&probeOpt.gapless, "gapless", false,
+		"use MP3 LAME and AAC iTunSMPB gapless info for durations")
+	fl.BoolVar(&probeOpt.exact, "exact", false,
+		"use sample-accurate audio stream duration")
+	fl.BoolVar(&probeOpt.count, "count", false,
+		"read all packets if container has no duration")
+	fl.BoolVar(&probeOpt.native, "native", false,
+		"read WAV, FLAC, Ogg and MP3 durations without ffprobe")
+	fl.StringVar(&probeOpt.manifest, "manifest", "",
+		"take durations from probe manifest file")
+	fl.StringVar(&probeOpt.sidecar, "sidecar", "",
+		"read track start times from sidecar files with extension, like .start")
+	fl.BoolVar(&probeOpt.cache, "cache", false, "keep probed durations in cache file between runs")
+	fl.DurationVar(&probeOpt.timeout, "probe-timeout", 0, "stop probing a file after duration like 30s")
+	fl.Func("refresh", "probe files matching pattern again, ignoring -cache",
+		func(s string) error {
+			if _, err := filepath.Match(s, ""); err != nil {
+				return err
+			}
+			probeOpt.refresh = s
+			return nil
+		})
+}
+
+// getMediaDuration returns media file duration in microseconds from probe
+// manifest, durations probed before, or backend selected by probe options,
+// within -probe-timeout.
+func getMediaDuration(filePath string) (Timestamp, error) {
+	ctx := context.Background()
+	if probeOpt.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, probeOpt.timeout)
+		defer cancel()
+	}
+	return getBackendDuration(ctx, probeBackend(), filePath)
+}
+
+// parseTime parses time in seconds or in cue format if it contains colons.
+func parseTime(time string) (Timestamp, error) {
+	if strings.Contains(time, ":") {
+		return parseCueTime(time)
 	}
+	return parseTimeSec(time)
+}
+
+// Decimal separators of input times.
+const (
+	decimalAuto  = "auto" // point or comma
+	decimalPoint = "point"
+	decimalComma = "comma"
+)
 
-	err = json.Unmarshal(out, &js)
+// decimalSep is set by the global -decimal option.
+var decimalSep = decimalAuto
+
+// parseDecimal parses number with decimal point, or comma like "183,44"
+// written by European tools, as -decimal option allows.
+func parseDecimal(s string) (float64, error) {
+	num := s
+	switch {
+	case decimalSep == decimalComma && strings.Contains(s, "."):
+		return 0, fmt.Errorf("'%v' has decimal point, expected comma", s)
+	case decimalSep == decimalPoint && strings.Contains(s, ","):
+		return 0, fmt.Errorf("'%v' has decimal comma, expected point", s)
+	case decimalSep != decimalPoint && !strings.Contains(s, "."):
+		num = strings.Replace(s, ",", ".", 1)
+	}
+	f, err := strconv.ParseFloat(num, 64)
 	if err != nil {
-		err = fmt.Errorf("get media duration: %w", err)
-		return
+		return 0, fmt.Errorf("'%v' is not a number", s)
 	}
+	return f, nil
+}
+
+// parseTimeSec parses user input time in seconds, with decimal separator
+// -decimal option allows.
+func parseTimeSec(time string) (t Timestamp, err error) {
+	var f float64
 
-	if js.Format.Duration == nil {
-		err = errors.New("get media duration: no 'duration' field in JSON")
+	if f, err = parseDecimal(time); err != nil {
 		return
 	}
-	dur, err = parseTimeSec(*js.Format.Duration)
+	return secondsTime(time, f)
+}
+
+// parseToolTime parses time in seconds with decimal point, like written by
+// ffprobe, ffmpeg and cue-maker itself, whatever -decimal option is.
+func parseToolTime(time string) (Timestamp, error) {
+	f, err := strconv.ParseFloat(time, 64)
 	if err != nil {
-		err = fmt.Errorf("get media duration: 'duration': %w", err)
-		return
+		return 0, fmt.Errorf("'%v' is not a number", time)
 	}
+	return secondsTime(time, f)
+}
 
-	if js.Format.Start != nil {
-		start, err = parseTimeSec(*js.Format.Start)
-		if err != nil {
-			err = fmt.Errorf("get media duration: 'start_time': %w", err)
-			return
-		}
-		if start > 0 {
-			dur -= start
-		}
+// secondsTime converts f seconds parsed from time to Timestamp.
+func secondsTime(time string, f float64) (t Timestamp, err error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("'%v' is not a finite number", time)
 	}
-	if dur <= 0 {
-		err = fmt.Errorf("get media duration: wrong value: %v", dur)
-		return
+	if math.Abs(f)*uSecInSecond > float64(maxDuration) {
+		return 0, fmt.Errorf("'%v' exceeds maximum duration %v", time, formatTimeSec(maxDuration))
 	}
+	t = Timestamp(math.Round(f * uSecInSecond))
 	return
 }
 
-func parseTimeSec(time string) (timeUSec int64, err error) {
-	var f float64
-
-	f, err = strconv.ParseFloat(time, 64)
-	if err != nil {
-		return
+// checkDuration returns error if dur is negative or longer than maxDuration.
+func checkDuration(dur Timestamp) error {
+	if dur < 0 || dur > maxDuration {
+		return fmt.Errorf("duration %v out of range 0-%v", formatTimeSec(dur),
+			formatTimeSec(maxDuration))
 	}
-	timeUSec = int64(math.Round(f * uSecInSecond))
-	return
+	return nil
 }
 
-func formatTimeSec(timeUSec int64) string {
-	return fmt.Sprintf("%d.%06d",
-		timeUSec/uSecInSecond,
-		abs(timeUSec%uSecInSecond))
+func formatTimeSec(t Timestamp) string {
+	var sign string
+
+	if t < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%v%d.%06d", sign,
+		abs(t/uSecInSecond),
+		abs(t%uSecInSecond))
 }
 
-func parseCueTime(cueTime string) (int64, error) {
+func parseCueTime(cueTime string) (Timestamp, error) {
 	var min, sec, frames int64
 
 	if _, err := fmt.Sscanf(cueTime, "%d:%d:%d", &min, &sec, &frames); err != nil {
 		return 0, fmt.Errorf("Wrong CUE time '%v': %w", cueTime, err)
 	}
 	if min < 0 || sec < 0 || frames < 0 ||
-		sec >= 60 || frames >= 75 {
+		sec >= 60 || frames >= cdFramesPerSecond || min > int64(maxDuration/uSecInSecond/60) {
 		return 0, fmt.Errorf("Wrong CUE time '%v'", cueTime)
 	}
-	return (min*60+sec)*uSecInSecond + frames*uSecInSecond/75, nil
+	// rounded up so that formatCueTime gives the same frame back
+	return FramesTime(cdMSF{min, sec, frames}.sectors()), nil
 }
 
-func formatCueTime(timeUSec int64) string {
-	sec := timeUSec / uSecInSecond
-	frames := (timeUSec % uSecInSecond) * 75 / uSecInSecond
+func formatCueTime(t Timestamp) string {
+	return t.CueTime()
+}
 
-	return fmt.Sprintf("%02d:%02d:%02d", sec/60, sec%60, frames)
+// formatMinSec formats time rounded to seconds as m:ss or h:mm:ss.
+func formatMinSec(dur Timestamp) string {
+	sec := dur.Seconds(RoundNearest)
+	if sec >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	}
+	return fmt.Sprintf("%d:%02d", sec/60, sec%60)
 }
 
-func runCommand(command string, args ...string) ([]byte, error) {
-	return exec.Command(command, args...).Output()
+// cuePartPath returns path of rollover or side cue file, e.g. "album-2.cue".
+func cuePartPath(path, part string) string {
+	gz := ""
+	if strings.HasSuffix(path, gzipExt) {
+		path, gz = strings.TrimSuffix(path, gzipExt), gzipExt
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v-%v%v%v", strings.TrimSuffix(path, ext), part, ext, gz)
 }
 
 func fileTitle(path string) string {
+	if _, member, ok := splitZipPath(path); ok {
+		path = member
+	}
 	base := filepath.Base(path)
 	if i := strings.LastIndexByte(base, '.'); i != -1 {
 		return base[:i]
@@ -449,7 +2071,7 @@ func fileTitle(path string) string {
 	return base
 }
 
-func abs[T int8 | int16 | int32 | int64](v T) T {
+func abs[T ~int8 | ~int16 | ~int32 | ~int64](v T) T {
 	if v < 0 {
 		v = -v
 	}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCueSheetWarnings(t *testing.T) {
+	cue := "TITLE Album\nFILE \"a.wav\" WAVE\n  TRACK 01 AUDIO\n    TITLE One\n" +
+		"    INDEX 01 00:00:00\n"
+	sheet := parseCueSheet(newNamedReader([]byte(cue), "a.cue"), 0)
+	want := []cueWarning{{"a.cue", 1, "unquoted title"}, {"a.cue", 4, "unquoted title"}}
+	if !reflect.DeepEqual(sheet.warning, want) {
+		t.Errorf("warnings = %v, want %v", sheet.warning, want)
+	}
+	if len(sheet.label) != 1 || sheet.label[0].title != "One" {
+		t.Errorf("tracks = %+v", sheet.label)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dbSchema is the SQLite catalog of generated cues. Paths are absolute,
+// times are in seconds.
+const dbSchema = `CREATE TABLE IF NOT EXISTS cue (
+	id INTEGER PRIMARY KEY,
+	path TEXT,
+	title TEXT,
+	created TEXT
+);
+CREATE TABLE IF NOT EXISTS source (
+	cue_id INTEGER REFERENCES cue(id),
+	path TEXT,
+	sha256 TEXT,
+	duration REAL
+);
+CREATE TABLE IF NOT EXISTS track (
+	cue_id INTEGER REFERENCES cue(id),
+	num INTEGER,
+	title TEXT,
+	start REAL
+);
+CREATE INDEX IF NOT EXISTS source_sha256 ON source(sha256);
+`
+
+// runSQL runs SQL script with sqlite3 utility and returns its output.
+func runSQL(dbPath, script string, arg ...string) (out []byte, err error) {
+	out, _, err = runCommandInput(strings.NewReader(script), "sqlite3",
+		append(append([]string{"-bail"}, arg...), dbPath)...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3: %w", err)
+	}
+	return
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// sqlLikeEscaper escapes LIKE wildcards for ESCAPE '\'.
+var sqlLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func sqlPath(path string) string {
+	if isStdio(path) {
+		return "NULL"
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return sqlQuote(path)
+}
+
+// fileSHA256 returns hex SHA-256 of file contents.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recordCue adds cue files of parts with their source files and tracks to
+// -db catalog. Tracks are recorded with TRACK numbers and times of their part.
+func (opt *cueOptions) recordCue(part []cuePart, trackFilePath []string,
+	dur []Timestamp, title []string, start []Timestamp) (err error) {
+	var b strings.Builder
+
+	hash := make([]string, len(trackFilePath))
+	for i, path := range trackFilePath {
+		if hash[i], err = fileSHA256(path); err != nil {
+			return fmt.Errorf("record cue: %w", err)
+		}
+	}
+	b.WriteString(dbSchema)
+	b.WriteString("BEGIN;\n")
+	for _, p := range part {
+		fmt.Fprintf(&b, "INSERT INTO cue (path, title, created) VALUES (%v, %v, datetime('now'));\n",
+			sqlPath(p.path), sqlQuote(p.title))
+		for i, path := range trackFilePath {
+			fmt.Fprintf(&b, "INSERT INTO source VALUES ((SELECT max(id) FROM cue), %v, %v, %v);\n",
+				sqlPath(path), sqlQuote(hash[i]), formatTimeSec(dur[i]))
+		}
+		for i := p.lo; i < p.hi; i++ {
+			fmt.Fprintf(&b, "INSERT INTO track VALUES ((SELECT max(id) FROM cue), %d, %v, %v);\n",
+				p.num+i-p.lo, sqlQuote(title[i]), formatTimeSec(start[i]-p.shift))
+		}
+	}
+	b.WriteString("COMMIT;\n")
+
+	if _, err = runSQL(opt.db, b.String()); err != nil {
+		return fmt.Errorf("record cue: %w", err)
+	}
+	return
+}
+
+func doCmdQuery(arg []string) {
+	var (
+		dbPath   string
+		hashPath string
+		where    []string
+		out      []byte
+		err      error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&dbPath, "db", "", "cue catalog database path")
+	fl.StringVar(&hashPath, "hash", "", "find cues made from this file")
+	parseFlags(fl, arg[1:])
+	if dbPath == "" {
+		panic("No database, use -db")
+	}
+	if _, err = os.Stat(dbPath); err != nil {
+		panic("Cannot open database: " + err.Error())
+	}
+
+	for _, s := range fl.Args() {
+		like := sqlQuote("%"+sqlLikeEscaper.Replace(s)+"%") + ` ESCAPE '\'`
+		where = append(where, fmt.Sprintf("(t.title LIKE %v OR c.title LIKE %v OR "+
+			"c.id IN (SELECT cue_id FROM source WHERE path LIKE %v))", like, like, like))
+	}
+	if hashPath != "" {
+		hash, err := fileSHA256(hashPath)
+		if err != nil {
+			panic("Cannot open input file: " + err.Error())
+		}
+		where = append(where, fmt.Sprintf(
+			"c.id IN (SELECT cue_id FROM source WHERE sha256 = %v)", sqlQuote(hash)))
+	}
+	if len(where) == 0 {
+		panic("No query, give text or -hash file")
+	}
+
+	query := fmt.Sprintf(`SELECT c.path AS cue, c.title AS album, t.num AS track,
+	t.title AS title, t.start AS start
+FROM track t JOIN cue c ON c.id = t.cue_id
+WHERE %v
+ORDER BY c.path, c.id, t.num;
+`, strings.Join(where, " AND "))
+	if outputFormat == outputJSON {
+		out, err = runSQL(dbPath, query, "-json")
+	} else {
+		out, err = runSQL(dbPath, query, "-separator", "\t")
+	}
+	panicIfError(err)
+	_, err = os.Stdout.Write(out)
+	panicIfError(err)
+}
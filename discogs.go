@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// doCmdDiscogs writes cue tracks as position,title,duration CSV used in
+// Discogs release submissions.
+func doCmdDiscogs(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		outFilePath   string
+		filter        trackFilter
+		cueRd         io.ReadCloser
+		outWr         io.WriteCloser
+		end           Timestamp = -1
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "cue audio file to get the last track length")
+	fl.StringVar(&outFilePath, "o", "", "output CSV file path")
+	addProbeFlags(fl)
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	label := parseCue(cueRd, cueAudioFile).label
+	if audioFilePath != "" {
+		end, err = getMediaDuration(audioFilePath)
+		panicIfError(err)
+	}
+	track := cueTrackLengths(label, end)
+	label, track = filter.apply(label), filter.applyLengths(label, track)
+
+	outWr = createOutput(outFilePath)
+	defer outWr.Close()
+	panicIfError(writeDiscogsCSV(outWr, label, track))
+}
+
+func writeDiscogsCSV(w io.Writer, label []cueLabel, track []trackLength) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"position", "title", "duration"})
+	for i, l := range label {
+		var dur string
+		if track[i].duration >= 0 {
+			dur = formatMinSec(track[i].duration)
+		}
+		cw.Write([]string{strconv.Itoa(l.num), l.title, dur})
+	}
+	cw.Flush()
+	return cw.Error()
+}
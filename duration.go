@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// durationBackend gets media file duration in microseconds. ffprobe, native
+// parsers and -cache are backends, so duration lookup can be swapped apart
+// from the probe options, like with a fake for testing.
+type durationBackend interface {
+	duration(ctx context.Context, filePath string) (Timestamp, error)
+}
+
+// ffprobeBackend probes duration with ffprobe, following -gapless, -exact and
+// -count options.
+type ffprobeBackend struct{}
+
+// nativeBackend reads duration of WAV, FLAC, Ogg and MP3 from the file.
+type nativeBackend struct{}
+
+// cacheBackend keeps durations got from its backend in -cache file between
+// runs.
+type cacheBackend struct {
+	durationBackend
+}
+
+// probeBackend returns backend selected by probe options.
+func probeBackend() durationBackend {
+	var b durationBackend = ffprobeBackend{}
+
+	if useNativeProbe() {
+		b = nativeBackend{}
+	}
+	if probeOpt.cache {
+		b = cacheBackend{b}
+	}
+	return b
+}
+
+// getBackendDuration returns media file duration from probe manifest,
+// durations probed before, or backend b, killing probe commands when ctx is
+// done.
+func getBackendDuration(ctx context.Context, b durationBackend, filePath string) (dur Timestamp,
+	err error) {
+	var ok bool
+
+	defer addProbeTiming(filePath, time.Now())
+	defer func() {
+		if err == nil {
+			if err = checkDuration(dur); err != nil {
+				err = fmt.Errorf("get media duration '%v': %w", filePath, err)
+			}
+		}
+	}()
+	if dur, ok = getManifestDuration(filePath); ok {
+		return
+	}
+	if dur, ok = getProbedDuration(filePath); ok {
+		return
+	}
+	if dur, err = b.duration(ctx, filePath); err == nil {
+		addProbedDuration(filePath, dur)
+	}
+	return
+}
+
+func (b cacheBackend) duration(ctx context.Context, filePath string) (dur Timestamp, err error) {
+	var ok bool
+
+	if dur, ok = getCachedDuration(filePath); ok {
+		return
+	}
+	if dur, err = b.durationBackend.duration(ctx, filePath); err == nil {
+		addCachedDuration(filePath, dur)
+	}
+	return
+}
+
+func (nativeBackend) duration(ctx context.Context, filePath string) (Timestamp, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("get native duration: %w", err)
+	}
+	return getNativeDuration(filePath)
+}
+
+// duration runs ffprobe until ctx is done. Gapless info is read from the
+// file, which is not stopped once started.
+func (ffprobeBackend) duration(ctx context.Context, filePath string) (dur Timestamp, err error) {
+	var (
+		out []byte
+		js  struct {
+			Format struct {
+				Duration *string `json:"duration"`
+				Start    *string `json:"start_time"`
+			} `json:"format"`
+		}
+		start Timestamp
+	)
+
+	if probeOpt.gapless {
+		if err = ctx.Err(); err != nil {
+			return 0, fmt.Errorf("get media duration: %w", err)
+		}
+		if dur, err = getGaplessDuration(filePath); err != nil || dur > 0 {
+			return
+		}
+	}
+	if probeOpt.exact || isVideoFile(filePath) {
+		if dur, err = getStreamDuration(ctx, filePath); err != nil || dur > 0 {
+			return
+		}
+	}
+
+	out, err = runCommandContext(ctx, "ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-i", filePath)
+	if err != nil {
+		err = fmt.Errorf("get media duration: ffprobe: %w", err)
+		return
+	}
+
+	err = json.Unmarshal(out, &js)
+	if err != nil {
+		err = fmt.Errorf("get media duration: %w", err)
+		return
+	}
+
+	if js.Format.Duration == nil {
+		if dur, err = getStreamDuration(ctx, filePath); err != nil || dur > 0 {
+			return
+		}
+		if probeOpt.count {
+			if dur, err = getPacketDuration(ctx, filePath); err != nil || dur > 0 {
+				return
+			}
+		}
+		err = errors.New("get media duration: no 'duration' field in JSON")
+		return
+	}
+	dur, err = parseToolTime(*js.Format.Duration)
+	if err != nil {
+		err = fmt.Errorf("get media duration: 'duration': %w", err)
+		return
+	}
+
+	if js.Format.Start != nil {
+		start, err = parseToolTime(*js.Format.Start)
+		if err != nil {
+			err = fmt.Errorf("get media duration: 'start_time': %w", err)
+			return
+		}
+		if start > 0 {
+			dur -= start
+		}
+	}
+	if dur <= 0 {
+		err = fmt.Errorf("get media duration: wrong value: %v", dur)
+		return
+	}
+	return
+}
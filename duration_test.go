@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingRunner runs commands until ctx is done.
+type blockingRunner struct{}
+
+func (blockingRunner) Run(ctx context.Context, _ io.Reader, _ string,
+	_ ...string) (stdout, stderr []byte, err error) {
+	<-ctx.Done()
+	return nil, []byte("killed\n"), errors.New("signal: killed")
+}
+
+func TestBackendDuration(t *testing.T) {
+	setRunner(t, &fakeRunner{stdout: `{"format":{"duration":"61.5"}}`})
+	dur, err := getBackendDuration(context.Background(), ffprobeBackend{}, "duration.flac")
+	if err != nil || dur != 61500000 {
+		t.Errorf("duration = %v, %v, want 61500000", dur, err)
+	}
+}
+
+func TestBackendDurationTimeout(t *testing.T) {
+	setRunner(t, blockingRunner{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := getBackendDuration(ctx, ffprobeBackend{}, "timeout.flac")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("duration error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestReadWavInfoLongChunk(t *testing.T) {
+	chunk := func(id string, b []byte) []byte {
+		return append(binary.LittleEndian.AppendUint32([]byte(id), uint32(len(b))), b...)
+	}
+	// INAM claims 4 GiB in LIST of 12 bytes
+	info := append([]byte("INFOINAM"), 0xf0, 0xff, 0xff, 0xff, 'a', 'b', 'c', 0)
+	fmtChunk := []byte{1, 0, 2, 0, 0x44, 0xac, 0, 0, 0x10, 0xb1, 2, 0, 4, 0, 16, 0}
+	body := append([]byte("WAVE"), chunk("fmt ", fmtChunk)...)
+	body = append(body, chunk("LIST", info)...)
+	body = append(body, chunk("data", make([]byte, 16))...)
+	path := filepath.Join(t.TempDir(), "long.wav")
+	if err := os.WriteFile(path, chunk("RIFF", body), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = readWavFile(f); err == nil {
+		t.Error("readWavFile accepted LIST INFO chunk longer than LIST")
+	}
+}
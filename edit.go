@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cueEditRe matches set command assignment like TITLE=x, TRACK3.TITLE=x or
+// REM.DATE=x.
+var cueEditRe = regexp.MustCompile(`(?i)^(?:TRACK(\d+)\.)?((?:REM\.)?[A-Z][A-Z0-9_-]*)=(.*)$`)
+
+// cueEdit sets command cmd, like TITLE or REM DATE, of disc or track to
+// value. Empty value removes the command.
+type cueEdit struct {
+	track int // 0 for disc
+	cmd   string
+	value string
+}
+
+func parseCueEdit(s string) (e cueEdit, err error) {
+	m := cueEditRe.FindStringSubmatch(s)
+	if m == nil {
+		return e, fmt.Errorf("expected FIELD=value or TRACKn.FIELD=value: %v", s)
+	}
+	if m[1] != "" {
+		if e.track, err = strconv.Atoi(m[1]); err != nil || e.track < 1 || e.track > maxCueTracks {
+			return e, fmt.Errorf("wrong track number: %v", m[1])
+		}
+	}
+	e.cmd, e.value = strings.ToUpper(m[2]), m[3]
+	if strings.ContainsAny(e.value, "\"\r\n") {
+		return e, fmt.Errorf("%v: value cannot contain quotes or line breaks", m[2])
+	}
+	switch e.cmd {
+	case "TITLE", "PERFORMER", "SONGWRITER":
+	case "CATALOG":
+		if e.track > 0 {
+			return e, fmt.Errorf("CATALOG is disc field")
+		}
+		if e.value != "" {
+			e.value, err = normalizeCatalog(e.value)
+		}
+	case "ISRC":
+		if e.track == 0 {
+			return e, fmt.Errorf("ISRC is track field")
+		}
+		if e.value != "" && !isrcRe.MatchString(e.value) {
+			err = fmt.Errorf("wrong ISRC: %v", e.value)
+		}
+	default:
+		rem, ok := strings.CutPrefix(e.cmd, "REM.")
+		if !ok {
+			return e, fmt.Errorf("unknown field %v, use REM.%v for comments", m[2], m[2])
+		}
+		e.cmd = "REM " + rem
+	}
+	return
+}
+
+// line returns cue line of the edit without indentation.
+func (e cueEdit) line() string {
+	switch {
+	case e.cmd == "CATALOG" || e.cmd == "ISRC":
+		return e.cmd + " " + e.value
+	case strings.HasPrefix(e.cmd, "REM ") && !strings.ContainsAny(e.value, " \t"):
+		return e.cmd + " " + e.value
+	}
+	// cue has no escapes, value is written as is
+	return e.cmd + ` "` + e.value + `"`
+}
+
+// applyCueEdit changes cue lines in place: the command line of disc or track
+// is replaced or removed, or added after TRACK line or before the first FILE.
+// Other lines, line endings and encoding are kept.
+func applyCueEdit(line [][]byte, e cueEdit) ([][]byte, error) {
+	var (
+		section      = 0  // disc, track number, or -1 for FILE header
+		found        = -1 // edited command line
+		insert       = -1 // line to add the command at
+		indent, eol  = "", "\n"
+		cmdField     = strings.Fields(e.cmd)
+		trackInCue   = false
+		endsWithLine = true
+	)
+
+	for i, l := range line {
+		f := strings.Fields(strings.TrimPrefix(string(l), "\ufeff"))
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "FILE":
+			if section == 0 && e.track == 0 {
+				insert = i
+			}
+			section = -1
+			continue
+		case "TRACK":
+			section = -1
+			if len(f) > 1 {
+				if n, err := strconv.Atoi(f[1]); err == nil {
+					section = n
+				}
+			}
+			if section == e.track && e.track > 0 {
+				trackInCue = true
+				insert = i + 1
+				indent = strings.TrimPrefix(lineIndent(l), "\ufeff") + "  "
+				if bytes.HasSuffix(l, []byte("\r\n")) {
+					eol = "\r\n"
+				}
+				endsWithLine = bytes.HasSuffix(l, []byte("\n"))
+			}
+			continue
+		}
+		if section != e.track || len(f) < len(cmdField) {
+			continue
+		}
+		match := true
+		for k, c := range cmdField {
+			match = match && strings.EqualFold(f[k], c)
+		}
+		if match && found < 0 {
+			found = i
+		}
+	}
+
+	switch {
+	case found >= 0 && e.value == "":
+		return append(line[:found], line[found+1:]...), nil
+	case found >= 0:
+		l := line[found]
+		end := l[len(bytes.TrimRight(l, "\r\n")):]
+		line[found] = append([]byte(lineIndent(l)+e.line()), end...)
+		return line, nil
+	case e.value == "":
+		return line, nil
+	case e.track > 0 && !trackInCue:
+		return nil, fmt.Errorf("no TRACK %d in cue", e.track)
+	}
+	if e.track == 0 {
+		if insert < 0 {
+			insert = len(line)
+		}
+		for _, l := range line {
+			if bytes.HasSuffix(l, []byte("\r\n")) {
+				eol = "\r\n"
+				break
+			}
+		}
+		if insert > 0 {
+			endsWithLine = bytes.HasSuffix(line[insert-1], []byte("\n"))
+		}
+	}
+	if !endsWithLine {
+		line[insert-1] = append(line[insert-1], eol...)
+	}
+	add := []byte(indent + e.line() + eol)
+	if insert == 0 && bytes.HasPrefix(line[0], []byte("\ufeff")) {
+		// byte order mark stays at the start
+		add = append([]byte("\ufeff"), add...)
+		line[0] = line[0][len("\ufeff"):]
+	}
+	return append(line[:insert], append([][]byte{add}, line[insert:]...)...), nil
+}
+
+// lineIndent returns leading white space of line with byte order mark.
+func lineIndent(l []byte) string {
+	s := strings.TrimPrefix(string(l), "\ufeff")
+	return string(l[:len(l)-len(strings.TrimLeft(s, " \t"))])
+}
+
+// doCmdSet edits disc and track fields of cue keeping the rest of it intact.
+func doCmdSet(arg []string) {
+	var (
+		cueFilePath string
+		outFilePath string
+		inPlace     bool
+		edit        []cueEdit
+		data        []byte
+		err         error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.StringVar(&outFilePath, "o", "", "output cue file path")
+	fl.BoolVar(&inPlace, "w", false, "write result to input cue file")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() == 0 {
+		panic("Expected FIELD=value or TRACKn.FIELD=value")
+	}
+	for _, a := range fl.Args() {
+		e, err := parseCueEdit(a)
+		if err != nil {
+			panic("Wrong field: " + err.Error())
+		}
+		edit = append(edit, e)
+	}
+	if inPlace && (outFilePath != "" || isStdio(cueFilePath)) {
+		panic("Option -w requires input cue file and no -o")
+	}
+
+	in := openInput(cueFilePath)
+	data, err = io.ReadAll(in)
+	in.Close()
+	if err != nil {
+		panic("Read cue " + inputName(in) + ": " + err.Error())
+	}
+	line := bytes.SplitAfter(data, []byte("\n"))
+	if len(line[len(line)-1]) == 0 {
+		line = line[:len(line)-1]
+	}
+	for _, e := range edit {
+		if line, err = applyCueEdit(line, e); err != nil {
+			panic("Cannot set field: " + err.Error())
+		}
+	}
+
+	var out io.WriteCloser
+	if inPlace {
+		out = replaceOutput(cueFilePath)
+	} else {
+		out = createOutput(outFilePath)
+	}
+	_, err = out.Write(bytes.Join(line, nil))
+	panicIfError(err)
+	panicIfError(out.Close())
+}
@@ -1,16 +1,75 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
+const (
+	logText = "text"
+	logJSON = "json"
+)
+
+// logFormat is set by the global -log-format option.
+var logFormat = logText
+
+// logCommand is the running command for log entries.
+var logCommand struct {
+	name  string
+	start time.Time
+}
+
+var logMutex sync.Mutex
+
+// logEntry is diagnostic message, written as text line or JSON line.
+type logEntry struct {
+	Time     string    `json:"time,omitempty"`
+	Level    string    `json:"level"`
+	Command  string    `json:"command,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Line     int       `json:"line,omitempty"`
+	Message  string    `json:"message"`
+	Duration *jsonTime `json:"duration,omitempty"`
+}
+
+func (e logEntry) write() {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	if logFormat != logJSON {
+		prefix := map[string]string{"error": "Error: ", "warning": "Warning: "}[e.Level]
+		if e.Line > 0 {
+			prefix += fmt.Sprintf("%v:%d: ", e.File, e.Line)
+		}
+		fmt.Fprintln(os.Stderr, prefix+e.Message)
+		return
+	}
+	e.Command = logCommand.name
+	// time varies between runs
+	switch {
+	case deterministic || logCommand.start.IsZero():
+		e.Duration = nil
+	case e.Level == "error" || e.Duration != nil:
+		d := jsonTime(time.Since(logCommand.start).Microseconds())
+		e.Duration = &d
+	}
+	if !deterministic {
+		e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal(e)
+	fmt.Fprintln(os.Stderr, string(b))
+}
+
 func checkPanic() {
 	if r := recover(); r != nil {
 		switch r.(type) {
 		case string:
 			logErrorMessage(r.(string))
 			os.Exit(1)
+		case helpShown:
 		default:
 			panic(r)
 		}
@@ -31,10 +90,37 @@ func logIfError(err error) {
 
 func logErrorMessage(msg string) {
 	if msg != "" {
-		logMessage("Error: " + msg)
+		logEntry{Level: "error", Message: msg}.write()
 	}
 }
 
+func logWarningMessage(msg string) {
+	logEntry{Level: "warning", Message: msg}.write()
+}
+
+// logFileWarning logs warning about file, at line if it is positive.
+func logFileWarning(file string, line int, msg string) {
+	logEntry{Level: "warning", File: file, Line: line, Message: msg}.write()
+}
+
 func logMessage(msg string) {
-	fmt.Fprintln(os.Stderr, msg)
+	logEntry{Level: "info", Message: msg}.write()
+}
+
+// logLines logs every line of text, like output of other programs.
+func logLines(text string) {
+	if text = strings.TrimRight(text, "\r\n"); text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		logMessage(strings.TrimRight(line, "\r"))
+	}
+}
+
+// logCommandDone logs successful command with its run time with
+// -log-format json.
+func logCommandDone() {
+	if logFormat == logJSON {
+		logEntry{Level: "info", Message: "done", Duration: new(jsonTime)}.write()
+	}
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// trackFilter selects cue tracks by number ranges and title regexp.
+type trackFilter struct {
+	tracks   string
+	match    string
+	maxTrack int // highest track number of -tracks, maxCueTracks if 0
+}
+
+func (f *trackFilter) addFlags(fl *flag.FlagSet) {
+	fl.StringVar(&f.tracks, "tracks", "", "track numbers to select, e.g. 3-7,10")
+	fl.StringVar(&f.match, "match", "", "regexp matching titles to select")
+}
+
+func (f *trackFilter) apply(label []cueLabel) (sel []cueLabel) {
+	for _, i := range f.selected(label) {
+		sel = append(sel, label[i])
+	}
+	if len(sel) == 0 {
+		panic("No tracks selected")
+	}
+	return
+}
+
+// applyLengths selects lengths computed for the unfiltered label list.
+func (f *trackFilter) applyLengths(label []cueLabel, track []trackLength) (sel []trackLength) {
+	for _, i := range f.selected(label) {
+		sel = append(sel, track[i])
+	}
+	if len(sel) == 0 {
+		panic("No tracks selected")
+	}
+	return
+}
+
+// selected returns indexes of selected labels in order.
+func (f *trackFilter) selected(label []cueLabel) (sel []int) {
+	match := f.matcher()
+	for i, l := range label {
+		if match(l) {
+			sel = append(sel, i)
+		}
+	}
+	return
+}
+
+// matcher returns function reporting whether label is selected, for labels
+// checked one by one.
+func (f *trackFilter) matcher() func(l cueLabel) bool {
+	var (
+		matchRe *regexp.Regexp
+		ranges  []trackRange
+		err     error
+	)
+
+	if f.match != "" {
+		if matchRe, err = regexp.Compile(f.match); err != nil {
+			panic("Wrong -match regexp: " + err.Error())
+		}
+	}
+	if f.tracks != "" {
+		ranges = parseTrackRanges(f.tracks, cmp.Or(f.maxTrack, maxCueTracks))
+	}
+	return func(l cueLabel) bool {
+		return (ranges == nil || slices.ContainsFunc(ranges, func(r trackRange) bool {
+			return r.first <= l.num && l.num <= r.last
+		})) && (matchRe == nil || matchRe.MatchString(l.title))
+	}
+}
+
+// trackRange is inclusive range of track numbers.
+type trackRange struct {
+	first, last int
+}
+
+// parseTrackRanges parses comma separated track numbers and ranges of tracks
+// 1 to maxTrack.
+func parseTrackRanges(s string, maxTrack int) (ranges []trackRange) {
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(r), "-")
+		first, err1 := strconv.Atoi(from)
+		last, err2 := first, error(nil)
+		if isRange {
+			last, err2 = strconv.Atoi(to)
+		}
+		if err1 != nil || err2 != nil || first < 1 || last < first {
+			panic("Wrong track range: '" + r + "'")
+		}
+		if last > maxTrack {
+			panic(fmt.Sprintf("Wrong track range: '%v', tracks are 1 to %d", r, maxTrack))
+		}
+		ranges = append(ranges, trackRange{first, last})
+	}
+	return
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrackFilterMatcher(t *testing.T) {
+	match := (&trackFilter{tracks: "2-4, 7,99"}).matcher()
+	for n, want := range map[int]bool{1: false, 2: true, 4: true, 5: false, 7: true, 98: false,
+		99: true} {
+		if got := match(cueLabel{num: n}); got != want {
+			t.Errorf("track %d selected = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestParseTrackRangesWrong(t *testing.T) {
+	for _, s := range []string{"0", "3-2", "1-100", "1-2000000000", "x", "1,"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("parseTrackRanges(%q) accepted", s)
+				}
+			}()
+			parseTrackRanges(s, maxCueTracks)
+		}()
+	}
+}
+
+func TestTrackFilterMaxTrack(t *testing.T) {
+	match := (&trackFilter{tracks: "99-101,250", maxTrack: math.MaxInt}).matcher()
+	for n, want := range map[int]bool{98: false, 99: true, 101: true, 102: false, 250: true} {
+		if got := match(cueLabel{num: n}); got != want {
+			t.Errorf("track %d selected = %v, want %v", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// getGaplessDuration returns sample-accurate duration from encoder delay and
+// padding info, or zero if the file has no such info.
+func getGaplessDuration(filePath string) (dur Timestamp, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp3":
+		dur, err = getLameDuration(filePath)
+	case ".m4a", ".m4b", ".mp4", ".aac":
+		dur, err = getITunSMPBDuration(filePath)
+	}
+	if err != nil {
+		err = fmt.Errorf("get gapless duration: %w", err)
+	}
+	return
+}
+
+var mp3SampleRate = [3]int{44100, 48000, 32000}
+
+type mp3Info struct {
+	rate    int
+	spf     int // samples per frame
+	frames  int64
+	delay   int64
+	padding int64
+	lame    bool
+}
+
+// getLameDuration returns duration with LAME encoder delay and padding
+// removed, or zero if the file has no LAME tag.
+func getLameDuration(filePath string) (dur Timestamp, err error) {
+	var info mp3Info
+
+	if info, err = readMP3Info(filePath); err != nil || !info.lame {
+		return
+	}
+	return info.duration(filePath)
+}
+
+func (info *mp3Info) duration(filePath string) (Timestamp, error) {
+	samples := info.frames*int64(info.spf) - info.delay - info.padding
+	if samples <= 0 {
+		return 0, fmt.Errorf("'%v': wrong MP3 sample count: %v", filePath, samples)
+	}
+	return SamplesTime(samples, int64(info.rate)), nil
+}
+
+// readMP3Info reads the Xing/Info frame count and LAME encoder delay and
+// padding from the first MP3 frame. Frame count is zero if there is no
+// Xing/Info header.
+func readMP3Info(filePath string) (info mp3Info, err error) {
+	var (
+		buf      []byte
+		pos      int
+		hdr      uint32
+		version  int // 1, 2 or 25 for MPEG 2.5
+		sideInfo int
+		flags    uint32
+	)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	buf = make([]byte, 64*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return
+	}
+	buf, err = buf[:n], nil
+
+	// skip ID3v2 tag
+	if len(buf) >= 10 && string(buf[:3]) == "ID3" {
+		size := int(buf[6])<<21 | int(buf[7])<<14 | int(buf[8])<<7 | int(buf[9])
+		pos = 10 + size
+		if buf[5]&0x10 != 0 {
+			pos += 10
+		}
+		if pos >= len(buf) {
+			if _, err = f.Seek(int64(pos), io.SeekStart); err != nil {
+				return
+			}
+			n, err = io.ReadFull(f, buf[:cap(buf)])
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return
+			}
+			buf, pos, err = buf[:n], 0, nil
+		}
+	}
+
+	for ; pos+4 <= len(buf); pos++ {
+		if buf[pos] == 0xff && buf[pos+1]&0xe0 == 0xe0 {
+			break
+		}
+	}
+	if pos+4 > len(buf) {
+		return
+	}
+	hdr = binary.BigEndian.Uint32(buf[pos:])
+	switch (hdr >> 19) & 3 {
+	case 0:
+		version = 25
+	case 2:
+		version = 2
+	case 3:
+		version = 1
+	default:
+		return
+	}
+	if (hdr>>17)&3 != 1 { // layer III
+		return
+	}
+	rateIndex := (hdr >> 10) & 3
+	if rateIndex == 3 {
+		return
+	}
+	info.rate = mp3SampleRate[rateIndex]
+	mono := (hdr>>6)&3 == 3
+	if version == 1 {
+		info.spf = 1152
+		sideInfo = 32
+		if mono {
+			sideInfo = 17
+		}
+	} else {
+		info.rate /= 2
+		if version == 25 {
+			info.rate /= 2
+		}
+		info.spf = 576
+		sideInfo = 17
+		if mono {
+			sideInfo = 9
+		}
+	}
+
+	p := pos + 4 + sideInfo
+	if p+8 > len(buf) {
+		return
+	}
+	if tag := string(buf[p : p+4]); tag != "Xing" && tag != "Info" {
+		return
+	}
+	flags = binary.BigEndian.Uint32(buf[p+4:])
+	p += 8
+	if flags&1 == 0 {
+		return
+	}
+	info.frames = int64(binary.BigEndian.Uint32(buf[p:]))
+	p += 4
+	if flags&2 != 0 {
+		p += 4
+	}
+	if flags&4 != 0 {
+		p += 100
+	}
+	if flags&8 != 0 {
+		p += 4
+	}
+	// LAME tag: 9 bytes encoder version, delay and padding at offset 21
+	if p+24 > len(buf) || !bytes.HasPrefix(buf[p:], []byte("LAME")) &&
+		!bytes.HasPrefix(buf[p:], []byte("Lavc")) {
+		return
+	}
+	info.delay = int64(buf[p+21])<<4 | int64(buf[p+22])>>4
+	info.padding = int64(buf[p+22]&0x0f)<<8 | int64(buf[p+23])
+	info.lame = true
+	return
+}
+
+// getITunSMPBDuration gets original sample count from iTunes gapless tag.
+func getITunSMPBDuration(filePath string) (dur Timestamp, err error) {
+	var (
+		out []byte
+		js  struct {
+			Streams []struct {
+				SampleRate string `json:"sample_rate"`
+			} `json:"streams"`
+			Format struct {
+				Tags map[string]string `json:"tags"`
+			} `json:"format"`
+		}
+		smpb    string
+		rate    int64
+		samples uint64
+	)
+
+	out, err = runCommand("ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=sample_rate:format_tags",
+		"-i", filePath)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		return
+	}
+	for _, k := range slices.Sorted(maps.Keys(js.Format.Tags)) {
+		if strings.EqualFold(k, "iTunSMPB") {
+			smpb = js.Format.Tags[k]
+			break
+		}
+	}
+	field := strings.Fields(smpb)
+	if len(field) < 4 || len(js.Streams) == 0 {
+		return
+	}
+	if samples, err = strconv.ParseUint(field[3], 16, 64); err != nil {
+		return 0, fmt.Errorf("'%v': iTunSMPB: %w", filePath, err)
+	}
+	if rate, err = strconv.ParseInt(js.Streams[0].SampleRate, 10, 64); err != nil || rate <= 0 {
+		return 0, fmt.Errorf("'%v': wrong sample rate", filePath)
+	}
+	return SamplesTime(int64(samples), rate), nil
+}
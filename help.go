@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// help and -h are registered in init to break initialization cycle with
+// commandTab.
+func init() {
+	commandTab["help"] = doCmdHelp
+	commandTab["-h"] = doCmdHelp
+}
+
+// commandHelp is command synopsis and examples shown by help, in help
+// order.
+type commandHelp struct {
+	name    string
+	args    string // options and arguments, continued on lines indented by 13
+	summary string
+	example []string
+}
+
+const globalUsage = `cue-maker [-format text|json -deterministic -timings -max-duration sec -strict
+           -decimal auto|point|comma -names windows|posix|conservative
+           -log-format text|json -f -n]
+          command [args]`
+
+const optionGroups = `cue_options:   -title title -performer name -date date -infer-meta
+               -meta-pattern pattern -file name
+               -file-path basename|relative|absolute -num start -shift time -shift-f file...
+               -cover image -overlap sec -precise -translit -truncate -catalog ean
+               -isrc-base isrc -flags [track:]flag,... -collate locale
+               -order file -reverse
+               -from-playlist m3u_file -nfo file -db db_file -skip-errors -validate-audio
+               -fingerprint -dedupe-boundary title_options probe_options
+title_options: -denum -underscores -collapse-space -strip-brackets -title-case
+               -nfc -nfd -num-format format -dedup -clean
+probe_options: -gapless -exact -count -native -manifest file -sidecar ext
+               -cache -refresh pattern
+filter_options: -tracks 1-3,5 -match regexp`
+
+var commandHelps = []commandHelp{
+	{"cue", `[-o cue_file -chapters ffmeta_file -rollover -expand-chapters -chapter-index
+             -sides n,... -side-len len,... -side letter -split-sides
+             cue_options] tracks...`,
+		"Make cue sheet of tracks played back-to-back.",
+		[]string{"cue-maker cue -o album.cue *.flac",
+			"cue-maker cue -o concert.cue -chapters concert.ffmeta *.mp4"}},
+	{"all", `-o base_path [-emit cue,labels,m3u,ffmeta,concat cue_options] tracks...`,
+		"Probe tracks once and write cue, labels, playlist and other files.",
+		[]string{"cue-maker all -o album -emit cue,concat *.wav"}},
+	{"label", `[-i cue_file -a audio_file_index|all -file-name name -o label_file|template
+             -cumulative -file-len len,... -dedupe-boundary -end-label -end len
+             -freq -subindex probe_options -num start -num-digits digits
+             -num-format format -index 00|01 -title-format template filter_options]`,
+		"Make Audacity label file of cue tracks.",
+		[]string{"cue-maker label -i album.cue -o label.txt"}},
+	{"split", `[-i cue_file -a audio_file_index -o dir|template -ext ext -cover image
+             -codec encoder -compression level -b:a bitrate -meta csv_file
+             -loudnorm -lufs lufs -jobs n filter_options probe_options] audio_file`,
+		"Split audio file to cue tracks with ffmpeg.",
+		[]string{"cue-maker split -i album.cue -o tracks -loudnorm album.flac",
+			"cue-maker split -i album.cue -o '{{.Album}}/{{.Num}} - {{.Title}}.flac' album.flac",
+			"cue-maker split -i album.cue -o tracks -codec libopus -b:a 160k album.wav"}},
+	{"points", `[-i cue_file -a audio_file_index -audio file -o out_file
+             -unit sec|ms|cue|hms|samples -rate hz filter_options probe_options]`,
+		"Print start, end and duration of cue tracks for cutting by hand.",
+		[]string{"cue-maker points -i album.cue -audio album.flac -unit hms"}},
+	{"label2cue", `[-i label_file -o cue_file -file name -title title -performer name -denum]`,
+		"Make cue from Audacity label file.",
+		[]string{"cue-maker label2cue -i label.txt -file album.flac -denum -o album.cue"}},
+	{"simple2cue", `[-i index_file -o cue_file -file name -title title -performer name
+             -time auto|hms|cue|sec -col n -sep sep -denum]`,
+		"Make cue from lines of start time and title, like '03:21 Title'.",
+		[]string{"cue-maker simple2cue -i chapters.txt -file video.mp4 -o video.cue"}},
+	{"len", `[-i cue_file -a audio_file_index -audio file -points filter_options
+             probe_options] [tracks...]`,
+		"Print lengths of cue tracks or track files.",
+		[]string{"cue-maker len -i album.cue -audio album.flac"}},
+	{"verify-times", `-i cue_file [-a audio_file_index -audio file -tol sec
+             probe_options] tracks...`,
+		"Check cue track times match track file durations.",
+		[]string{"cue-maker verify-times -i album.cue *.flac"}},
+	{"verify-merge", `-m merged_file [-i cue_file -a audio_file_index -tol sec
+             probe_options] tracks...`,
+		"Check merged file duration is the sum of tracks and cue indexes are inside it.",
+		[]string{"cue-maker verify-merge -i album.cue -m album.mka *.flac"}},
+	{"convert", `-via converter [-i cue_file -a audio_file_index -o out_file
+             filter_options] [converter_args...]`,
+		"Pass cue as JSON to external converter cue-maker-name.",
+		[]string{"cue-maker convert -via chapters -i album.cue -o album.txt"}},
+	{"discogs", `[-i cue_file -a audio_file_index -audio file -o csv_file
+             filter_options probe_options]`,
+		"Write cue tracks as Discogs tracklist CSV.",
+		[]string{"cue-maker discogs -i album.cue -audio album.flac -o tracks.csv"}},
+	{"id3chap", `[-i cue_file -a audio_file_index probe_options] mp3_file`,
+		"Write cue tracks to MP3 file as ID3v2 chapters.",
+		[]string{"cue-maker id3chap -i album.cue album.mp3"}},
+	{"vorbischap", `[-i cue_file -a audio_file_index -o out_file filter_options]
+   vorbischap -import comments_or_audio_file [-o cue_file]`,
+		"Write cue tracks as Vorbis comment chapters, or make cue from them.",
+		[]string{"cue-maker vorbischap -i album.cue -o chapters.txt",
+			"cue-maker vorbischap -import album.opus -o album.cue"}},
+	{"burn", `-o base_path [-i cue_file -a audio_file_index -wav probe_options]
+             audio_file`,
+		"Make CD-R image of cue audio with sector aligned cue.",
+		[]string{"cue-maker burn -i album.cue -o image album.flac"}},
+	{"mixxx", `[-i cue_file -a audio_file_index -audio file -db mixxxdb.sqlite|-o sql_file
+             -rate hz -replace filter_options probe_options]`,
+		"Set cue tracks as hot cues of the audio in Mixxx library.",
+		[]string{"cue-maker mixxx -i set.cue -db ~/.mixxx/mixxxdb.sqlite -replace"}},
+	{"accuraterip", `-audio file [-i cue_file -a audio_file_index -ids -query
+             probe_options]`,
+		"Print AccurateRip disc ID and checksums of cue tracks.",
+		[]string{"cue-maker accuraterip -i album.cue -audio album.flac -query"}},
+	{"merge-titles", `-times cue_file -titles cue_file [-times-a audio_file_index
+             -titles-a audio_file_index -by number|title -o cue_file]`,
+		"Take track times from one cue and titles from another.",
+		[]string{"cue-maker merge-titles -times rip.cue -titles discogs.cue -by title -o album.cue"}},
+	{"check", `[-i cue_file]`,
+		"Check cue for errors and common problems.",
+		[]string{"cue-maker check -i album.cue"}},
+	{"set", `[-i cue_file -o cue_file -w] FIELD=value|TRACKn.FIELD=value...`,
+		"Set disc and track fields of cue keeping the rest intact.",
+		[]string{`cue-maker set -i album.cue -w TITLE="New Title" TRACK3.TITLE="Fixed"`}},
+	{"retime", `-cut regions_file|-detect audio [-i cue_file -a audio_file_index -o cue_file
+             -keep len -min-silence len -noise dB -audio trimmed_audio probe_options]`,
+		"Move cue track times after parts of audio were removed.",
+		[]string{"cue-maker retime -i album.cue -cut removed.txt -o trimmed.cue"}},
+	{"cdtext", `-o cdt_file [-i cue_file -a audio_file_index]`,
+		"Write CD-TEXT file of cue titles and performers.",
+		[]string{"cue-maker cdtext -i album.cue -o album.cdt"}},
+	{"tagfiles", `-i cue_file [-a audio_file_index -print -jobs n] tracks...`,
+		"Tag track files with cue titles and performers.",
+		[]string{"cue-maker tagfiles -i album.cue *.flac"}},
+	{"audiobook", `-o m4b_file [-title title -cover image -b bitrate title_options
+             probe_options] chapters...`,
+		"Make M4B audiobook of chapter files.",
+		[]string{"cue-maker audiobook -o book.m4b -cover cover.jpg -denum *.mp3"}},
+	{"run", `job_file`,
+		"Run commands of JSON or YAML job file.",
+		[]string{"cue-maker run album.yaml"}},
+	{"probe", `[-o manifest_file probe_options] tracks...`,
+		"Write probe manifest of track durations to reuse with -manifest.",
+		[]string{"cue-maker probe -o manifest.json *.flac"}},
+	{"query", `-db db_file [-hash file] [text...]`,
+		"Search SQLite catalog of cues.",
+		[]string{`cue-maker query -db music.db "track title"`}},
+	{"sec2cue", `seconds... | -csv [-col n -sep , -header -i file -o file]`,
+		"Convert seconds to cue times.",
+		[]string{"cue-maker sec2cue 201.5 1234"}},
+	{"cue2sec", `cue_times... | -csv [-col n -sep , -header -i file -o file]`,
+		"Convert cue times to seconds.",
+		[]string{"cue-maker cue2sec 03:21:00"}},
+	{"timecalc", `[-to cue|sec] add|sub times...`,
+		"Add or subtract times.",
+		[]string{"cue-maker timecalc add 03:21:00 00:02:33"}},
+	{"completion", `bash|zsh|fish|powershell`,
+		"Write shell completion script.",
+		[]string{"cue-maker completion bash >/etc/bash_completion.d/cue-maker"}},
+	{"cache", `stats | clear [patterns...]`,
+		"Show or clear duration cache of -cache.",
+		[]string{"cue-maker cache clear '*.flac'"}},
+	{"selftest", `[-tracks n -seed n]`,
+		"Round-trip generated tracks through cue, labels, JSON and Vorbis chapters.",
+		[]string{"cue-maker -decimal comma selftest"}},
+	{"version", ``,
+		"Print version and available tools.",
+		[]string{"cue-maker version"}},
+	{"help", `[command]`,
+		"Print usage, or options and examples of command.",
+		[]string{"cue-maker help label"}},
+}
+
+func findCommandHelp(name string) (commandHelp, bool) {
+	for _, h := range commandHelps {
+		if h.name == name {
+			return h, true
+		}
+	}
+	return commandHelp{}, false
+}
+
+// usageText returns synopsis of all commands.
+func usageText() string {
+	var b strings.Builder
+
+	b.WriteString(globalUsage + "\n")
+	for _, h := range commandHelps {
+		fmt.Fprintf(&b, "   %-8s %v\n", h.name, h.args)
+	}
+	b.WriteString("\n" + optionGroups + "\n\nRun 'cue-maker help command' for its options and examples.")
+	return b.String()
+}
+
+// writeCommandHelp writes command synopsis, summary, options of fl if it is
+// not nil, and examples.
+func writeCommandHelp(w io.Writer, name string, fl *flag.FlagSet) {
+	h, _ := findCommandHelp(name)
+	prefix := "Usage: cue-maker " + name + " "
+	args := strings.ReplaceAll(h.args, "\n"+strings.Repeat(" ", 13),
+		"\n"+strings.Repeat(" ", len(prefix)))
+	// other forms of command
+	args = strings.ReplaceAll(args, "\n   "+name+" ", "\n       cue-maker "+name+" ")
+	fmt.Fprintln(w, strings.TrimSpace(prefix+args))
+	if h.summary != "" {
+		fmt.Fprintf(w, "\n%v\n", h.summary)
+	}
+	if fl != nil {
+		n := 0
+		fl.VisitAll(func(*flag.Flag) { n++ })
+		if n > 0 {
+			fmt.Fprintf(w, "\nOptions:\n")
+			fl.SetOutput(w)
+			fl.PrintDefaults()
+			fl.SetOutput(io.Discard)
+		}
+	}
+	if len(h.example) > 0 {
+		fmt.Fprintf(w, "\nExamples:\n  %v\n", strings.Join(h.example, "\n  "))
+	}
+}
+
+// helpShown panics stop command after its help was printed on -h.
+type helpShown struct{}
+
+// parseFlags parses command options. With -h command help is printed and
+// command stops, wrong options stop it with error.
+func parseFlags(fl *flag.FlagSet, arg []string) {
+	err := fl.Parse(arg)
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		// completion lists options without printing them
+		if completionFlags == nil {
+			if logCommand.name == "" {
+				fmt.Fprintln(os.Stdout, usageText())
+			} else {
+				writeCommandHelp(os.Stdout, logCommand.name, fl)
+			}
+		}
+		panic(helpShown{})
+	case logCommand.name == "":
+		panic(err.Error() + ", see 'cue-maker -h'")
+	default:
+		panic(fmt.Sprintf("%v, see 'cue-maker help %v'", err, logCommand.name))
+	}
+}
+
+// suggestCommand returns known command closest to misspelled name, or ""
+// if none is close.
+func suggestCommand(name string) (best string) {
+	bestDist := max(len(name)/3, 2)
+	for cmd := range commandTab {
+		if strings.HasPrefix(cmd, "-") {
+			continue
+		}
+		d := editDistance([]rune(name), []rune(cmd))
+		if d < bestDist || d == bestDist && (best == "" || cmd < best) {
+			best, bestDist = cmd, d
+		}
+	}
+	return
+}
+
+// unknownCommand returns error message of unknown command with suggestion.
+func unknownCommand(name string) string {
+	msg := "no such command: '" + name + "'"
+	if s := suggestCommand(name); s != "" {
+		msg += ", did you mean '" + s + "'?"
+	}
+	return msg
+}
+
+// doCmdHelp prints usage of all commands, or options and examples of one.
+func doCmdHelp(arg []string) {
+	fl := newFlagSet()
+	parseFlags(fl, arg[1:])
+	switch fl.NArg() {
+	case 0:
+		fmt.Fprintln(os.Stdout, usageText())
+	case 1:
+		name := fl.Arg(0)
+		if _, ok := commandTab[name]; !ok {
+			panic(unknownCommand(name))
+		}
+		writeCommandHelp(os.Stdout, name, commandFlags(name))
+	default:
+		panic("Expected one command")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	id3HeaderLen   = 10
+	id3MaxChapters = 255 // CTOC entry count is one byte
+)
+
+// doCmdID3Chapters writes cue tracks to MP3 file as ID3v2 CHAP frames with
+// CTOC table of contents. Other frames of the existing tag are kept.
+func doCmdID3Chapters(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		cueRd        io.ReadCloser
+		end          Timestamp
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 1 {
+		panic("Expected one MP3 file")
+	}
+	mp3FilePath := fl.Arg(0)
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	label := parseCue(cueRd, cueAudioFile).label
+	if len(label) == 0 {
+		panic("No tracks in cue")
+	}
+	if len(label) > id3MaxChapters {
+		panic(fmt.Sprintf("ID3 table of contents supports at most %d chapters", id3MaxChapters))
+	}
+	end, err = getMediaDuration(mp3FilePath)
+	panicIfError(err)
+
+	chap := make([]chapter, len(label))
+	for i, l := range label {
+		chap[i] = chapter{start: l.start, end: end, title: l.title}
+		if i < len(label)-1 {
+			chap[i].end = label[i+1].start
+		}
+	}
+	panicIfError(writeID3Chapters(mp3FilePath, chap))
+}
+
+// writeID3Chapters replaces CHAP and CTOC frames of file ID3v2 tag. File
+// without tag gets ID3v2.3 tag.
+func writeID3Chapters(filePath string, chap []chapter) (err error) {
+	var (
+		version byte
+		frame   [][]byte
+		tagLen  int64
+	)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("write ID3 chapters: %w", err)
+	}
+	defer f.Close()
+	if version, frame, tagLen, err = readID3Frames(f); err != nil {
+		return fmt.Errorf("write ID3 chapters: '%v': %w", filePath, err)
+	}
+	if version == 0 {
+		version = 3
+	}
+
+	var toc bytes.Buffer
+	toc.WriteString("toc\x00")
+	toc.WriteByte(0x03) // top-level, ordered
+	toc.WriteByte(byte(len(chap)))
+	for i, c := range chap {
+		id := fmt.Sprintf("chp%d", i+1)
+		fmt.Fprintf(&toc, "%v\x00", id)
+
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%v\x00", id)
+		binary.Write(&b, binary.BigEndian, [4]uint32{
+			uint32(c.start.Millis(RoundDown)), uint32(c.end.Millis(RoundDown)), 0xffffffff, 0xffffffff})
+		b.Write(id3Frame(version, "TIT2", id3Text(version, c.title)))
+		frame = append(frame, id3Frame(version, "CHAP", b.Bytes()))
+	}
+	frame = append(frame, id3Frame(version, "CTOC", toc.Bytes()))
+
+	out, err := os.CreateTemp(filepath.Dir(filePath), ".cue-maker-*.mp3")
+	if err != nil {
+		return fmt.Errorf("write ID3 chapters: %w", err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+
+	size := 0
+	for _, fr := range frame {
+		size += len(fr)
+	}
+	hdr := []byte{'I', 'D', '3', version, 0, 0}
+	hdr = binary.BigEndian.AppendUint32(hdr, syncSafe(uint32(size)))
+	if _, err = out.Write(hdr); err != nil {
+		return fmt.Errorf("write ID3 chapters: %w", err)
+	}
+	for _, fr := range frame {
+		if _, err = out.Write(fr); err != nil {
+			return fmt.Errorf("write ID3 chapters: %w", err)
+		}
+	}
+	if _, err = f.Seek(tagLen, io.SeekStart); err == nil {
+		_, err = io.Copy(out, f)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		if fi, e := f.Stat(); e == nil {
+			os.Chmod(out.Name(), fi.Mode())
+		}
+		err = os.Rename(out.Name(), filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("write ID3 chapters: %w", err)
+	}
+	return
+}
+
+// readID3Frames returns ID3v2 tag version, raw frames without CHAP and
+// CTOC, and tag length including header. Version is zero if there is no
+// tag.
+func readID3Frames(r io.Reader) (version byte, frame [][]byte, tagLen int64, err error) {
+	var hdr [id3HeaderLen]byte
+
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		return
+	}
+	if string(hdr[:3]) != "ID3" {
+		return
+	}
+	version = hdr[3]
+	if version != 3 && version != 4 {
+		return 0, nil, 0, fmt.Errorf("unsupported ID3v2.%d tag", version)
+	}
+	if hdr[5]&0xc0 != 0 {
+		return 0, nil, 0, errors.New("unsynchronized or extended ID3 header is not supported")
+	}
+	size := int64(unsyncSafe(binary.BigEndian.Uint32(hdr[6:])))
+	tagLen = id3HeaderLen + size
+	if hdr[5]&0x10 != 0 {
+		tagLen += id3HeaderLen // footer
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return 0, nil, 0, fmt.Errorf("read ID3 tag: %w", err)
+	}
+
+	for pos := 0; pos+id3HeaderLen <= len(data) && data[pos] != 0; {
+		id := string(data[pos : pos+4])
+		n := binary.BigEndian.Uint32(data[pos+4:])
+		if version == 4 {
+			n = unsyncSafe(n)
+		}
+		next := pos + id3HeaderLen + int(n)
+		if next > len(data) {
+			return 0, nil, 0, fmt.Errorf("ID3 frame %v is too long", id)
+		}
+		if id != "CHAP" && id != "CTOC" {
+			frame = append(frame, data[pos:next])
+		}
+		pos = next
+	}
+	return
+}
+
+func id3Frame(version byte, id string, data []byte) []byte {
+	size := uint32(len(data))
+	if version == 4 {
+		size = syncSafe(size)
+	}
+	fr := append([]byte(id), 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(fr[4:], size)
+	return append(fr, data...)
+}
+
+// id3Text returns text frame data: ISO-8859-1 if possible, UTF-8 in v2.4
+// or UTF-16 with BOM in v2.3.
+func id3Text(version byte, s string) []byte {
+	if strings.IndexFunc(s, func(r rune) bool { return r > 0xff }) < 0 {
+		b := []byte{0}
+		for _, r := range s {
+			b = append(b, byte(r))
+		}
+		return b
+	}
+	if version == 4 {
+		return append([]byte{3}, s...)
+	}
+	b := []byte{1, 0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func syncSafe(n uint32) uint32 {
+	return n&0x7f | n<<1&0x7f00 | n<<2&0x7f0000 | n<<3&0x7f000000
+}
+
+func unsyncSafe(n uint32) uint32 {
+	return n&0x7f | n>>1&0x3f80 | n>>2&0x1fc000 | n>>3&0xfe00000
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteID3Chapters(t *testing.T) {
+	chap := []chapter{
+		{0, 60500000, "Intro"},
+		{60500000, 125 * uSecInSecond, "Ωmega"},
+	}
+	for _, c := range []struct {
+		name, in, want string
+	}{
+		{"no tag", hex.EncodeToString([]byte("MP3DATA")),
+			"4944330300000000007f434841500000002500006368703100000000000000ec" +
+				"54ffffffffffffffff5449543200000006000000496e74726f43484150000000" +
+				"2c000063687032000000ec540001e848ffffffffffffffff544954320000000d" +
+				"000001fffea9036d0065006700610043544f43000000100000746f6300030263" +
+				"6870310063687032004d503344415441"},
+		{"ID3v2.4", "4944330400000000002e5449543200000006000000416c62756d434841500000" +
+			"001400006f6c6400000000000000000000000000000000004d503344415441",
+			"494433040000000001095449543200000006000000416c62756d434841500000" +
+				"002500006368703100000000000000ec54ffffffffffffffff54495432000000" +
+				"06000000496e74726f4348415000000026000063687032000000ec540001e848" +
+				"ffffffffffffffff5449543200000007000003cea96d65676143544f43000000" +
+				"100000746f63000302636870310063687032004d503344415441"},
+	} {
+		path := filepath.Join(t.TempDir(), "book.mp3")
+		in, _ := hex.DecodeString(c.in)
+		if err := os.WriteFile(path, in, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeID3Chapters(path, chap); err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		out, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(out); got != c.want {
+			t.Errorf("%v: tag =\n%v, want\n%v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// runJobs calls job for indexes 0...n-1 running at most jobs calls at once.
+// Errors are joined in index order, so the report does not depend on
+// timing.
+func runJobs(jobs, n int, job func(i int) error) error {
+	var (
+		wg  sync.WaitGroup
+		err = make([]error, n)
+		sem = make(chan struct{}, max(jobs, 1))
+	)
+
+	for i := range n {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err[i] = job(i)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(err...)
+}
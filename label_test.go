@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// benchCue returns a cue sheet of one file with n tracks a second apart.
+func benchCue(n int) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "PERFORMER \"Artist\"\nTITLE \"Album\"\nFILE \"album.wav\" WAVE\n")
+	for i := range n {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n    TITLE \"Track %d\"\n    INDEX 01 %v\n",
+			i%99+1, i+1, (Timestamp(i) * uSecInSecond).CueTime())
+	}
+	return b.Bytes()
+}
+
+func TestLabelTitleRoundTrip(t *testing.T) {
+	for _, title := range []string{`C:\new\track`, "a\tb", "tab\there", "two\nlines\r\n",
+		`\`, `end\`, "plain"} {
+		line := fmt.Sprintf("1.000000\t1.000000\t%v\n", labelEscaper.Replace(title))
+		label, err := readLabels(strings.NewReader(line))
+		if err != nil || len(label) != 1 || label[0].title != title {
+			t.Errorf("title %q read back as %+v, %v", title, label, err)
+		}
+	}
+}
+
+func benchmarkLabels(b *testing.B, opt labelOptions) {
+	cue := benchCue(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(cue)))
+	for range b.N {
+		lw := opt.newLabelWriter(func(*cueSheet) io.WriteCloser {
+			return nopWriteCloser{io.Discard}
+		})
+		scanCue(bytes.NewReader(cue), 0, lw.put)
+		lw.finish(-1)
+	}
+}
+
+func BenchmarkLabels(b *testing.B) {
+	benchmarkLabels(b, labelOptions{numStart: defaultNumStart})
+}
+
+func BenchmarkLabelsTitleFormat(b *testing.B) {
+	opt := labelOptions{numStart: defaultNumStart}
+	opt.tmpl = template.Must(template.New("").Parse("{{.Num}} {{.Title}} {{.Duration}}"))
+	benchmarkLabels(b, opt)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Audacity label lines have no escapes, so tab or line break in title would
+// start new column or label. They are written as \t, \n and \r, and
+// backslash as \\.
+var (
+	labelEscaper   = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	labelUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+)
+
+// labelFreqLine is Audacity spectral selection line following its label,
+// with zero low and high frequency.
+const labelFreqLine = "\\\t0\t0\n"
+
+// readLabels reads Audacity label file. Spectral selection lines are
+// skipped, and the end label made by label -end-label is dropped.
+func readLabels(r io.Reader) (label []cueLabel, err error) {
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		s := strings.TrimRight(scan.Text(), "\r")
+		if strings.TrimSpace(s) == "" || strings.HasPrefix(s, `\`) {
+			continue
+		}
+		f := strings.SplitN(s, "\t", 3)
+		if len(f) < 2 {
+			return nil, fmt.Errorf("line %d: expected start and end time", n)
+		}
+		t, err := parseTimeSec(strings.TrimSpace(f[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		l := cueLabel{num: len(label) + 1, start: t, index00: -1}
+		if len(label) > 0 && l.start < label[len(label)-1].start {
+			return nil, fmt.Errorf("line %d: label starts before previous one", n)
+		}
+		if len(f) == 3 {
+			l.title = labelUnescaper.Replace(f[2])
+		}
+		label = append(label, l)
+	}
+	if err = scan.Err(); err != nil {
+		return nil, err
+	}
+	if n := len(label); n > 1 && label[n-1].title == endLabelTitle {
+		label = label[:n-1]
+	}
+	return label, nil
+}
+
+// doCmdLabelToCue makes cue from Audacity label file.
+func doCmdLabelToCue(arg []string) {
+	var (
+		labelFilePath string
+		cueFilePath   string
+		fileName      string
+		denum         bool
+		sheet         cueSheet
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&labelFilePath, "i", "", "input label file path")
+	fl.StringVar(&cueFilePath, "o", "", "output cue file path")
+	fl.StringVar(&fileName, "file", "", "cue audio file name")
+	fl.StringVar(&sheet.title, "title", "", "album title")
+	fl.StringVar(&sheet.performer, "performer", "", "album performer")
+	fl.BoolVar(&denum, "denum", false, "remove track numbers from label titles")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if fileName == "" {
+		if isStdio(labelFilePath) {
+			panic("Option -file is required for label file from standard input")
+		}
+		fileName = fileTitle(labelFilePath) + ".wav"
+	}
+
+	in := openInput(labelFilePath)
+	sheet.label, err = readLabels(in)
+	in.Close()
+	if err != nil {
+		panic("Wrong label file " + inputName(in) + ": " + err.Error())
+	}
+	if len(sheet.label) == 0 {
+		panic("No labels in " + inputName(in))
+	}
+	if len(sheet.label) > maxCueTracks {
+		panic(fmt.Sprintf("Too many labels: %d", len(sheet.label)))
+	}
+	for i, l := range sheet.label {
+		if denum {
+			sheet.label[i].title = denumRe.ReplaceAllString(l.title, "$1")
+		}
+	}
+
+	out := createOutput(cueFilePath)
+	writeCueSheet(out, sheet, fileName, "WAVE")
+	panicIfError(out.Close())
+}
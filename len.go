@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type trackLength struct {
+	name     string
+	start    Timestamp
+	duration Timestamp // -1 if unknown
+}
+
+func doCmdTrackLength(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		points        bool
+		filter        trackFilter
+		track         []trackLength
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "cue audio file to get the last track length")
+	addProbeFlags(fl)
+	fl.BoolVar(&points, "points", false, "print shntool split points")
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+
+	if cueFilePath != "" {
+		if fl.NArg() != 0 {
+			panic("No tracks expected with cue file")
+		}
+		f := openInput(cueFilePath)
+		label := parseCue(f, cueAudioFile).label
+		f.Close()
+		end := Timestamp(-1)
+		if audioFilePath != "" {
+			end, err = getMediaDuration(audioFilePath)
+			panicIfError(err)
+		}
+		track = filter.applyLengths(label, cueTrackLengths(label, end))
+	} else {
+		if fl.NArg() == 0 {
+			panic("No input cue file or track(s)")
+		}
+		start, end := trackStartTimes(0, 0, fl.Args(), true)
+		for i, path := range fl.Args() {
+			t := trackLength{name: path, start: start[i], duration: end - start[i]}
+			if i < len(start)-1 {
+				t.duration = start[i+1] - start[i]
+			}
+			track = append(track, t)
+		}
+	}
+
+	if points {
+		writeSplitPoints(os.Stdout, track)
+	} else {
+		writeTrackLengths(os.Stdout, track)
+	}
+}
+
+func cueTrackLengths(label []cueLabel, end Timestamp) (track []trackLength) {
+	for i, l := range label {
+		t := trackLength{name: l.title, start: l.start, duration: -1}
+		if i < len(label)-1 {
+			t.duration = label[i+1].start - l.start
+		} else if end >= 0 {
+			t.duration = end - l.start
+		}
+		track = append(track, t)
+	}
+	return
+}
+
+// writeTrackLengths writes track lengths in shntool len style.
+func writeTrackLengths(w io.Writer, track []trackLength) {
+	var err error
+
+	if outputFormat == outputJSON {
+		js := make([]jsonTrackLength, 0, len(track))
+		for _, t := range track {
+			j := jsonTrackLength{Track: t.name, Start: jsonTime(t.start)}
+			if t.duration >= 0 {
+				d, sectors := jsonTime(t.duration), t.duration.Frames(RoundDown)
+				bytes := sectors * cdBytesPerSector
+				j.Duration, j.Sectors, j.Bytes = &d, &sectors, &bytes
+			}
+			js = append(js, j)
+		}
+		writeJSON(w, js)
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "%12v%12v%14v  %v\n", "length", "sectors", "bytes", "track")
+	panicIfError(err)
+	for _, t := range track {
+		if t.duration < 0 {
+			_, err = fmt.Fprintf(w, "%12v%12v%14v  %v\n", "?", "?", "?", t.name)
+		} else {
+			sectors := t.duration.Frames(RoundDown)
+			_, err = fmt.Fprintf(w, "%12v%12d%14d  %v\n",
+				formatShnTime(t.duration), sectors, sectors*cdBytesPerSector, t.name)
+		}
+		panicIfError(err)
+	}
+}
+
+// writeSplitPoints writes track start times suitable for 'shntool split -f'.
+func writeSplitPoints(w io.Writer, track []trackLength) {
+	if outputFormat == outputJSON {
+		var js []string
+		for _, t := range track {
+			if t.start > 0 {
+				js = append(js, formatShnTime(t.start))
+			}
+		}
+		writeJSON(w, js)
+		return
+	}
+	for _, t := range track {
+		if t.start <= 0 {
+			continue
+		}
+		_, err := fmt.Fprintln(w, formatShnTime(t.start))
+		panicIfError(err)
+	}
+}
+
+// formatShnTime formats time as shntool m:ss.ff with CD frames.
+func formatShnTime(t Timestamp) string {
+	m := newMSF(t.Frames(RoundDown))
+	return fmt.Sprintf("%d:%02d.%02d", m.min, m.sec, m.frame)
+}
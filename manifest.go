@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// mediaInfo is one file in probe manifest.
+type mediaInfo struct {
+	Path       string            `json:"path"`
+	Duration   jsonTime          `json:"duration"`
+	SampleRate int               `json:"sample_rate,omitempty"`
+	Channels   int               `json:"channels,omitempty"`
+	SampleFmt  string            `json:"sample_fmt,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+type mediaManifest struct {
+	Files []mediaInfo `json:"files"`
+}
+
+// manifestDur holds durations from -manifest file by cleaned path.
+var manifestDur map[string]Timestamp
+
+// probedDur holds durations probed in this run by cleaned path, so files
+// probed twice, like with -skip-errors, are probed once.
+var probedDur struct {
+	sync.Mutex
+	dur map[string]Timestamp
+}
+
+func doCmdProbe(arg []string) {
+	var (
+		manifestPath string
+		manifest     mediaManifest
+		outWr        io.WriteCloser
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&manifestPath, "o", "", "output manifest file path")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() == 0 {
+		panic("No input track(s)")
+	}
+
+	for _, path := range fl.Args() {
+		info, err := getMediaInfo(path)
+		panicIfError(err)
+		manifest.Files = append(manifest.Files, info)
+	}
+
+	outWr = createOutput(manifestPath)
+	defer outWr.Close()
+	writeJSON(outWr, manifest)
+}
+
+// getMediaInfo probes duration, audio stream parameters and tags.
+func getMediaInfo(filePath string) (info mediaInfo, err error) {
+	var (
+		dur Timestamp
+		out []byte
+		js  struct {
+			Streams []struct {
+				SampleRate string `json:"sample_rate"`
+				Channels   int    `json:"channels"`
+				SampleFmt  string `json:"sample_fmt"`
+			} `json:"streams"`
+			Format struct {
+				Tags map[string]string `json:"tags"`
+			} `json:"format"`
+		}
+	)
+
+	if dur, err = getMediaDuration(filePath); err != nil {
+		return
+	}
+	info = mediaInfo{Path: filePath, Duration: jsonTime(dur)}
+	if useNativeProbe() {
+		info.SampleRate, info.Channels, info.SampleFmt, err = getNativeStreamInfo(filePath)
+		if err != nil && !errors.Is(err, errNativeFormat) {
+			return
+		}
+		if info.Tags, err = getNativeTags(filePath); errors.Is(err, errNativeFormat) {
+			err = nil
+		}
+		return
+	}
+
+	out, err = runCommand("ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=sample_rate,channels,sample_fmt:format_tags",
+		"-i", filePath)
+	if err != nil {
+		err = fmt.Errorf("get media info: ffprobe: %w", err)
+		return
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		err = fmt.Errorf("get media info: %w", err)
+		return
+	}
+	if len(js.Streams) > 0 {
+		info.SampleRate, _ = strconv.Atoi(js.Streams[0].SampleRate)
+		info.Channels = js.Streams[0].Channels
+		info.SampleFmt = js.Streams[0].SampleFmt
+	}
+	info.Tags = js.Format.Tags
+	return
+}
+
+func loadManifest(path string) {
+	var manifest mediaManifest
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic("Cannot read manifest: " + err.Error())
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		panic("Wrong manifest: " + err.Error())
+	}
+	manifestDur = make(map[string]Timestamp)
+	for _, f := range manifest.Files {
+		manifestDur[filepath.Clean(f.Path)] = Timestamp(f.Duration)
+	}
+}
+
+func getManifestDuration(filePath string) (dur Timestamp, ok bool) {
+	if probeOpt.manifest != "" && manifestDur == nil {
+		loadManifest(probeOpt.manifest)
+	}
+	dur, ok = manifestDur[filepath.Clean(filePath)]
+	return
+}
+
+func getProbedDuration(filePath string) (dur Timestamp, ok bool) {
+	probedDur.Lock()
+	defer probedDur.Unlock()
+	dur, ok = probedDur.dur[filepath.Clean(filePath)]
+	return
+}
+
+func addProbedDuration(filePath string, dur Timestamp) {
+	probedDur.Lock()
+	defer probedDur.Unlock()
+	if probedDur.dur == nil {
+		probedDur.dur = make(map[string]Timestamp)
+	}
+	probedDur.dur[filepath.Clean(filePath)] = dur
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	mergeByNumber = "number"
+	mergeByTitle  = "title"
+
+	minTitleSimilarity = 0.6
+)
+
+// doCmdMergeTitles makes cue with track times from one cue and titles and
+// performers from another cue of the same audio.
+func doCmdMergeTitles(arg []string) {
+	var (
+		timesFilePath  string
+		timesAudioFile int
+		titleFilePath  string
+		titleAudioFile int
+		outFilePath    string
+		by             string
+		outWr          io.WriteCloser
+		match          []int
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&timesFilePath, "times", "", "cue file to take track times from")
+	fl.IntVar(&timesAudioFile, "times-a", 0, "times cue audio file index starting at 0")
+	fl.StringVar(&titleFilePath, "titles", "", "cue file to take titles and performers from")
+	fl.IntVar(&titleAudioFile, "titles-a", 0, "titles cue audio file index starting at 0")
+	fl.StringVar(&outFilePath, "o", "", "output cue file path")
+	fl.StringVar(&by, "by", mergeByNumber, "match tracks by number or title")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if timesFilePath == "" || titleFilePath == "" {
+		panic("Both -times and -titles cue files are required")
+	}
+	if isStdio(timesFilePath) && isStdio(titleFilePath) {
+		panic("Only one of -times and -titles can be stdin")
+	}
+
+	times, fileName := readCueFile(timesFilePath, timesAudioFile)
+	titles, _ := readCueFile(titleFilePath, titleAudioFile)
+	switch by {
+	case mergeByNumber:
+		match = matchTracksByNumber(times.label, titles.label)
+	case mergeByTitle:
+		match = matchTracksByTitle(times.label, titles.label)
+	default:
+		panic("Wrong track matching: " + by)
+	}
+
+	sheet := mergeCueSheets(times, titles, match)
+	outWr = createOutput(outFilePath)
+	defer outWr.Close()
+	writeCueSheet(outWr, sheet, fileName, "WAVE")
+}
+
+// readCueFile parses cue audio file tracks and returns the audio file name.
+func readCueFile(path string, cueAudioFile int) (sheet cueSheet, fileName string) {
+	f := openInput(path)
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		panic("Read cue " + inputName(f) + ": " + err.Error())
+	}
+	sheet = parseCue(newNamedReader(data, path), cueAudioFile)
+	if name := parseCueFiles(newNamedReader(data, path)); cueAudioFile < len(name) {
+		fileName = name[cueAudioFile]
+	}
+	return
+}
+
+// mergeCueSheets takes times from times and the rest from matched titles
+// tracks, match[i] is titles track index for times track i or -1.
+func mergeCueSheets(times, titles cueSheet, match []int) (sheet cueSheet) {
+	sheet = cueSheet{
+		catalog:   cmp.Or(titles.catalog, times.catalog),
+		title:     cmp.Or(titles.title, times.title),
+		performer: cmp.Or(titles.performer, times.performer),
+		cover:     cmp.Or(titles.cover, times.cover),
+	}
+	for i, l := range times.label {
+		if match[i] < 0 {
+			logWarningMessage(fmt.Sprintf("track %d %q has no match", l.num, l.title))
+		} else {
+			t := titles.label[match[i]]
+			l.title = t.title
+			l.performer = cmp.Or(t.performer, l.performer)
+			l.isrc = cmp.Or(t.isrc, l.isrc)
+			if l.flags == nil {
+				l.flags = t.flags
+			}
+		}
+		sheet.label = append(sheet.label, l)
+	}
+	return
+}
+
+func matchTracksByNumber(times, titles []cueLabel) (match []int) {
+	for _, l := range times {
+		m := -1
+		for j, t := range titles {
+			if t.num == l.num {
+				m = j
+				break
+			}
+		}
+		match = append(match, m)
+	}
+	return
+}
+
+// matchTracksByTitle matches every times track to the most similar not yet
+// matched titles track.
+func matchTracksByTitle(times, titles []cueLabel) (match []int) {
+	used := make([]bool, len(titles))
+	for _, l := range times {
+		m, best := -1, minTitleSimilarity
+		for j, t := range titles {
+			if s := titleSimilarity(l.title, t.title); !used[j] && s >= best {
+				m, best = j, s
+			}
+		}
+		if m >= 0 {
+			used[m] = true
+		}
+		match = append(match, m)
+	}
+	return
+}
+
+// titleSimilarity returns 1 for titles equal ignoring case, spaces and
+// punctuation, down to 0 for completely different ones.
+func titleSimilarity(a, b string) float64 {
+	key := func(s string) []rune {
+		return []rune(strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, s))
+	}
+	ka, kb := key(a), key(b)
+	n := max(len(ka), len(kb))
+	if n == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(ka, kb))/float64(n)
+}
+
+// editDistance returns Levenshtein distance of a and b.
+func editDistance(a, b []rune) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := range a {
+		prev := row[0]
+		row[0] = i + 1
+		for j := range b {
+			cost := 1
+			if a[i] == b[j] {
+				cost = 0
+			}
+			prev, row[j+1] = row[j+1], min(row[j+1]+1, row[j]+1, prev+cost)
+		}
+	}
+	return row[len(b)]
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultMetaPattern = "{artist}/{year} - {album}"
+
+var metaFieldRe = regexp.MustCompile(`\{(artist|album|year)\}`)
+
+// metaPatternRe converts -meta-pattern like "{artist}/{year} - {album}" to
+// regexp matching the end of a slash separated directory path.
+func metaPatternRe(pattern string) (*regexp.Regexp, error) {
+	var (
+		b    strings.Builder
+		last int
+		seen = make(map[string]bool)
+	)
+
+	for _, m := range metaFieldRe.FindAllStringSubmatchIndex(pattern, -1) {
+		name := pattern[m[2]:m[3]]
+		if seen[name] {
+			return nil, fmt.Errorf("field {%v} is repeated", name)
+		}
+		seen[name] = true
+		b.WriteString(regexp.QuoteMeta(pattern[last:m[0]]))
+		if name == "year" {
+			b.WriteString(`(?P<year>[0-9]{4})`)
+		} else {
+			fmt.Fprintf(&b, `(?P<%v>[^/]+?)`, name)
+		}
+		last = m[1]
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no {artist}, {album} or {year} field in '%v'", pattern)
+	}
+	b.WriteString(regexp.QuoteMeta(pattern[last:]))
+	return regexp.Compile("(?:^|/)" + b.String() + "$")
+}
+
+// inferMetadata fills disc title, performer and date not given by options
+// from the directory of the first track matching -meta-pattern.
+func (opt *cueOptions) inferMetadata(trackFilePath []string) {
+	if opt.metaRe == nil || len(trackFilePath) == 0 {
+		return
+	}
+	dir, err := filepath.Abs(filepath.Dir(trackFilePath[0]))
+	if err != nil {
+		panic("Cannot infer metadata: " + err.Error())
+	}
+	m := opt.metaRe.FindStringSubmatch(filepath.ToSlash(dir))
+	if m == nil {
+		logWarningMessage(fmt.Sprintf("directory '%v' does not match '%v'", dir, opt.metaPattern))
+		return
+	}
+	for i, name := range opt.metaRe.SubexpNames() {
+		switch name {
+		case "artist":
+			opt.performer = cmp.Or(opt.performer, m[i])
+		case "album":
+			opt.discTitle = cmp.Or(opt.discTitle, m[i])
+		case "year":
+			opt.date = cmp.Or(opt.date, m[i])
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	mixxxHotCue = 1 // cues.type of hot cue
+	// mixxxMaxHotCues is the number of hot cues of Mixxx track.
+	mixxxMaxHotCues = 36
+	// mixxxCueColor is RGB color of the cues.
+	mixxxCueColor = 0xc50a08
+)
+
+// mixxxTrackID selects Mixxx library id of track by absolute path.
+func mixxxTrackID(path string) string {
+	return fmt.Sprintf("(SELECT library.id FROM library JOIN track_locations "+
+		"ON library.location = track_locations.id WHERE track_locations.location = %v)",
+		sqlQuote(path))
+}
+
+// mixxxCueSQL returns SQL script setting hot cues of Mixxx track at label
+// starts. Cue positions are in samples of all channels, as Mixxx counts
+// them for stereo tracks.
+func mixxxCueSQL(path string, label []cueLabel, rate int, replace bool) string {
+	var b strings.Builder
+
+	b.WriteString("BEGIN;\n")
+	if replace {
+		fmt.Fprintf(&b, "DELETE FROM cues WHERE type = %d AND track_id = %v;\n",
+			mixxxHotCue, mixxxTrackID(path))
+	}
+	for i, l := range label {
+		frame := l.start.Samples(int64(rate), RoundNearest)
+		title := l.title
+		if l.performer != "" {
+			title = l.performer + " - " + title
+		}
+		fmt.Fprintf(&b, "INSERT INTO cues (track_id, type, position, length, hotcue, label, color) "+
+			"SELECT id, %d, %d, 0, %d, %v, %d FROM library WHERE id = %v;\n",
+			mixxxHotCue, frame*2, i, sqlQuote(title), mixxxCueColor, mixxxTrackID(path))
+	}
+	b.WriteString("COMMIT;\n")
+	return b.String()
+}
+
+// doCmdMixxx sets cue tracks as hot cues of the audio in Mixxx library, or
+// writes SQL script doing it.
+func doCmdMixxx(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		dbPath        string
+		outFilePath   string
+		rate          int
+		replace       bool
+		filter        trackFilter
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "audio file in Mixxx library, default is cue FILE")
+	fl.StringVar(&dbPath, "db", "", "Mixxx mixxxdb.sqlite to add hot cues to")
+	fl.StringVar(&outFilePath, "o", "", "output SQL script path, if no -db")
+	fl.IntVar(&rate, "rate", 0, "audio sample rate, probed by default")
+	fl.BoolVar(&replace, "replace", false, "remove existing hot cues of the track")
+	filter.addFlags(fl)
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if dbPath != "" && outFilePath != "" {
+		panic("Options -db and -o are exclusive")
+	}
+	if rate < 0 {
+		panic("Wrong sample rate: " + strconv.Itoa(rate))
+	}
+
+	cueRd := openInput(cueFilePath)
+	sheet := parseCue(cueRd, cueAudioFile)
+	cueRd.Close()
+	if audioFilePath == "" {
+		if sheet.file == "" {
+			panic("No cue FILE, use -audio")
+		}
+		audioFilePath = cueMediaPath(cueFilePath, sheet.file)
+	}
+	if audioFilePath, err = filepath.Abs(audioFilePath); err != nil {
+		panic("Wrong audio file path: " + err.Error())
+	}
+	if rate == 0 {
+		info, err := getMediaInfo(audioFilePath)
+		panicIfError(err)
+		if rate = info.SampleRate; rate == 0 {
+			panic("Unknown sample rate of " + audioFilePath + ", use -rate")
+		}
+	}
+	label := filter.apply(sheet.label)
+	if len(label) > mixxxMaxHotCues {
+		logWarningMessage(fmt.Sprintf("Mixxx track has %d hot cues, %d tracks left out",
+			mixxxMaxHotCues, len(label)-mixxxMaxHotCues))
+		label = label[:mixxxMaxHotCues]
+	}
+	script := mixxxCueSQL(audioFilePath, label, rate, replace)
+
+	if dbPath == "" {
+		outWr := createOutput(outFilePath)
+		_, err = io.WriteString(outWr, script)
+		panicIfError(err)
+		panicIfError(outWr.Close())
+		return
+	}
+	id, err := runSQL(dbPath, "SELECT "+mixxxTrackID(audioFilePath)+";")
+	if err != nil {
+		panic("Cannot read Mixxx library: " + err.Error())
+	}
+	if strings.TrimSpace(string(id)) == "" {
+		panic("Track is not in Mixxx library: " + audioFilePath)
+	}
+	if _, err = runSQL(dbPath, script); err != nil {
+		panic("Cannot add hot cues: " + err.Error())
+	}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var errNativeFormat = errors.New("unsupported format")
+
+// nativeFormats are extensions of files getNativeDuration reads.
+var nativeFormats = []string{".wav", ".flac", ".ogg", ".oga", ".opus", ".mp3"}
+
+// useNativeProbe reports whether durations are read without ffprobe: either
+// requested with -native or ffprobe is not installed. Deterministic mode
+// never falls back silently, as the two may disagree by a few samples.
+func useNativeProbe() bool {
+	if probeOpt.native || deterministic {
+		return probeOpt.native
+	}
+	_, err := exec.LookPath("ffprobe")
+	return err != nil
+}
+
+// getNativeDuration reads duration of WAV, FLAC, Ogg Vorbis/Opus and MP3
+// with Xing/Info header directly from the file.
+func getNativeDuration(filePath string) (dur Timestamp, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		dur, err = getWavDuration(filePath)
+	case ".flac":
+		dur, err = getFlacDuration(filePath)
+	case ".ogg", ".oga", ".opus":
+		dur, err = getOggDuration(filePath)
+	case ".mp3":
+		var info mp3Info
+		if info, err = readMP3Info(filePath); err == nil {
+			if info.frames == 0 {
+				err = errors.New("no Xing/Info header")
+			} else {
+				dur, err = info.duration(filePath)
+			}
+		}
+	default:
+		err = errNativeFormat
+	}
+	if err != nil {
+		err = fmt.Errorf("get native duration: '%v': %w", filePath, err)
+	} else if dur <= 0 {
+		err = fmt.Errorf("get native duration: '%v': wrong value: %v", filePath, dur)
+	}
+	return
+}
+
+// wavFile is format and data chunk position of RIFF WAVE file.
+type wavFile struct {
+	format     []byte // fmt chunk
+	rate       int64
+	channels   int
+	bits       int
+	blockAlign int64
+	dataOff    int64
+	dataSize   int64
+	tags       map[string]string // LIST INFO by ffprobe tag name
+}
+
+// wavInfoTags are ffprobe names of RIFF LIST INFO tags.
+var wavInfoTags = map[string]string{
+	"IART": "artist", "ICMT": "comment", "ICOP": "copyright", "ICRD": "date",
+	"IGNR": "genre", "ILNG": "language", "INAM": "title", "IPRD": "album",
+	"IPRT": "track", "ITRK": "track", "ISFT": "encoder", "ITCH": "encoded_by",
+}
+
+// readWavFile reads chunks of RIFF WAVE file.
+func readWavFile(f *os.File) (w wavFile, err error) {
+	var (
+		hdr   [12]byte
+		chunk [8]byte
+	)
+
+	if _, err = io.ReadFull(f, hdr[:]); err != nil {
+		return
+	}
+	if string(hdr[:4]) != "RIFF" || string(hdr[8:]) != "WAVE" {
+		return w, errors.New("not a RIFF WAVE file")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	pos := int64(len(hdr))
+	for {
+		if _, err = f.ReadAt(chunk[:], pos); err != nil {
+			if w.dataOff > 0 && err == io.EOF {
+				return w, nil
+			}
+			return w, fmt.Errorf("no data chunk: %w", err)
+		}
+		pos += int64(len(chunk))
+		// a chunk holds no more than the file, as of truncated recording
+		size := min(int64(binary.LittleEndian.Uint32(chunk[4:])), fi.Size()-pos)
+		switch string(chunk[:4]) {
+		case "fmt ":
+			if size < 16 {
+				return w, errors.New("short fmt chunk")
+			}
+			w.format = make([]byte, size)
+			if _, err = f.ReadAt(w.format, pos); err != nil {
+				return
+			}
+			w.channels = int(binary.LittleEndian.Uint16(w.format[2:]))
+			w.rate = int64(binary.LittleEndian.Uint32(w.format[4:]))
+			w.blockAlign = int64(binary.LittleEndian.Uint16(w.format[12:]))
+			w.bits = int(binary.LittleEndian.Uint16(w.format[14:]))
+		case "LIST":
+			if w.tags, err = readWavInfo(io.NewSectionReader(f, pos, size)); err != nil {
+				return
+			}
+		case "data":
+			if w.rate == 0 || w.blockAlign == 0 {
+				return w, errors.New("no fmt chunk before data")
+			}
+			w.dataOff, w.dataSize = pos, size
+		}
+		// chunks are word aligned
+		pos += size + size&1
+	}
+}
+
+// readWavInfo reads tags of LIST INFO chunk, or none of other LIST chunks.
+func readWavInfo(r *io.SectionReader) (tag map[string]string, err error) {
+	var (
+		typ [4]byte
+		sub [8]byte
+	)
+
+	if _, err = io.ReadFull(r, typ[:]); err != nil || string(typ[:]) != "INFO" {
+		return nil, nil
+	}
+	left := r.Size() - int64(len(typ))
+	tag = make(map[string]string)
+	for {
+		if _, err = io.ReadFull(r, sub[:]); err != nil {
+			return tag, nil
+		}
+		left -= int64(len(sub))
+		size := int64(binary.LittleEndian.Uint32(sub[4:]))
+		if size > left {
+			return nil, errors.New("LIST INFO: chunk longer than LIST")
+		}
+		// the pad byte of the last chunk may be left out
+		v := make([]byte, min(size+size&1, left))
+		if _, err = io.ReadFull(r, v); err != nil {
+			return nil, fmt.Errorf("LIST INFO: %w", err)
+		}
+		left -= int64(len(v))
+		v = v[:size]
+		if name, ok := wavInfoTags[string(sub[:4])]; ok {
+			tag[name] = strings.TrimRight(string(v), "\x00")
+		}
+	}
+}
+
+func getWavDuration(filePath string) (dur Timestamp, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	w, err := readWavFile(f)
+	if err != nil {
+		return
+	}
+	return SamplesTime(w.dataSize/w.blockAlign, w.rate), nil
+}
+
+// flacStreamInfo is FLAC STREAMINFO block.
+type flacStreamInfo struct {
+	rate     int64
+	channels int
+	bits     int
+	samples  int64
+}
+
+func readFlacStreamInfo(filePath string) (si flacStreamInfo, err error) {
+	var buf [4 + 4 + 34]byte
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = io.ReadFull(f, buf[:]); err != nil {
+		return
+	}
+	if string(buf[:4]) != "fLaC" || buf[4]&0x7f != 0 {
+		return si, errors.New("no FLAC STREAMINFO")
+	}
+	b := buf[8:]
+	si.rate = int64(b[10])<<12 | int64(b[11])<<4 | int64(b[12])>>4
+	si.channels = int(b[12]>>1&7) + 1
+	si.bits = int(b[12]&1)<<4 | int(b[13]>>4) + 1
+	si.samples = int64(b[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(b[14:]))
+	return
+}
+
+func getFlacDuration(filePath string) (dur Timestamp, err error) {
+	si, err := readFlacStreamInfo(filePath)
+	if err != nil {
+		return
+	}
+	if si.rate == 0 || si.samples == 0 {
+		return 0, errors.New("unknown FLAC sample count")
+	}
+	return SamplesTime(si.samples, si.rate), nil
+}
+
+// formatTag returns format tag of WAV fmt chunk, or of its sub format if
+// the chunk is WAVE_FORMAT_EXTENSIBLE.
+func (w *wavFile) formatTag() uint16 {
+	tag := binary.LittleEndian.Uint16(w.format)
+	if tag == 0xfffe && len(w.format) >= 26 {
+		tag = binary.LittleEndian.Uint16(w.format[24:])
+	}
+	return tag
+}
+
+// getNativeStreamInfo reads sample rate, channels and ffprobe sample format
+// of WAV and FLAC directly from the file.
+func getNativeStreamInfo(filePath string) (rate, channels int, sampleFmt string, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		var (
+			f *os.File
+			w wavFile
+		)
+		if f, err = os.Open(filePath); err != nil {
+			break
+		}
+		w, err = readWavFile(f)
+		f.Close()
+		if err != nil {
+			break
+		}
+		rate, channels = int(w.rate), w.channels
+		switch tag := w.formatTag(); {
+		case tag == 3 && w.bits == 32:
+			sampleFmt = "flt"
+		case tag == 3 && w.bits == 64:
+			sampleFmt = "dbl"
+		case tag == 1 && w.bits <= 8:
+			sampleFmt = "u8"
+		case tag == 1 && w.bits <= 16:
+			sampleFmt = "s16"
+		case tag == 1 && w.bits <= 32:
+			sampleFmt = "s32"
+		default:
+			err = errNativeFormat
+		}
+	case ".flac":
+		var si flacStreamInfo
+		if si, err = readFlacStreamInfo(filePath); err != nil {
+			break
+		}
+		rate, channels, sampleFmt = int(si.rate), si.channels, "s16"
+		if si.bits > 16 {
+			sampleFmt = "s32"
+		}
+	default:
+		err = errNativeFormat
+	}
+	if err != nil {
+		err = fmt.Errorf("get native stream info: '%v': %w", filePath, err)
+	}
+	return
+}
+
+// getOggDuration gets duration from the granule position of the last page.
+func getOggDuration(filePath string) (dur Timestamp, err error) {
+	var (
+		buf     []byte
+		rate    int64
+		preSkip int64
+		granule int64
+	)
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	pkt, err := readOggPackets(bufio.NewReader(f), 1)
+	if err != nil {
+		return
+	}
+	switch packet := pkt[0]; {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		rate = int64(binary.LittleEndian.Uint32(packet[12:]))
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 12:
+		rate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+	default:
+		return 0, errNativeFormat
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	off := max(0, fi.Size()-64*1024)
+	buf = make([]byte, fi.Size()-off)
+	if _, err = f.ReadAt(buf, off); err != nil && err != io.EOF {
+		return
+	}
+	i := bytes.LastIndex(buf, []byte("OggS"))
+	if i < 0 || i+14 > len(buf) {
+		return 0, errors.New("no last Ogg page")
+	}
+	granule = int64(binary.LittleEndian.Uint64(buf[i+6:]))
+	if rate == 0 || granule <= preSkip {
+		return 0, errors.New("wrong Ogg granule position")
+	}
+	return SamplesTime(granule-preSkip, rate), nil
+}
+
+// oggHeaderLimit is the longest Ogg header packets read, leaving room for
+// cover art in comments.
+const oggHeaderLimit = 16 << 20
+
+// readOggPackets reads the first n packets of Ogg stream.
+func readOggPackets(r io.Reader, n int) (pkt [][]byte, err error) {
+	var (
+		hdr   [27]byte
+		cur   []byte
+		total int
+	)
+
+	for len(pkt) < n {
+		if _, err = io.ReadFull(r, hdr[:]); err != nil {
+			return nil, fmt.Errorf("Ogg page: %w", err)
+		}
+		if string(hdr[:4]) != "OggS" {
+			return nil, errors.New("not an Ogg file")
+		}
+		lacing := make([]byte, hdr[26])
+		if _, err = io.ReadFull(r, lacing); err != nil {
+			return nil, fmt.Errorf("Ogg page: %w", err)
+		}
+		for _, l := range lacing {
+			if total += int(l); total > oggHeaderLimit {
+				return nil, errors.New("too long Ogg header packets")
+			}
+			seg := make([]byte, l)
+			if _, err = io.ReadFull(r, seg); err != nil {
+				return nil, fmt.Errorf("Ogg page: %w", err)
+			}
+			cur = append(cur, seg...)
+			if l < 255 {
+				if pkt = append(pkt, cur); len(pkt) == n {
+					return
+				}
+				cur = nil
+			}
+		}
+	}
+	return
+}
+
+// parseVorbisCommentBlock returns comments of Vorbis comment block with lower
+// case names, like ffprobe format tags.
+func parseVorbisCommentBlock(b []byte) (tag map[string]string, err error) {
+	field := func() ([]byte, error) {
+		if len(b) < 4 {
+			return nil, errors.New("short Vorbis comment")
+		}
+		n := binary.LittleEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, errors.New("short Vorbis comment")
+		}
+		f := b[4 : 4+n]
+		b = b[4+n:]
+		return f, nil
+	}
+	if _, err = field(); err != nil { // vendor
+		return
+	}
+	if len(b) < 4 {
+		return nil, errors.New("short Vorbis comment")
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	tag = make(map[string]string)
+	for range count {
+		c, err := field()
+		if err != nil {
+			return nil, err
+		}
+		if k, v, ok := strings.Cut(string(c), "="); ok {
+			tag[strings.ToLower(k)] = v
+		}
+	}
+	return
+}
+
+func getFlacTags(filePath string) (tag map[string]string, err error) {
+	var hdr [4]byte
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, err = io.ReadFull(f, hdr[:]); err != nil {
+		return
+	}
+	if string(hdr[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	pos := int64(len(hdr))
+	for last := false; !last; {
+		if _, err = io.ReadFull(f, hdr[:]); err != nil {
+			return
+		}
+		last = hdr[0]&0x80 != 0
+		size := int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3])
+		if pos += int64(len(hdr)); size > fi.Size()-pos {
+			return nil, errors.New("FLAC metadata block longer than file")
+		}
+		pos += size
+		if hdr[0]&0x7f != 4 { // VORBIS_COMMENT
+			if _, err = f.Seek(size, io.SeekCurrent); err != nil {
+				return
+			}
+			continue
+		}
+		b := make([]byte, size)
+		if _, err = io.ReadFull(f, b); err != nil {
+			return
+		}
+		return parseVorbisCommentBlock(b)
+	}
+	return map[string]string{}, nil
+}
+
+func getOggTags(filePath string) (tag map[string]string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	pkt, err := readOggPackets(bufio.NewReader(f), 2)
+	if err != nil {
+		return
+	}
+	switch c := pkt[1]; {
+	case bytes.HasPrefix(c, []byte("\x03vorbis")):
+		return parseVorbisCommentBlock(c[7:])
+	case bytes.HasPrefix(c, []byte("OpusTags")):
+		return parseVorbisCommentBlock(c[8:])
+	}
+	return nil, errNativeFormat
+}
+
+func getWavTags(filePath string) (tag map[string]string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	w, err := readWavFile(f)
+	if err == nil && w.tags == nil {
+		w.tags = map[string]string{}
+	}
+	return w.tags, err
+}
+
+// getNativeTags reads tags of WAV, FLAC and Ogg Vorbis/Opus directly from the
+// file, with lower case names like ffprobe format tags.
+func getNativeTags(filePath string) (tag map[string]string, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		tag, err = getWavTags(filePath)
+	case ".flac":
+		tag, err = getFlacTags(filePath)
+	case ".ogg", ".oga", ".opus":
+		tag, err = getOggTags(filePath)
+	default:
+		err = errNativeFormat
+	}
+	if err != nil {
+		err = fmt.Errorf("get native tags: '%v': %w", filePath, err)
+	}
+	return
+}
+
+// useNativeSplit reports whether split cuts WAV files without ffmpeg, like
+// useNativeProbe.
+func useNativeSplit() bool {
+	if probeOpt.native || deterministic {
+		return probeOpt.native
+	}
+	_, err := exec.LookPath("ffmpeg")
+	return err != nil
+}
+
+// splitWav writes dur of src WAV file from start to dst WAV file with LIST
+// INFO tags by their ffprobe names. Times are rounded to samples. dst is a
+// temporary output, discarded if the command fails.
+func splitWav(src, dst string, start, dur Timestamp, tag map[string]string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	w, err := readWavFile(in)
+	if err != nil {
+		return fmt.Errorf("'%v': %w", src, err)
+	}
+	total := w.dataSize / w.blockAlign
+	first := min(start.Samples(w.rate, RoundNearest), total)
+	frames := min(dur.Samples(w.rate, RoundNearest), total-first)
+	size := frames * w.blockAlign
+
+	var info bytes.Buffer
+	info.WriteString("INFO")
+	for _, id := range slices.Sorted(maps.Keys(wavInfoTags)) {
+		v, ok := tag[wavInfoTags[id]]
+		if !ok || id == "ITRK" {
+			continue
+		}
+		n := len(v) + 1
+		info.WriteString(id)
+		binary.Write(&info, binary.LittleEndian, uint32(n))
+		info.WriteString(v)
+		info.Write(make([]byte, 1+n&1))
+	}
+	format := w.format
+	if len(format)&1 != 0 {
+		format = append(format, 0)
+	}
+	riff := 4 + 8 + int64(len(format)) + 8 + int64(info.Len()) + 8 + size + size&1
+	if riff > math.MaxUint32 {
+		return fmt.Errorf("track longer than WAV holds")
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	bw := bufio.NewWriter(out)
+	chunk := func(id string, size int64) {
+		bw.WriteString(id)
+		binary.Write(bw, binary.LittleEndian, uint32(size))
+	}
+	chunk("RIFF", riff)
+	bw.WriteString("WAVE")
+	chunk("fmt ", int64(len(w.format)))
+	bw.Write(format)
+	chunk("LIST", int64(info.Len()))
+	bw.Write(info.Bytes())
+	chunk("data", size)
+	if _, err = io.Copy(bw, io.NewSectionReader(in, w.dataOff+first*w.blockAlign, size)); err != nil {
+		return
+	}
+	if size&1 != 0 {
+		bw.WriteByte(0)
+	}
+	return bw.Flush()
+}
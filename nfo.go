@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nfoLengthTolerance is the largest accepted difference between NFO and
+// probed track lengths, NFO lengths being rounded to seconds.
+const nfoLengthTolerance = 2 * uSecInSecond
+
+// nfoTrackRe matches NFO tracklist line: number, title and length like
+// "01. Title ...... 3:45" or "1) Artist - Title [1:02:03]".
+var nfoTrackRe = regexp.MustCompile(
+	`^\s*(\d{1,3})\s*[.):-]?\s+(.+?)[\s.·_-]*[\[(]?(\d{1,3}:\d{2}(?::\d{2})?)[\])]?\s*$`)
+
+// nfoTrack is track of NFO tracklist.
+type nfoTrack struct {
+	num    int
+	title  string
+	length Timestamp
+}
+
+// readNFO returns tracklist of scene or rip NFO, info.txt and similar files:
+// numbered lines ending with track length.
+func readNFO(path string) (track []nfoTrack, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		m := nfoTrackRe.FindStringSubmatch(strings.ToValidUTF8(scan.Text(), ""))
+		if m == nil {
+			continue
+		}
+		t := nfoTrack{title: strings.TrimSpace(m[2])}
+		t.num, _ = strconv.Atoi(m[1])
+		if t.length, err = parseNFOLength(m[3]); err != nil {
+			return nil, fmt.Errorf("read NFO: track %d: %w", t.num, err)
+		}
+		track = append(track, t)
+	}
+	if err = scan.Err(); err != nil {
+		return nil, fmt.Errorf("read NFO: %w", err)
+	}
+	if len(track) == 0 {
+		return nil, fmt.Errorf("read NFO: no tracklist in '%v'", path)
+	}
+	return
+}
+
+// parseNFOLength parses m:ss or h:mm:ss length.
+func parseNFOLength(s string) (Timestamp, error) {
+	var sec Timestamp
+
+	for i, f := range strings.Split(s, ":") {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || i > 0 && n >= 60 {
+			return 0, fmt.Errorf("wrong length '%v'", s)
+		}
+		sec = sec*60 + Timestamp(n)
+	}
+	return sec * uSecInSecond, nil
+}
+
+// applyNFO replaces track titles with -nfo tracklist titles and warns about
+// track counts and lengths not matching probed durations.
+func (opt *cueOptions) applyNFO(title []string, dur []Timestamp) {
+	if opt.nfo == "" {
+		return
+	}
+	track, err := readNFO(opt.nfo)
+	if err != nil {
+		panic("Cannot read NFO: " + err.Error())
+	}
+	if len(track) != len(title) {
+		logWarningMessage(fmt.Sprintf("NFO lists %d track(s), found %d", len(track), len(title)))
+	}
+	for i := range min(len(track), len(title)) {
+		t := track[i]
+		title[i] = t.title
+		if d := dur[i] - t.length; d > nfoLengthTolerance || d < -nfoLengthTolerance {
+			logWarningMessage(fmt.Sprintf("track %d '%v': NFO length %v, probed %v",
+				opt.numStart+i, t.title, formatMinSec(t.length), formatMinSec(dur[i])))
+		}
+	}
+}
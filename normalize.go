@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+// Unicode normalization forms for titles.
+const (
+	normNFC = "nfc"
+	normNFD = "nfd"
+)
+
+// unicodeComp maps canonical decompositions back to precomposed letters. On
+// conflict the lowest code point wins, which skips Greek oxia duplicates
+// excluded from composition.
+var unicodeComp = func() map[string]rune {
+	m := make(map[string]rune, len(unicodeDecomp))
+	for r, d := range unicodeDecomp {
+		if len([]rune(d)) < 2 {
+			continue
+		}
+		if c, ok := m[d]; !ok || r < c {
+			m[d] = r
+		}
+	}
+	return m
+}()
+
+// Hangul syllables are composed of leading consonant, vowel and optional
+// trailing consonant jamo arithmetically.
+const (
+	hangulSBase  = 0xac00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11a7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// normalize converts s to NFC or NFD form. Letters in unicodeDecomp and
+// Hangul syllables are handled, which covers decomposed file names written
+// by macOS.
+func normalize(s, form string) string {
+	switch form {
+	case normNFD:
+		return toNFD(s)
+	case normNFC:
+		return toNFC(s)
+	}
+	return s
+}
+
+// combiningClass returns canonical combining class of r, zero for starters.
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(unicodeCombining), func(i int) bool {
+		return unicodeCombining[i].hi >= r
+	})
+	if i < len(unicodeCombining) && unicodeCombining[i].lo <= r {
+		return unicodeCombining[i].class
+	}
+	return 0
+}
+
+func toNFD(s string) string {
+	var d []rune
+
+	for _, r := range s {
+		if dec, ok := unicodeDecomp[r]; ok {
+			d = append(d, []rune(dec)...)
+		} else if i := r - hangulSBase; i >= 0 && i < hangulSCount {
+			d = append(d, hangulLBase+i/hangulNCount, hangulVBase+i%hangulNCount/hangulTCount)
+			if t := i % hangulTCount; t != 0 {
+				d = append(d, hangulTBase+t)
+			}
+		} else {
+			d = append(d, r)
+		}
+	}
+	// canonical ordering: marks following a starter are sorted by class
+	for i := 0; i < len(d); i++ {
+		j := i
+		for j < len(d) && combiningClass(d[j]) != 0 {
+			j++
+		}
+		slices.SortStableFunc(d[i:j], func(a, b rune) int {
+			return cmp.Compare(combiningClass(a), combiningClass(b))
+		})
+		i = j
+	}
+	return string(d)
+}
+
+func toNFC(s string) string {
+	var (
+		out     []rune
+		starter = -1   // index of the last starter in out
+		base    string // decomposition of composed starter
+		last    uint8  // combining class of the last rune in out
+	)
+
+	for _, r := range toNFD(s) {
+		class := combiningClass(r)
+		// r is blocked from starter by a rune of class zero or not lower
+		if starter >= 0 && (starter == len(out)-1 || (last != 0 && last < class)) {
+			if c, ok := composeHangul(out[starter], r); ok {
+				out[starter], base = c, string(c)
+				continue
+			}
+			if c, ok := unicodeComp[base+string(r)]; ok {
+				out[starter], base = c, base+string(r)
+				continue
+			}
+		}
+		if class == 0 {
+			starter, base = len(out), string(r)
+		}
+		last = class
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// composeHangul composes leading consonant and vowel jamo, or LV syllable and
+// trailing consonant jamo.
+func composeHangul(s, r rune) (rune, bool) {
+	if l, v := s-hangulLBase, r-hangulVBase; l >= 0 && l < hangulLCount &&
+		v >= 0 && v < hangulVCount {
+		return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+	}
+	if i, t := s-hangulSBase, r-hangulTBase; i >= 0 && i < hangulSCount &&
+		i%hangulTCount == 0 && t > 0 && t < hangulTCount {
+		return s + t, true
+	}
+	return 0, false
+}
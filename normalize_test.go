@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		s, nfc, nfd string
+	}{
+		{"Cafe\u0301", "Caf\u00e9", "Cafe\u0301"},
+		{"Caf\u00e9", "Caf\u00e9", "Cafe\u0301"},
+		{"u\u0308\u0304", "\u01d6", "u\u0308\u0304"},
+		{"A\u030a\u0301", "\u01fa", "A\u030a\u0301"},
+		{"\u0301a", "\u0301a", "\u0301a"},
+		// Hangul
+		{"\uac00\uac01", "\uac00\uac01", "\u1100\u1161\u1100\u1161\u11a8"},
+		{"\u1100\u1161\u11a8", "\uac01", "\u1100\u1161\u11a8"},
+		{"\uac00\u11a8", "\uac01", "\u1100\u1161\u11a8"},
+		{"\uac01\u11a8", "\uac01\u11a8", "\u1100\u1161\u11a8\u11a8"},
+		{"\ud55c\uad6d\uc5b4", "\ud55c\uad6d\uc5b4",
+			"\u1112\u1161\u11ab\u1100\u116e\u11a8\u110b\u1165"},
+		// kana with dakuten and handakuten
+		{"\u304b\u3099", "\u304c", "\u304b\u3099"},
+		{"\u30cf\u309a", "\u30d1", "\u30cf\u309a"},
+		{"\u30ac\u30d1", "\u30ac\u30d1", "\u30ab\u3099\u30cf\u309a"},
+		// canonical ordering and blocked marks
+		{"a\u0301\u0323", "\u1ea1\u0301", "a\u0323\u0301"},
+		{"\u1e0b\u0323", "\u1e0d\u0307", "d\u0323\u0307"},
+		{"q\u0307\u0323", "q\u0323\u0307", "q\u0323\u0307"},
+		{"a\u0308\u0301\u0304", "\u00e4\u0301\u0304", "a\u0308\u0301\u0304"},
+		{"a\u0315\u0300\u05ae\u0300b", "\u00e0\u05ae\u0300\u0315b", "a\u05ae\u0300\u0300\u0315b"},
+	} {
+		if got := normalize(c.s, normNFC); got != c.nfc {
+			t.Errorf("NFC of %+q = %+q, want %+q", c.s, got, c.nfc)
+		}
+		if got := normalize(c.s, normNFD); got != c.nfd {
+			t.Errorf("NFD of %+q = %+q, want %+q", c.s, got, c.nfd)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var numVerbRe = regexp.MustCompile(`%[-+ 0#]*\d*[dxXob]`)
+
+// numFormat formats track numbers: with printf format like "%02d." or "A%d",
+// or as vinyl side and track like A1, A2, B1.
+type numFormat struct {
+	format string
+	side   []int // tracks per side, the last count repeats
+}
+
+// parseNumFormat parses -num-format: printf format with one integer verb,
+// or "vinyl:N[,N...]" with tracks per side. Empty format gives digits
+// zero-padded number.
+func parseNumFormat(s string, digits int) (f numFormat, err error) {
+	if s == "" {
+		return numFormat{format: fmt.Sprintf("%%0%dd", digits)}, nil
+	}
+	if count, ok := strings.CutPrefix(s, "vinyl:"); ok {
+		for _, c := range strings.Split(count, ",") {
+			n, err := strconv.Atoi(c)
+			if err != nil || n <= 0 {
+				return f, fmt.Errorf("wrong tracks per side '%v'", c)
+			}
+			f.side = append(f.side, n)
+		}
+		return
+	}
+	rest := strings.ReplaceAll(s, "%%", "")
+	if len(numVerbRe.FindAllString(rest, -1)) != 1 ||
+		strings.Count(numVerbRe.ReplaceAllString(rest, ""), "%") != 0 {
+		return f, fmt.Errorf("'%v' needs one integer verb like %%02d", s)
+	}
+	return numFormat{format: s}, nil
+}
+
+func (f numFormat) number(n int) string {
+	switch {
+	case f.format != "":
+		return fmt.Sprintf(f.format, n)
+	case f.side == nil:
+		return fmt.Sprintf("%0*d", defaultNumDigits, n)
+	}
+	side := 0
+	for n > f.side[min(side, len(f.side)-1)] {
+		n -= f.side[min(side, len(f.side)-1)]
+		side++
+	}
+	return sideName(side) + strconv.Itoa(n)
+}
+
+// sideName returns vinyl side letters: A...Z, AA, AB...
+func sideName(side int) string {
+	if side < 26 {
+		return string(rune('A' + side))
+	}
+	return sideName(side/26-1) + sideName(side%26)
+}
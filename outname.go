@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// File name policies of the global -names option.
+const (
+	namesWindows      = "windows"      // names valid on Windows and POSIX systems
+	namesPOSIX        = "posix"        // only slashes and NUL are replaced
+	namesConservative = "conservative" // Windows names of ASCII letters, digits and few marks
+)
+
+// namePolicy is set by the global -names option.
+var namePolicy = namesWindows
+
+// windowsReservedRe matches device names Windows reserves with any extension.
+var windowsReservedRe = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[0-9¹²³]|LPT[0-9¹²³])$`)
+
+// safeFileName replaces characters not allowed in file names by -names
+// policy. Conservative names are transliterated to Latin first.
+func safeFileName(s string) string {
+	if namePolicy == namesConservative {
+		s = transliterate(s)
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == 0:
+			return '_'
+		case namePolicy == namesPOSIX:
+			return r
+		case strings.ContainsRune(`\:*?"<>|`, r) || r < ' ' || r == 0x7f:
+			return '_'
+		case namePolicy == namesConservative && (r >= utf8.RuneSelf ||
+			!unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" ._-,()[]", r)):
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// safePathName makes file or directory name valid on Windows, unless -names
+// is posix: trailing dots and spaces are removed, and reserved device names
+// like CON or NUL.txt get "_" after the base name.
+func safePathName(name string) string {
+	if namePolicy == namesPOSIX || name == "." || name == ".." {
+		return name
+	}
+	if name = strings.TrimRight(name, ". "); name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedRe.MatchString(strings.TrimSpace(base)) {
+		name = base + "_" + name[len(base):]
+	}
+	return name
+}
+
+// safePath applies safePathName to every name in path.
+func safePath(path string) string {
+	vol := filepath.VolumeName(path)
+	name := strings.Split(path[len(vol):], string(filepath.Separator))
+	for i, n := range name {
+		if n != "" {
+			name[i] = safePathName(n)
+		}
+	}
+	return vol + strings.Join(name, string(filepath.Separator))
+}
+
+// outputName is output file name template data. Values are made safe for
+// file names, so only the template itself makes directories.
+type outputName struct {
+	Album     string
+	Performer string
+	Disc      string // cue FILE number starting at 1
+	File      string // cue FILE name without extension
+	Num       string // two digit track number
+	Title     string
+}
+
+// isNameTemplate reports whether output path is a template like
+// "{{.Album}}/{{.Num}} - {{.Title}}.flac".
+func isNameTemplate(path string) bool {
+	return strings.Contains(path, "{{")
+}
+
+func parseNameTemplate(path string) (*template.Template, error) {
+	return template.New("").Parse(path)
+}
+
+// path returns output file path made by template, creating its directory.
+func (n outputName) path(tmpl *template.Template) (string, error) {
+	var b strings.Builder
+
+	for _, v := range []*string{&n.Album, &n.Performer, &n.Disc, &n.File, &n.Num, &n.Title} {
+		if *v = strings.TrimSpace(safeFileName(*v)); *v == "." || *v == ".." {
+			*v = "_"
+		}
+	}
+	if err := tmpl.Execute(&b, n); err != nil {
+		return "", err
+	}
+	path := filepath.Clean(b.String())
+	if path == "." || strings.HasSuffix(b.String(), "/") {
+		return "", fmt.Errorf("no file name in '%v'", b.String())
+	}
+	path = safePath(path)
+	if err := os.MkdirAll(fileDir(path), 0777); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// outputFormat is set by the global -format option.
+var outputFormat = outputText
+
+// deterministic is set by the global -deterministic option: output depends
+// only on inputs and options, not on installed tools or current time.
+var deterministic bool
+
+// jsonTime is time written as JSON number of seconds.
+type jsonTime Timestamp
+
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+	return []byte(formatTimeSec(Timestamp(t))), nil
+}
+
+func (t *jsonTime) UnmarshalJSON(b []byte) error {
+	v, err := parseToolTime(string(b))
+	*t = jsonTime(v)
+	return err
+}
+
+type jsonTimeConv struct {
+	Sec jsonTime `json:"sec"`
+	Cue string   `json:"cue"`
+}
+
+type jsonLabel struct {
+	Start jsonTime `json:"start"`
+	End   jsonTime `json:"end"`
+	Title string   `json:"title"`
+}
+
+type jsonTrackLength struct {
+	Track    string    `json:"track"`
+	Start    jsonTime  `json:"start"`
+	Duration *jsonTime `json:"duration"`
+	Sectors  *int64    `json:"sectors"`
+	Bytes    *int64    `json:"bytes"`
+}
+
+type jsonPoint struct {
+	Track    int       `json:"track"`
+	Title    string    `json:"title"`
+	Start    jsonTime  `json:"start"`
+	End      *jsonTime `json:"end"`
+	Duration *jsonTime `json:"duration"`
+}
+
+type jsonARTrack struct {
+	Track      int    `json:"track"`
+	CRC1       string `json:"crc1"`
+	CRC2       string `json:"crc2"`
+	Status     string `json:"status,omitempty"`
+	Confidence int    `json:"confidence,omitempty"`
+}
+
+type jsonAccurateRip struct {
+	DiscID string        `json:"disc_id"`
+	URL    string        `json:"url"`
+	Tracks []jsonARTrack `json:"tracks,omitempty"`
+}
+
+type jsonVerify struct {
+	Track    string    `json:"track"`
+	Status   string    `json:"status"`
+	Expected *jsonTime `json:"expected,omitempty"`
+	Actual   *jsonTime `json:"actual,omitempty"`
+}
+
+type jsonMergeIndex struct {
+	Track  int      `json:"track"`
+	Index  int      `json:"index"`
+	Time   jsonTime `json:"time"`
+	Status string   `json:"status"`
+}
+
+type jsonVerifyMerge struct {
+	Merged  jsonVerify       `json:"merged"`
+	Indexes []jsonMergeIndex `json:"indexes"`
+}
+
+type jsonFileTags struct {
+	File string            `json:"file"`
+	Tags map[string]string `json:"tags"`
+}
+
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	panicIfError(enc.Encode(v))
+}
+
+type jsonCueTrack struct {
+	Title     string     `json:"title"`
+	Performer string     `json:"performer,omitempty"`
+	ISRC      string     `json:"isrc,omitempty"`
+	Flags     []string   `json:"flags,omitempty"`
+	Start     jsonTime   `json:"start"`
+	Index00   *jsonTime  `json:"index00,omitempty"`
+	Subindex  []jsonTime `json:"subindex,omitempty"`
+}
+
+type jsonCueWarning struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+type jsonCueSheet struct {
+	Catalog   string           `json:"catalog,omitempty"`
+	Title     string           `json:"title,omitempty"`
+	Performer string           `json:"performer,omitempty"`
+	Tracks    []jsonCueTrack   `json:"tracks"`
+	Warnings  []jsonCueWarning `json:"warnings,omitempty"`
+}
+
+func newJSONCueSheet(sheet cueSheet) (js jsonCueSheet) {
+	js = jsonCueSheet{Catalog: sheet.catalog, Title: sheet.title, Performer: sheet.performer}
+	js.Tracks = make([]jsonCueTrack, 0, len(sheet.label))
+	for _, l := range sheet.label {
+		t := jsonCueTrack{Title: l.title, Performer: l.performer, ISRC: l.isrc,
+			Flags: l.flags, Start: jsonTime(l.start)}
+		if l.index00 >= 0 {
+			i := jsonTime(l.index00)
+			t.Index00 = &i
+		}
+		for _, s := range l.subindex {
+			t.Subindex = append(t.Subindex, jsonTime(s))
+		}
+		js.Tracks = append(js.Tracks, t)
+	}
+	for _, w := range sheet.warning {
+		js.Warnings = append(js.Warnings, jsonCueWarning{w.line, w.msg})
+	}
+	return
+}
+
+type jsonSelftest struct {
+	Format string   `json:"format"`
+	Tracks int      `json:"tracks"`
+	Losses []string `json:"losses,omitempty"`
+}
+
+type jsonCacheStats struct {
+	Path    string `json:"path"`
+	Entries int    `json:"entries"`
+	Stale   int    `json:"stale"`
+	Missing int    `json:"missing"`
+}
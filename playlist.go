@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// readPlaylist returns track paths of M3U or PLS playlist in playlist
+// order. Relative paths are resolved against the playlist directory.
+func readPlaylist(playlistPath string) (trackFilePath []string, err error) {
+	f, err := os.Open(playlistPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(playlistPath), ".pls") {
+		trackFilePath, err = readPLS(f)
+	} else {
+		trackFilePath, err = readM3U(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read playlist: %w", err)
+	}
+	dir := filepath.Dir(playlistPath)
+	for i, path := range trackFilePath {
+		trackFilePath[i] = playlistEntryPath(dir, path)
+	}
+	return
+}
+
+func readM3U(r io.Reader) (entry []string, err error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		s := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if s != "" && !strings.HasPrefix(s, "#") {
+			entry = append(entry, s)
+		}
+	}
+	return entry, sc.Err()
+}
+
+func readPLS(r io.Reader) (entry []string, err error) {
+	type plsFile struct {
+		n    int
+		path string
+	}
+	var file []plsFile
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok || len(key) < 5 || !strings.EqualFold(key[:4], "file") {
+			continue
+		}
+		n, err := strconv.Atoi(key[4:])
+		if err != nil {
+			return nil, fmt.Errorf("wrong PLS key '%v'", key)
+		}
+		file = append(file, plsFile{n, value})
+	}
+	if err = sc.Err(); err != nil {
+		return
+	}
+	slices.SortStableFunc(file, func(a, b plsFile) int { return a.n - b.n })
+	for _, f := range file {
+		entry = append(entry, f.path)
+	}
+	return
+}
+
+// playlistEntryPath converts playlist entry, either path or file URL, to
+// local file path.
+func playlistEntryPath(dir, entry string) string {
+	if u, err := url.Parse(entry); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path)
+	}
+	if filepath.Separator == '/' {
+		entry = strings.ReplaceAll(entry, `\`, "/")
+	}
+	if filepath.IsAbs(entry) {
+		return entry
+	}
+	return filepath.Join(dir, filepath.FromSlash(entry))
+}
+
+// tracks returns tracks from -from-playlist followed by track arguments.
+func (opt *cueOptions) tracks(arg []string) []string {
+	if opt.playlist == "" {
+		return arg
+	}
+	trackFilePath, err := readPlaylist(opt.playlist)
+	if err != nil {
+		panic("Cannot read playlist: " + err.Error())
+	}
+	return append(trackFilePath, arg...)
+}
+
+// readOrderFile reads file names, one per line, skipping empty lines and
+// # comments.
+func readOrderFile(path string) (name []string, err error) {
+	f := openInput(path)
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		if s := strings.TrimSpace(scan.Text()); s != "" && !strings.HasPrefix(s, "#") {
+			name = append(name, s)
+		}
+	}
+	return name, scan.Err()
+}
+
+// orderTracks moves tracks listed by path or base name to the front in list
+// order. Not listed tracks follow in their order.
+func orderTracks(trackFilePath, name []string) error {
+	var ordered []string
+
+	abs := func(path string) string {
+		if a, err := filepath.Abs(path); err == nil {
+			return a
+		}
+		return filepath.Clean(path)
+	}
+	used := make([]bool, len(trackFilePath))
+	for _, n := range name {
+		m := -1
+		for i, path := range trackFilePath {
+			if abs(path) == abs(n) {
+				m = i
+				break
+			}
+			if filepath.Base(path) == n && m < 0 {
+				m = i
+			}
+		}
+		if m < 0 {
+			return fmt.Errorf("'%v' is not among input tracks", n)
+		}
+		if used[m] {
+			return fmt.Errorf("'%v' is listed twice", n)
+		}
+		used[m] = true
+		ordered = append(ordered, trackFilePath[m])
+	}
+	for i, path := range trackFilePath {
+		if !used[i] {
+			logWarningMessage(fmt.Sprintf("track '%v' is not in order file", path))
+			ordered = append(ordered, path)
+		}
+	}
+	copy(trackFilePath, ordered)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+const defaultSampleRate = cdSampleRate
+
+// Time units of points command.
+var pointUnits = []string{"sec", "ms", "cue", "hms", "samples"}
+
+// doCmdPoints prints start, end and duration of every cue track for cutting
+// tracks by hand, e.g. with ffmpeg -ss and -to.
+func doCmdPoints(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		outFilePath   string
+		unit          string
+		rate          int
+		filter        trackFilter
+		cueRd         io.ReadCloser
+		outWr         io.WriteCloser
+		end           Timestamp = -1
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "cue audio file to get the last track end")
+	fl.StringVar(&outFilePath, "o", "", "output file path")
+	fl.StringVar(&unit, "unit", "sec", "time unit: sec, ms, cue, hms or samples")
+	fl.IntVar(&rate, "rate", defaultSampleRate, "sample rate for samples unit")
+	addProbeFlags(fl)
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if !slices.Contains(pointUnits, unit) {
+		panic(fmt.Sprintf("Wrong time unit '%v', expected one of %v", unit, pointUnits))
+	}
+	if rate <= 0 {
+		panic("Wrong sample rate: " + strconv.Itoa(rate))
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	label := parseCue(cueRd, cueAudioFile).label
+	if audioFilePath != "" {
+		end, err = getMediaDuration(audioFilePath)
+		panicIfError(err)
+	}
+	track := cueTrackLengths(label, end)
+	label, track = filter.apply(label), filter.applyLengths(label, track)
+
+	outWr = createOutput(outFilePath)
+	defer outWr.Close()
+	writePoints(outWr, label, track, unit, rate)
+}
+
+func writePoints(w io.Writer, label []cueLabel, track []trackLength, unit string, rate int) {
+	var err error
+
+	if outputFormat == outputJSON {
+		js := make([]jsonPoint, 0, len(label))
+		for i, l := range label {
+			p := jsonPoint{Track: l.num, Title: l.title, Start: jsonTime(l.start)}
+			if d := track[i].duration; d >= 0 {
+				end, dur := jsonTime(l.start+d), jsonTime(d)
+				p.End, p.Duration = &end, &dur
+			}
+			js = append(js, p)
+		}
+		writeJSON(w, js)
+		return
+	}
+
+	for i, l := range label {
+		end, dur := "?", "?"
+		if d := track[i].duration; d >= 0 {
+			end, dur = formatPoint(l.start+d, unit, rate), formatPoint(d, unit, rate)
+		}
+		_, err = fmt.Fprintf(w, "%02d\t%v\t%v\t%v\t%v\n", l.num,
+			formatPoint(l.start, unit, rate), end, dur, l.title)
+		panicIfError(err)
+	}
+}
+
+func formatPoint(t Timestamp, unit string, rate int) string {
+	switch unit {
+	case "ms":
+		return strconv.FormatInt(t.Millis(RoundNearest), 10)
+	case "cue":
+		return formatCueTime(t)
+	case "hms":
+		return formatVorbisTime(t)
+	case "samples":
+		return strconv.FormatInt(t.Samples(int64(rate), RoundNearest), 10)
+	}
+	return formatTimeSec(t)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ffprobeStream struct {
+	SampleRate string  `json:"sample_rate"`
+	TimeBase   string  `json:"time_base"`
+	Duration   *string `json:"duration"`
+	DurationTS *int64  `json:"duration_ts"`
+	NbSamples  *int64  `json:"nb_samples"`
+}
+
+// isVideoFile reports whether file is a video by extension. Its audio stream
+// may be shorter or longer than the container, so the stream duration is used.
+func isVideoFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mkv", ".webm", ".mov", ".avi", ".mpg", ".mpeg",
+		".ts", ".m2ts", ".wmv", ".flv":
+		return true
+	}
+	return false
+}
+
+// getStreamDuration returns duration of the first audio stream, exact if
+// ffprobe reports duration_ts, or zero if the stream has no duration. ffprobe
+// is killed when ctx is done.
+func getStreamDuration(ctx context.Context, filePath string) (dur Timestamp, err error) {
+	var (
+		out []byte
+		js  struct {
+			Streams []ffprobeStream `json:"streams"`
+		}
+	)
+
+	out, err = runCommandContext(ctx, "ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "a:0",
+		"-show_streams",
+		"-i", filePath)
+	if err != nil {
+		err = fmt.Errorf("get stream duration: ffprobe: %w", err)
+		return
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		err = fmt.Errorf("get stream duration: %w", err)
+		return
+	}
+	if len(js.Streams) == 0 {
+		return
+	}
+	if dur, err = js.Streams[0].duration(); err != nil {
+		err = fmt.Errorf("get stream duration: '%v': %w", filePath, err)
+	}
+	return
+}
+
+// duration converts stream duration to Timestamp rounding to nearest.
+func (s *ffprobeStream) duration() (Timestamp, error) {
+	var ts, num, den int64
+	var err error
+
+	if s.DurationTS != nil && s.TimeBase != "" {
+		if num, den, err = parseTimeBase(s.TimeBase); err != nil {
+			return 0, err
+		}
+		ts = *s.DurationTS
+	} else if s.NbSamples != nil && s.SampleRate != "" {
+		rate, err := strconv.ParseInt(s.SampleRate, 10, 64)
+		if err != nil || rate <= 0 {
+			return 0, fmt.Errorf("wrong sample rate '%v'", s.SampleRate)
+		}
+		ts, num, den = *s.NbSamples, 1, rate
+	} else if s.Duration != nil {
+		return parseToolTime(*s.Duration)
+	} else {
+		return 0, nil
+	}
+	if ts <= 0 {
+		return 0, fmt.Errorf("wrong stream duration %v", ts)
+	}
+	return scaleTimeBase(ts, num, den)
+}
+
+// getPacketDuration reads all packets of the first audio stream and returns
+// the time from the first packet start to the last packet end.
+func getPacketDuration(ctx context.Context, filePath string) (dur Timestamp, err error) {
+	var (
+		out []byte
+		js  struct {
+			Streams []ffprobeStream `json:"streams"`
+			Packets []struct {
+				PTS      *int64 `json:"pts"`
+				Duration *int64 `json:"duration"`
+			} `json:"packets"`
+		}
+		num, den   int64
+		start, end int64
+	)
+
+	out, err = runCommandContext(ctx, "ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=time_base:packet=pts,duration",
+		"-i", filePath)
+	if err != nil {
+		err = fmt.Errorf("get packet duration: ffprobe: %w", err)
+		return
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		err = fmt.Errorf("get packet duration: %w", err)
+		return
+	}
+	if len(js.Streams) == 0 || len(js.Packets) == 0 {
+		return
+	}
+	if num, den, err = parseTimeBase(js.Streams[0].TimeBase); err != nil {
+		err = fmt.Errorf("get packet duration: '%v': %w", filePath, err)
+		return
+	}
+	start = -1
+	for _, p := range js.Packets {
+		if p.PTS == nil {
+			continue
+		}
+		if start < 0 || *p.PTS < start {
+			start = *p.PTS
+		}
+		e := *p.PTS
+		if p.Duration != nil {
+			e += *p.Duration
+		}
+		end = max(end, e)
+	}
+	if start < 0 || end <= start {
+		return
+	}
+	if dur, err = scaleTimeBase(end-start, num, den); err != nil {
+		err = fmt.Errorf("get packet duration: '%v': %w", filePath, err)
+	}
+	return
+}
+
+func parseTimeBase(tb string) (num, den int64, err error) {
+	n, d, ok := strings.Cut(tb, "/")
+	if ok {
+		num, err = strconv.ParseInt(n, 10, 64)
+		if err == nil {
+			den, err = strconv.ParseInt(d, 10, 64)
+		}
+	}
+	if !ok || err != nil || num <= 0 || den <= 0 {
+		return 0, 0, fmt.Errorf("wrong time base '%v'", tb)
+	}
+	return
+}
+
+// scaleTimeBase converts ts in num/den units to microseconds.
+func scaleTimeBase(ts, num, den int64) (Timestamp, error) {
+	// ts * num * uSecInSecond may overflow int64
+	v := new(big.Int).Mul(big.NewInt(ts), big.NewInt(num))
+	v.Mul(v, big.NewInt(uSecInSecond))
+	v.Add(v, big.NewInt(den/2))
+	v.Quo(v, big.NewInt(den))
+	if !v.IsInt64() {
+		return 0, fmt.Errorf("duration overflow")
+	}
+	return Timestamp(v.Int64()), nil
+}
+
+// getMediaChapters returns chapters of media file in file time.
+func getMediaChapters(filePath string) (chap []chapter, err error) {
+	var (
+		out []byte
+		js  struct {
+			Chapters []struct {
+				StartTime string            `json:"start_time"`
+				EndTime   string            `json:"end_time"`
+				Tags      map[string]string `json:"tags"`
+			} `json:"chapters"`
+		}
+		c chapter
+	)
+
+	out, err = runCommand("ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_chapters",
+		"-i", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("get media chapters: ffprobe: %w", err)
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		return nil, fmt.Errorf("get media chapters: %w", err)
+	}
+	for _, jc := range js.Chapters {
+		if c.start, err = parseToolTime(jc.StartTime); err != nil {
+			return nil, fmt.Errorf("get media chapters: '%v': %w", filePath, err)
+		}
+		if c.end, err = parseToolTime(jc.EndTime); err != nil {
+			return nil, fmt.Errorf("get media chapters: '%v': %w", filePath, err)
+		}
+		c.title = jc.Tags["title"]
+		chap = append(chap, c)
+	}
+	return
+}
+
+// chapterSubindexes returns start times of chapters within every track, but
+// the one at the track start, as INDEX 02 and later times by track index.
+func chapterSubindexes(trackFilePath []string, start []Timestamp) (sub [][]Timestamp, err error) {
+	sub = make([][]Timestamp, len(trackFilePath))
+	for i, track := range trackFilePath {
+		chap, err := getMediaChapters(track)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chap {
+			if c.start <= 0 {
+				continue
+			}
+			if len(sub[i]) == maxCueIndex-1 {
+				return nil, fmt.Errorf("track '%v' has more than %d chapters", track, maxCueIndex-1)
+			}
+			sub[i] = append(sub[i], start[i]+c.start)
+		}
+	}
+	return
+}
+
+// expandChapters replaces every track having more than one chapter with
+// chapter tracks. Chapters without title are named by track title and
+// chapter number.
+func expandChapters(trackFilePath []string, start []Timestamp,
+	title []string) (chStart []Timestamp, chTitle []string, err error) {
+	var chap []chapter
+
+	for i, track := range trackFilePath {
+		if chap, err = getMediaChapters(track); err != nil {
+			return nil, nil, err
+		}
+		if len(chap) < 2 {
+			chStart, chTitle = append(chStart, start[i]), append(chTitle, title[i])
+			continue
+		}
+		for j, c := range chap {
+			if c.title == "" {
+				c.title = fmt.Sprintf("%v %d", title[i], j+1)
+			}
+			chStart = append(chStart, start[i]+c.start)
+			chTitle = append(chTitle, c.title)
+		}
+	}
+	return
+}
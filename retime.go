@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSilenceNoise = -50 // dB
+	// retimeTolerance is accepted difference of expected and trimmed audio
+	// lengths.
+	retimeTolerance = uSecInSecond / 2
+)
+
+// timeRange is audio part from start to end.
+type timeRange struct {
+	start, end Timestamp
+}
+
+var silenceRe = regexp.MustCompile(`silence_(start|end): *(-?[0-9.]+)`)
+
+// readCutList reads removed regions, a "start end" pair of times per line,
+// skipping empty lines and # comments.
+func readCutList(r io.Reader) (cut []timeRange, err error) {
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		s := strings.TrimSpace(scan.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		f := strings.Fields(strings.Replace(s, "-", " ", 1))
+		if len(f) != 2 {
+			return nil, fmt.Errorf("line %d: expected start and end time", n)
+		}
+		var c timeRange
+		if c.start, err = parseTime(f[0]); err == nil {
+			c.end, err = parseTime(f[1])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		cut = append(cut, c)
+	}
+	return cut, scan.Err()
+}
+
+// detectSilence returns silent parts of audio found by ffmpeg silencedetect.
+func detectSilence(filePath string, noise float64, minLen, end Timestamp) (silence []timeRange,
+	err error) {
+	_, stderr, err := runCommandInput(nil, "ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-i", filePath,
+		"-map", "0:a",
+		"-af", fmt.Sprintf("silencedetect=noise=%vdB:d=%v",
+			strconv.FormatFloat(noise, 'f', -1, 64), formatTimeSec(minLen)),
+		"-f", "null", "-")
+	if err != nil {
+		return nil, fmt.Errorf("detect silence: ffmpeg: %w", err)
+	}
+	for _, m := range silenceRe.FindAllStringSubmatch(string(stderr), -1) {
+		t, err := parseToolTime(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("detect silence: %w", err)
+		}
+		t = max(t, 0)
+		switch {
+		case m[1] == "start":
+			silence = append(silence, timeRange{t, -1})
+		case len(silence) > 0 && silence[len(silence)-1].end < 0:
+			silence[len(silence)-1].end = t
+		}
+	}
+	// silence lasting to the end of audio has no end
+	if len(silence) > 0 && silence[len(silence)-1].end < 0 {
+		silence[len(silence)-1].end = end
+	}
+	return
+}
+
+// sortCuts sorts removed regions and checks they do not overlap.
+func sortCuts(cut []timeRange) error {
+	slices.SortFunc(cut, func(a, b timeRange) int { return int(a.start - b.start) })
+	for i, c := range cut {
+		if c.end < c.start {
+			return fmt.Errorf("region %v-%v ends before its start",
+				formatTimeSec(c.start), formatTimeSec(c.end))
+		}
+		if i > 0 && c.start < cut[i-1].end {
+			return fmt.Errorf("regions %v-%v and %v-%v overlap",
+				formatTimeSec(cut[i-1].start), formatTimeSec(cut[i-1].end),
+				formatTimeSec(c.start), formatTimeSec(c.end))
+		}
+	}
+	return nil
+}
+
+// trimmedTime returns time t of audio with sorted regions cut removed. Times
+// inside a removed region move to its start.
+func trimmedTime(t Timestamp, cut []timeRange) Timestamp {
+	var removed Timestamp
+
+	for _, c := range cut {
+		switch {
+		case t >= c.end:
+			removed += c.end - c.start
+		case t > c.start:
+			return c.start - removed
+		}
+	}
+	return t - removed
+}
+
+// doCmdRetime recomputes cue INDEX times of audio file after removing silence
+// or other regions from it. Other cue lines are kept intact.
+func doCmdRetime(arg []string) {
+	var (
+		cueFilePath  string
+		outFilePath  string
+		cueAudioFile int
+		cutFilePath  string
+		oldAudio     string
+		newAudio     string
+		keepSpec     string
+		minLenSpec   string
+		noise        float64
+		keep, minLen Timestamp
+		cut          []timeRange
+		data         []byte
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.StringVar(&outFilePath, "o", "", "output cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&cutFilePath, "cut", "", "file of removed regions, start and end time per line")
+	fl.StringVar(&oldAudio, "detect", "", "find removed silence in original audio file")
+	fl.StringVar(&keepSpec, "keep", "0", "silence length kept of every detected silence")
+	fl.StringVar(&minLenSpec, "min-silence", "2",
+		"shortest detected silence")
+	fl.Float64Var(&noise, "noise", defaultSilenceNoise, "silence level in dB")
+	fl.StringVar(&newAudio, "audio", "", "trimmed audio file to check the cue against")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if (cutFilePath == "") == (oldAudio == "") {
+		panic("Expected one of -cut and -detect")
+	}
+	if keep, err = parseTime(keepSpec); err != nil || keep < 0 {
+		panic("Wrong kept silence length: " + keepSpec)
+	}
+	if minLen, err = parseTime(minLenSpec); err != nil || minLen <= 0 {
+		panic("Wrong minimum silence length: " + minLenSpec)
+	}
+
+	in := openInput(cueFilePath)
+	data, err = io.ReadAll(in)
+	in.Close()
+	if err != nil {
+		panic("Read cue " + inputName(in) + ": " + err.Error())
+	}
+	// validate the cue before changing it
+	parseCue(newNamedReader(data, cueFilePath), cueAudioFile)
+
+	if cutFilePath != "" {
+		f := openInput(cutFilePath)
+		cut, err = readCutList(f)
+		f.Close()
+		if err != nil {
+			panic("Wrong cut list: " + err.Error())
+		}
+	} else {
+		oldEnd, err := getMediaDuration(oldAudio)
+		panicIfError(err)
+		silence, err := detectSilence(oldAudio, noise, minLen, oldEnd)
+		panicIfError(err)
+		// the middle of silence is removed, keeping its both ends
+		for _, s := range silence {
+			if d := s.end - s.start - keep; d > 0 {
+				c := timeRange{s.start + keep/2, s.end - keep + keep/2}
+				cut = append(cut, c)
+			}
+		}
+		if newAudio != "" {
+			var removed Timestamp
+			for _, c := range cut {
+				removed += c.end - c.start
+			}
+			newEnd, err := getMediaDuration(newAudio)
+			panicIfError(err)
+			if d := oldEnd - removed - newEnd; d > retimeTolerance || d < -retimeTolerance {
+				logWarningMessage(fmt.Sprintf("detected silence %v does not match "+
+					"trimmed audio, %v shorter than original", formatTimeSec(removed),
+					formatTimeSec(oldEnd-newEnd)))
+			}
+		}
+	}
+	if err = sortCuts(cut); err != nil {
+		panic("Wrong removed regions: " + err.Error())
+	}
+
+	data = retimeCue(data, cueAudioFile, cut)
+	sheet := parseCueSheet(newNamedReader(data, cueFilePath), cueAudioFile)
+	if newAudio != "" && len(sheet.label) > 0 {
+		end, err := getMediaDuration(newAudio)
+		panicIfError(err)
+		if l := sheet.label[len(sheet.label)-1]; l.start >= end {
+			panic(fmt.Sprintf("Track %d starts at %v after the end of %v", l.num,
+				l.start.String(), newAudio))
+		}
+	}
+	out := createOutput(outFilePath)
+	_, err = out.Write(data)
+	panicIfError(err)
+	panicIfError(out.Close())
+}
+
+// retimeCue changes INDEX and REM INDEX01-SEC times of audio file cueAudioFile
+// to times of trimmed audio.
+func retimeCue(data []byte, cueAudioFile int, cut []timeRange) []byte {
+	audioFile := -1
+	line := bytes.SplitAfter(data, []byte("\n"))
+	for i, l := range line {
+		f := strings.Fields(strings.TrimPrefix(string(l), "\ufeff"))
+		if len(f) == 0 {
+			continue
+		}
+		f[0] = strings.ToUpper(f[0])
+		switch {
+		case f[0] == "FILE":
+			audioFile++
+		case audioFile != cueAudioFile:
+		case f[0] == "INDEX" && len(f) == 3:
+			t, err := parseCueTime(f[2])
+			panicIfError(err)
+			line[i] = replaceLineFields(l, fmt.Sprintf("INDEX %v %v", f[1],
+				formatCueTime(trimmedTime(t, cut))))
+		case f[0] == "REM" && len(f) == 3 && strings.EqualFold(f[1], "INDEX01-SEC"):
+			t, err := parseToolTime(f[2])
+			panicIfError(err)
+			line[i] = replaceLineFields(l, "REM INDEX01-SEC "+formatTimeSec(trimmedTime(t, cut)))
+		}
+	}
+	return bytes.Join(line, nil)
+}
+
+// replaceLineFields replaces line text keeping its indentation and line end.
+func replaceLineFields(l []byte, s string) []byte {
+	end := l[len(bytes.TrimRight(l, "\r\n")):]
+	return append([]byte(lineIndent(l)+s), end...)
+}
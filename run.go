@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// jobSpec is a JSON or YAML job file run by the run command. Paths are
+// relative to the job file directory.
+type jobSpec struct {
+	Inputs  []string    `json:"inputs"`
+	Outputs []jobOutput `json:"outputs"`
+}
+
+// jobOutput runs one command. Options are passed as -name value, or -name
+// for true booleans. Job inputs are appended unless Inputs is set.
+type jobOutput struct {
+	Command string         `json:"command"`
+	Options map[string]any `json:"options"`
+	Inputs  *[]string      `json:"inputs"`
+}
+
+// run is registered in init to break initialization cycle with commandTab.
+func init() {
+	commandTab["run"] = doCmdRun
+}
+
+func doCmdRun(arg []string) {
+	var (
+		spec jobSpec
+		data []byte
+		err  error
+	)
+
+	if len(arg) != 2 {
+		panic("Expected one job file")
+	}
+	f := openInput(arg[1])
+	data, err = io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		panic("Cannot read job file: " + err.Error())
+	}
+	if err = parseJob(data, &spec); err != nil {
+		panic("Wrong job file: " + err.Error())
+	}
+	if len(spec.Outputs) == 0 {
+		panic("No outputs in job file")
+	}
+	if !isStdio(arg[1]) {
+		if err = os.Chdir(filepath.Dir(arg[1])); err != nil {
+			panic("Cannot change directory: " + err.Error())
+		}
+	}
+
+	inputs := expandInputs(spec.Inputs)
+	for i, out := range spec.Outputs {
+		cmd, ok := commandTab[out.Command]
+		if !ok || out.Command == "run" {
+			panic(fmt.Sprintf("Job output %d: %v", i+1, unknownCommand(out.Command)))
+		}
+		cmdArg, err := out.args(inputs)
+		if err != nil {
+			panic(fmt.Sprintf("Job output %d: %v", i+1, err))
+		}
+		runCmd(cmd, cmdArg)
+	}
+}
+
+// parseJob parses JSON job starting with { or YAML job otherwise. YAML is
+// converted to JSON to be decoded the same way.
+func parseJob(data []byte, spec *jobSpec) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		v, err := parseYAML(data)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, spec)
+}
+
+func (out *jobOutput) args(inputs []string) (arg []string, err error) {
+	var name []string
+
+	arg = []string{out.Command}
+	for k := range out.Options {
+		name = append(name, k)
+	}
+	slices.Sort(name)
+	for _, k := range name {
+		switch v := out.Options[k].(type) {
+		case bool:
+			arg = append(arg, fmt.Sprintf("-%v=%v", k, v))
+		case string:
+			arg = append(arg, "-"+k, v)
+		case float64:
+			arg = append(arg, "-"+k, fmt.Sprint(v))
+		case []any:
+			// repeated flag
+			for _, e := range v {
+				arg = append(arg, "-"+k, fmt.Sprint(e))
+			}
+		default:
+			return nil, fmt.Errorf("wrong value of option '%v'", k)
+		}
+	}
+	if out.Inputs != nil {
+		inputs = expandInputs(*out.Inputs)
+	}
+	return append(arg, inputs...), nil
+}
+
+// expandInputs expands glob patterns keeping literal paths without matches.
+func expandInputs(pattern []string) (path []string) {
+	for _, p := range pattern {
+		m, err := filepath.Glob(p)
+		if err != nil || len(m) == 0 {
+			path = append(path, p)
+			continue
+		}
+		slices.Sort(m)
+		path = append(path, m...)
+	}
+	return
+}
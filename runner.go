@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandRunner runs external tools: ffprobe, ffmpeg, taggers, sqlite3 and
+// converters. It can be replaced to run tools in a sandbox or container, or
+// with a fake for testing.
+type commandRunner interface {
+	Run(ctx context.Context, stdin io.Reader, name string,
+		arg ...string) (stdout, stderr []byte, err error)
+}
+
+// execRunner runs tools as local processes.
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, stdin io.Reader, name string,
+	arg ...string) (stdout, stderr []byte, err error) {
+	var outBuf, errBuf bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	// children of a killed command may keep its output open
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// runner runs all external commands.
+var runner commandRunner = execRunner{}
+
+// runCommand returns command stdout. Error includes the last stderr line.
+func runCommand(command string, args ...string) ([]byte, error) {
+	return runCommandContext(context.Background(), command, args...)
+}
+
+// runCommandContext is runCommand killing the command when ctx is done.
+func runCommandContext(ctx context.Context, command string, args ...string) ([]byte, error) {
+	out, _, err := runCommandInputContext(ctx, nil, command, args...)
+	return out, err
+}
+
+// runCommandInput runs command with stdin and returns its stdout and stderr.
+func runCommandInput(stdin io.Reader, command string, args ...string) (stdout, stderr []byte,
+	err error) {
+	return runCommandInputContext(context.Background(), stdin, command, args...)
+}
+
+func runCommandInputContext(ctx context.Context, stdin io.Reader, command string,
+	args ...string) (stdout, stderr []byte, err error) {
+	defer addCommandTiming(command, time.Now())
+	stdout, stderr, err = runner.Run(ctx, stdin, command, args...)
+	if err != nil && ctx.Err() != nil {
+		// killed command has no useful message
+		return stdout, stderr, ctx.Err()
+	}
+	if err != nil {
+		msg := strings.TrimSpace(string(stderr))
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		if msg != "" {
+			err = fmt.Errorf("%w: %v", err, msg)
+		}
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeRunner answers commands with fixed output, recording their arguments.
+type fakeRunner struct {
+	stdout, stderr string
+	err            error
+	name           string
+	arg            []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, _ io.Reader, name string,
+	arg ...string) (stdout, stderr []byte, err error) {
+	r.name, r.arg = name, arg
+	return []byte(r.stdout), []byte(r.stderr), r.err
+}
+
+func setRunner(t *testing.T, r commandRunner) {
+	saved := runner
+	runner = r
+	t.Cleanup(func() { runner = saved })
+}
+
+func TestFfprobeDuration(t *testing.T) {
+	r := &fakeRunner{stdout: `{"format":{"duration":"12.500000","start_time":"0.250000"}}`}
+	setRunner(t, r)
+	dur, err := ffprobeBackend{}.duration(context.Background(), "a.flac")
+	if err != nil || dur != 12250000 {
+		t.Errorf("duration = %v, %v, want 12250000", dur, err)
+	}
+	if r.name != "ffprobe" || !slices.Contains(r.arg, "a.flac") {
+		t.Errorf("ran %v %v", r.name, r.arg)
+	}
+}
+
+func TestRunCommandError(t *testing.T) {
+	setRunner(t, &fakeRunner{stderr: "first\nlast line\n", err: errors.New("exit status 1")})
+	_, err := runCommand("ffmpeg", "-i", "a.flac")
+	if err == nil || !strings.HasSuffix(err.Error(), "exit status 1: last line") {
+		t.Errorf("runCommand error = %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+)
+
+// selftestTitles are track titles in several scripts and Unicode forms. Cue
+// strings are not escaped and cannot hold double quotes, so titles have
+// neither quotes nor backslashes.
+var selftestTitles = []string{
+	"Intro",
+	"L'été indien",
+	"Straße nach Süden",
+	"Θεσσαλονίκη",
+	"Пётр Ильич — Щелкунчик",
+	"東京の夜",
+	"서울의 봄",
+	"مرحبا بالعالم",
+	"שלום",
+	"Cafe\u0301 (NFD)",
+	"Rock & Roll (Live) [2024 Remaster]",
+	"100% Pure 🎵",
+	"Part 2/3: Allegro; ma non troppo",
+	"  Spaced  Out  ",
+	"Ünïcödé Ωmega",
+	"Ça va? ¡Sí!",
+}
+
+// selftestFormat is a format tracks are converted to and read back.
+type selftestFormat struct {
+	name    string
+	convert func(sheet cueSheet) (cueSheet, error)
+	// resolution of start times in microseconds
+	resolution Timestamp
+	// the format keeps sheet header, performers, ISRCs, flags and indexes
+	full bool
+}
+
+var selftestFormats = []selftestFormat{
+	{"cue", selftestCue, 1, true},
+	{"labels", selftestLabels, 1, false},
+	{"json", selftestJSON, 1, true},
+	{"vorbischap", selftestVorbis, 1000, false},
+}
+
+func selftestCue(sheet cueSheet) (cueSheet, error) {
+	var b bytes.Buffer
+
+	writeCueSheet(&b, sheet, "selftest.wav", "WAVE")
+	got := parseCueSheet(newNamedReader(b.Bytes(), "selftest.cue"), 0)
+	if len(got.warning) > 0 {
+		return got, errors.New(got.warning[0].String())
+	}
+	return got, nil
+}
+
+func selftestLabels(sheet cueSheet) (got cueSheet, err error) {
+	var b bytes.Buffer
+
+	for _, l := range sheet.label {
+		if err = writeLabelLine(&b, l, true); err != nil {
+			return
+		}
+	}
+	got.label, err = readLabels(&b)
+	return
+}
+
+// selftestJSON converts written cue to JSON like convert.
+func selftestJSON(sheet cueSheet) (got cueSheet, err error) {
+	var (
+		b  bytes.Buffer
+		js jsonCueSheet
+	)
+
+	writeCueSheet(&b, sheet, "selftest.wav", "WAVE")
+	data, err := cueJSON(newNamedReader(b.Bytes(), "selftest.cue"), 0, &trackFilter{})
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &js); err != nil {
+		return
+	}
+	got = cueSheet{catalog: js.Catalog, title: js.Title, performer: js.Performer}
+	for i, t := range js.Tracks {
+		l := cueLabel{num: i + 1, start: Timestamp(t.Start), index00: -1, title: t.Title,
+			performer: t.Performer, isrc: t.ISRC, flags: t.Flags}
+		if t.Index00 != nil {
+			l.index00 = Timestamp(*t.Index00)
+		}
+		for _, s := range t.Subindex {
+			l.subindex = append(l.subindex, Timestamp(s))
+		}
+		got.label = append(got.label, l)
+	}
+	return
+}
+
+// selftestVorbis writes chapters of written cue like vorbischap.
+func selftestVorbis(sheet cueSheet) (got cueSheet, err error) {
+	var cue, b bytes.Buffer
+
+	writeCueSheet(&cue, sheet, "selftest.wav", "WAVE")
+	writeVorbisChapters(&b, newNamedReader(cue.Bytes(), "selftest.cue"), 0,
+		(&trackFilter{}).matcher())
+	tag, err := readVorbisComments(&b)
+	if err != nil {
+		return
+	}
+	chap, err := parseVorbisChapters(tag)
+	for i, c := range chap {
+		got.label = append(got.label, cueLabel{num: i + 1, start: c.start, index00: -1,
+			title: c.title})
+	}
+	return
+}
+
+// selftestSheet makes cue sheet of tracks with titles in several scripts,
+// frame aligned and sub-frame start times, pregaps, subindexes, performers,
+// ISRCs and flags.
+func selftestSheet(tracks int, seed uint64) (sheet cueSheet) {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	sheet = cueSheet{catalog: "0123456789012", title: "Selftest – Ünïcödé Ålbum",
+		performer: "Ensemble Æøå & Ωmega"}
+	var t Timestamp
+	for i := range tracks {
+		l := cueLabel{num: i + 1, start: t, index00: -1,
+			title: selftestTitles[i%len(selftestTitles)]}
+		if i%3 == 1 {
+			l.performer = selftestTitles[(i+5)%len(selftestTitles)]
+		}
+		if i%4 == 2 {
+			l.isrc = fmt.Sprintf("XXSLF%02d%05d", seed%100, i+1)
+		}
+		if i%5 == 3 {
+			l.flags = []string{"DCP", "PRE"}
+		}
+		if i > 0 && i%2 == 0 {
+			l.index00 = t.AlignToFrame() - 2*uSecInSecond
+		}
+		if i%4 == 1 {
+			s := (t + 30*uSecInSecond).AlignToFrame()
+			l.subindex = []Timestamp{s, s + 30*uSecInSecond}
+		}
+		sheet.label = append(sheet.label, l)
+		// tracks of 90 to 600 seconds, every other one not frame aligned
+		t += Timestamp(rnd.Int64N(510*uSecInSecond) + 90*uSecInSecond).AlignToFrame()
+		if i%2 == 0 {
+			t += 1 + Timestamp(rnd.Int64N(uSecInSecond/cdFramesPerSecond-1))
+		}
+	}
+	return
+}
+
+// selftestLosses compares tracks read back from format with the tracks
+// written.
+func selftestLosses(f selftestFormat, want, got cueSheet) (loss []string) {
+	lost := func(format string, a ...any) {
+		loss = append(loss, fmt.Sprintf(format, a...))
+	}
+	diff := func(track int, field string, w, g any) {
+		if fmt.Sprint(w) != fmt.Sprint(g) {
+			lost("track %d %v %q became %q", track, field, fmt.Sprint(w), fmt.Sprint(g))
+		}
+	}
+	if f.full {
+		for _, h := range []struct{ field, w, g string }{
+			{"catalog", want.catalog, got.catalog},
+			{"title", want.title, got.title},
+			{"performer", want.performer, got.performer},
+		} {
+			if h.w != h.g {
+				lost("sheet %v %q became %q", h.field, h.w, h.g)
+			}
+		}
+	}
+	if len(got.label) != len(want.label) {
+		lost("%d tracks became %d", len(want.label), len(got.label))
+	}
+	for i := range min(len(want.label), len(got.label)) {
+		w, g := want.label[i], got.label[i]
+		diff(i+1, "title", w.title, g.title)
+		if start := w.start / f.resolution * f.resolution; start != g.start {
+			lost("track %d start %v became %v", i+1, start.String(),
+				g.start.String())
+		}
+		if !f.full {
+			continue
+		}
+		diff(i+1, "performer", w.performer, g.performer)
+		diff(i+1, "ISRC", w.isrc, g.isrc)
+		diff(i+1, "flags", strings.Join(w.flags, " "), strings.Join(g.flags, " "))
+		if w.index00 != g.index00 {
+			lost("track %d INDEX 00 %v became %v", i+1, w.index00.String(),
+				g.index00.String())
+		}
+		if !slices.Equal(w.subindex, g.subindex) {
+			lost("track %d has %d subindexes, %d read back", i+1, len(w.subindex),
+				len(g.subindex))
+		}
+	}
+	return
+}
+
+// selftestRun converts sheet to format and back, returning cue-maker errors
+// raised by parsers as errors.
+func selftestRun(f selftestFormat, sheet cueSheet) (got cueSheet, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(string)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(msg)
+		}
+	}()
+	return f.convert(sheet)
+}
+
+// doCmdSelftest round-trips generated tracks through cue, labels, JSON and
+// Vorbis chapters and reports what is lost, like titles changed by text
+// encoding or times by -decimal option.
+func doCmdSelftest(arg []string) {
+	var (
+		tracks int
+		seed   uint64
+		report []jsonSelftest
+		failed []string
+	)
+
+	fl := newFlagSet()
+	fl.IntVar(&tracks, "tracks", 24, "number of generated tracks")
+	fl.Uint64Var(&seed, "seed", 1, "seed of generated track times")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	if tracks < 1 || tracks > maxCueTracks {
+		panic(fmt.Sprintf("Tracks number must be in range 1-%d", maxCueTracks))
+	}
+
+	sheet := selftestSheet(tracks, seed)
+	for _, f := range selftestFormats {
+		r := jsonSelftest{Format: f.name, Tracks: tracks}
+		got, err := selftestRun(f, sheet)
+		if err != nil {
+			r.Losses = []string{err.Error()}
+		} else {
+			r.Losses = selftestLosses(f, sheet, got)
+		}
+		if len(r.Losses) > 0 {
+			failed = append(failed, f.name)
+		}
+		report = append(report, r)
+	}
+
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, report)
+	} else {
+		for _, r := range report {
+			status := "ok"
+			if len(r.Losses) > 0 {
+				status = fmt.Sprintf("%d lost", len(r.Losses))
+			}
+			_, err := fmt.Fprintf(os.Stdout, "cue -> %-10v -> cue  %d tracks  %v\n",
+				r.Format, r.Tracks, status)
+			panicIfError(err)
+			for _, l := range r.Losses {
+				_, err = fmt.Fprintf(os.Stdout, "    %v\n", l)
+				panicIfError(err)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		panic("Lossy conversions: " + strings.Join(failed, ", "))
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vinylSides describes vinyl digitization sides: tracks and optional
+// recorded length of every side.
+type vinylSides struct {
+	first  int // index of the first side letter, 0 for A
+	count  []int
+	length []Timestamp // zero if not given
+}
+
+// parseSides parses -sides track counts, -side-len side lengths and -side
+// first side letter.
+func parseSides(spec, lens, first string) (s vinylSides, err error) {
+	if first != "" {
+		if len(first) != 1 || first[0] < 'A' || first[0] > 'Z' {
+			return s, fmt.Errorf("wrong side '%v'", first)
+		}
+		s.first = int(first[0] - 'A')
+	}
+	for _, c := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(c)
+		if err != nil || n <= 0 {
+			return s, fmt.Errorf("wrong tracks per side '%v'", c)
+		}
+		s.count = append(s.count, n)
+	}
+	s.length = make([]Timestamp, len(s.count))
+	if lens == "" {
+		return
+	}
+	l := strings.Split(lens, ",")
+	if len(l) > len(s.count) {
+		return s, fmt.Errorf("%d side lengths for %d sides", len(l), len(s.count))
+	}
+	for i, t := range l {
+		if t == "" {
+			continue
+		}
+		if s.length[i], err = parseSideTime(t); err != nil || s.length[i] <= 0 {
+			return s, fmt.Errorf("wrong side length '%v'", t)
+		}
+	}
+	return
+}
+
+// parseSideTime parses seconds or m:ss.f time.
+func parseSideTime(t string) (Timestamp, error) {
+	mins, sec, ok := strings.Cut(t, ":")
+	if !ok {
+		return parseTimeSec(t)
+	}
+	m, err := strconv.ParseUint(mins, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	s, err := parseTimeSec(sec)
+	return Timestamp(m)*60*uSecInSecond + s, err
+}
+
+func (s *vinylSides) name(side int) string {
+	return sideName(s.first + side)
+}
+
+// tracks returns the first track index of every side.
+func (s *vinylSides) firstTracks(nTrack int) (first []int, err error) {
+	total := 0
+	for _, c := range s.count {
+		first = append(first, total)
+		total += c
+	}
+	if total != nTrack {
+		return nil, fmt.Errorf("sides have %d tracks, but %d tracks given", total, nTrack)
+	}
+	return
+}
+
+// applyLengths moves sides to start after the recorded length of previous
+// sides, so run-out silence is kept in offsets.
+func (s *vinylSides) applyLengths(start []Timestamp, first []int) error {
+	for k := 1; k < len(first); k++ {
+		if s.length[k-1] == 0 {
+			continue
+		}
+		sideEnd := start[first[k-1]] + s.length[k-1]
+		delta := sideEnd - start[first[k]]
+		if delta < 0 {
+			return fmt.Errorf("side %v length %v is shorter than its tracks",
+				s.name(k-1), formatTimeSec(s.length[k-1]))
+		}
+		for i := first[k]; i < len(start); i++ {
+			start[i] += delta
+		}
+	}
+	return nil
+}
+
+// parts returns cue files of sides starting at tracks first.
+func (s *vinylSides) parts(cueFilePath string, opt *cueOptions, first []int,
+	start []Timestamp) (part []cuePart) {
+	for k, lo := range first {
+		hi := len(start)
+		if k < len(first)-1 {
+			hi = first[k+1]
+		}
+		part = append(part, cuePart{path: cuePartPath(cueFilePath, s.name(k)),
+			title: fmt.Sprintf("%v (Side %v)", opt.title, s.name(k)), lo: lo, hi: hi,
+			num: opt.numStart, shift: start[lo]})
+	}
+	return
+}
+
+// writeSideCues writes a cue file per side part, e.g. "album-A.cue", with
+// track times from the side start.
+func writeSideCues(opt *cueOptions, sides *vinylSides, part []cuePart, title []string,
+	start []Timestamp) {
+	for k, p := range part {
+		lo, hi := p.lo, p.hi
+		sideStart := make([]Timestamp, 0, hi-lo)
+		for _, t := range start[lo:hi] {
+			sideStart = append(sideStart, t-p.shift)
+		}
+		sideOpt := *opt
+		sideOpt.title = p.title
+		sideOpt.sideMark = map[int]string{opt.numStart: sides.name(k)}
+		if opt.trackPerformer != nil {
+			sideOpt.trackPerformer = opt.trackPerformer[lo:hi]
+		}
+		if opt.subindex != nil {
+			sideOpt.subindex = nil
+			for _, sub := range opt.subindex[lo:hi] {
+				sideOpt.subindex = append(sideOpt.subindex, shiftTimes(sub, -p.shift))
+			}
+		}
+		if opt.trkFlags != nil {
+			// side cue tracks are numbered from numStart again
+			sideOpt.trkFlags = map[int][]string{0: opt.trkFlags[0]}
+			for i := range hi - lo {
+				if f, ok := opt.trkFlags[opt.numStart+lo+i]; ok {
+					sideOpt.trkFlags[opt.numStart+i] = f
+				}
+			}
+		}
+		if opt.isrcBase != "" {
+			isrc, err := makeISRCs(opt.isrcBase, lo+1)
+			if err != nil {
+				panic("Wrong ISRC base: " + err.Error())
+			}
+			sideOpt.isrcBase = isrc[lo]
+		}
+
+		w := createOutput(p.path)
+		writeCue(w, &sideOpt, title[lo:hi], sideStart, 0)
+		panicIfError(w.Close())
+	}
+}
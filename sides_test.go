@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSideCuesFlagsISRC(t *testing.T) {
+	defer func() { pendingOutputs = nil }()
+	sides, err := parseSides("2,2", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags, err := parseTrackFlags([]string{"DCP", "3:PRE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := cueOptions{title: "Album", fileName: "album.wav", numStart: 1,
+		isrcBase: "USRC17607839", trkFlags: flags}
+	start := []Timestamp{0, 60 * uSecInSecond, 120 * uSecInSecond, 180 * uSecInSecond}
+	cuePath := filepath.Join(t.TempDir(), "album.cue")
+	part := sides.parts(cuePath, &opt, []int{0, 2}, start)
+	writeSideCues(&opt, &sides, part, []string{"One", "Two", "Three", "Four"}, start)
+	commitOutputs()
+
+	want := map[string][]string{
+		"album-A.cue": {"ISRC USRC17607839\n    FLAGS DCP\n", "ISRC USRC17607840\n    FLAGS DCP\n"},
+		"album-B.cue": {"ISRC USRC17607841\n    FLAGS DCP PRE\n", "ISRC USRC17607842\n    FLAGS DCP\n"},
+	}
+	for name, tracks := range want {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(cuePath), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cue := string(data)
+		for i, s := range tracks {
+			if !strings.Contains(cue, s) {
+				t.Errorf("%v track %d: no %q in\n%v", name, i+1, s, cue)
+			}
+		}
+		if n := strings.Count(cue, "FLAGS"); n != len(tracks) {
+			t.Errorf("%v: %d FLAGS, want %d", name, n, len(tracks))
+		}
+	}
+}
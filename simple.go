@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	simpleTimeAuto = "auto" // h:mm:ss or mm:ss with colons, otherwise seconds
+	simpleTimeHMS  = "hms"
+	simpleTimeCue  = "cue"
+	simpleTimeSec  = "sec"
+)
+
+// simpleTitleTrim is punctuation between time and title, like in
+// "01:02 - Title" or "Title | 01:02".
+const simpleTitleTrim = " \t-–—|:;,."
+
+var simpleTimeRe = regexp.MustCompile(`^[\[(]?([0-9]+(?:[:.,][0-9]+)*)[\])]?$`)
+
+// simpleIndex is how simple index file lines are split to start time and
+// title.
+type simpleIndex struct {
+	timeFormat string
+	col        int    // time column starting at 1, 0 for first or last
+	sep        string // column separator, "" for tab or blanks
+}
+
+func (s *simpleIndex) parseTime(t string) (Timestamp, error) {
+	m := simpleTimeRe.FindStringSubmatch(t)
+	if m == nil {
+		return 0, fmt.Errorf("wrong time '%v'", t)
+	}
+	t = m[1]
+	switch {
+	case s.timeFormat == simpleTimeCue:
+		return parseCueTime(t)
+	case s.timeFormat == simpleTimeSec:
+		return parseTimeSec(t)
+	case s.timeFormat == simpleTimeHMS || strings.Contains(t, ":"):
+		return parseVorbisTime(t)
+	}
+	return parseTimeSec(t)
+}
+
+// split returns start time and title of line.
+func (s *simpleIndex) split(line string) (start Timestamp, title string, err error) {
+	var (
+		field []string
+		t     Timestamp
+	)
+
+	switch {
+	case s.sep != "":
+		field = strings.Split(line, s.sep)
+	case strings.Contains(line, "\t"):
+		field = strings.Split(line, "\t")
+	default:
+		field = strings.Fields(line)
+	}
+	for i := range field {
+		field[i] = strings.TrimSpace(field[i])
+	}
+	col := []int{s.col - 1}
+	if s.col == 0 {
+		col = []int{0, len(field) - 1}
+		// in "1 Title 03:21" the time is 03:21
+		if !strings.Contains(field[0], ":") && strings.Contains(field[len(field)-1], ":") {
+			col[0], col[1] = col[1], col[0]
+		}
+	}
+	for _, c := range col {
+		if c >= len(field) {
+			continue
+		}
+		if t, err = s.parseTime(field[c]); err != nil {
+			continue
+		}
+		sep := cmp.Or(s.sep, " ")
+		rest := append(field[:c:c], field[c+1:]...)
+		title = strings.Join(rest, sep)
+		// only punctuation next to the time is separator
+		if c == 0 {
+			title = strings.TrimLeft(title, simpleTitleTrim)
+		} else {
+			title = strings.TrimRight(title, simpleTitleTrim)
+		}
+		title = strings.TrimSpace(title)
+		return t, title, nil
+	}
+	if s.col == 0 {
+		return 0, "", fmt.Errorf("no time in the first or the last column")
+	}
+	return 0, "", fmt.Errorf("no time in column %d", s.col)
+}
+
+// read reads lines of start time and title. Empty lines and #
+// comments are skipped, and lines without time are warned about.
+func (s *simpleIndex) read(r io.Reader) (label []cueLabel, err error) {
+	name := inputName(r)
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scan.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		l := cueLabel{num: len(label) + 1, index00: -1}
+		if l.start, l.title, err = s.split(line); err != nil {
+			logFileWarning(name, n, "skipped: "+err.Error())
+			continue
+		}
+		if len(label) > 0 && l.start <= label[len(label)-1].start {
+			return nil, fmt.Errorf("line %d: time %v is not after previous one", n,
+				l.start.String())
+		}
+		label = append(label, l)
+	}
+	return label, scan.Err()
+}
+
+// doCmdSimpleToCue makes cue from simple index file with start time and
+// title per line, like "03:21 Title" or "201.5<TAB>Title".
+func doCmdSimpleToCue(arg []string) {
+	var (
+		indexFilePath string
+		cueFilePath   string
+		fileName      string
+		denum         bool
+		index         simpleIndex
+		sheet         cueSheet
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&indexFilePath, "i", "", "input index file path")
+	fl.StringVar(&cueFilePath, "o", "", "output cue file path")
+	fl.StringVar(&fileName, "file", "", "cue audio file name")
+	fl.StringVar(&sheet.title, "title", "", "album title")
+	fl.StringVar(&sheet.performer, "performer", "", "album performer")
+	fl.StringVar(&index.timeFormat, "time", simpleTimeAuto,
+		"time format: auto, hms (h:mm:ss), cue (mm:ss:ff) or sec")
+	fl.IntVar(&index.col, "col", 0, "time column starting at 1, 0 for the first or the last")
+	fl.StringVar(&index.sep, "sep", "", "column separator, \\t for tab, default is tab or blanks")
+	fl.BoolVar(&denum, "denum", false, "remove track numbers from titles")
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+	switch index.timeFormat {
+	case simpleTimeAuto, simpleTimeHMS, simpleTimeCue, simpleTimeSec:
+	default:
+		panic("Wrong time format: " + index.timeFormat)
+	}
+	if index.col < 0 {
+		panic(fmt.Sprintf("Wrong time column: %d", index.col))
+	}
+	if index.sep == `\t` {
+		index.sep = "\t"
+	}
+	if fileName == "" {
+		if isStdio(indexFilePath) {
+			panic("Option -file is required for index file from standard input")
+		}
+		fileName = fileTitle(indexFilePath) + ".wav"
+	}
+
+	in := openInput(indexFilePath)
+	sheet.label, err = index.read(in)
+	in.Close()
+	if err != nil {
+		panic("Wrong index file " + inputName(in) + ": " + err.Error())
+	}
+	if len(sheet.label) == 0 {
+		panic("No tracks in " + inputName(in))
+	}
+	if len(sheet.label) > maxCueTracks {
+		panic(fmt.Sprintf("Too many tracks: %d", len(sheet.label)))
+	}
+	for i, l := range sheet.label {
+		if denum {
+			sheet.label[i].title = denumRe.ReplaceAllString(l.title, "$1")
+		}
+	}
+
+	out := createOutput(cueFilePath)
+	writeCueSheet(out, sheet, fileName, "WAVE")
+	panicIfError(out.Close())
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLoudness = -23 // EBU R128 target, LUFS
+	loudnormTP      = -1
+	loudnormLRA     = 7
+)
+
+// loudnormStats is ffmpeg loudnorm filter first pass measurement.
+type loudnormStats struct {
+	InputI      string `json:"input_i"`
+	InputTP     string `json:"input_tp"`
+	InputLRA    string `json:"input_lra"`
+	InputThresh string `json:"input_thresh"`
+	Offset      string `json:"target_offset"`
+}
+
+// trackEncoder is ffmpeg audio encoder options of split tracks.
+type trackEncoder struct {
+	codec       string
+	compression int // -1 for the codec default
+	bitrate     string
+}
+
+// codecExt is output file extension of ffmpeg audio encoders.
+var codecExt = map[string]string{
+	"flac": ".flac", "alac": ".m4a", "wavpack": ".wv",
+	"libopus": ".opus", "opus": ".opus", "libvorbis": ".ogg", "vorbis": ".ogg",
+	"libmp3lame": ".mp3", "aac": ".m4a", "libfdk_aac": ".m4a",
+	"pcm_s16le": ".wav", "pcm_s24le": ".wav",
+}
+
+func (e *trackEncoder) args() (args []string) {
+	if e.codec != "" {
+		args = append(args, "-c:a", e.codec)
+	}
+	if e.compression >= 0 {
+		args = append(args, "-compression_level", strconv.Itoa(e.compression))
+	}
+	if e.bitrate != "" {
+		args = append(args, "-b:a", e.bitrate)
+	}
+	return
+}
+
+// override returns encoder with options set in o replaced.
+func (e trackEncoder) override(o trackEncoder) trackEncoder {
+	e.codec = cmp.Or(o.codec, e.codec)
+	if o.compression >= 0 {
+		e.compression = o.compression
+	}
+	e.bitrate = cmp.Or(o.bitrate, e.bitrate)
+	return e
+}
+
+// trackMeta is track title, artist and encoder options from split -meta CSV.
+// Empty cells keep cue and command line values.
+type trackMeta struct {
+	title   string
+	artist  string
+	encoder trackEncoder
+}
+
+// trackMetaColumns are columns of split -meta CSV header.
+var trackMetaColumns = []string{"track", "title", "artist", "codec", "compression", "bitrate"}
+
+// readTrackMeta reads CSV with header row of trackMetaColumns, track column
+// required, and returns track metadata by track number.
+func readTrackMeta(r io.Reader) (meta map[int]trackMeta, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	head, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	col := make(map[string]int)
+	for i, h := range head {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if !slices.Contains(trackMetaColumns, h) {
+			return nil, fmt.Errorf("unknown column '%v', expected %v", h,
+				strings.Join(trackMetaColumns, ", "))
+		}
+		col[h] = i
+	}
+	if _, ok := col["track"]; !ok {
+		return nil, errors.New("no track column")
+	}
+	meta = make(map[int]trackMeta)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return meta, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		cell := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		num, err := strconv.Atoi(cell("track"))
+		if err != nil || num < 1 || num > maxCueTracks {
+			return nil, fmt.Errorf("line %d: wrong track '%v'", line, cell("track"))
+		}
+		if _, ok := meta[num]; ok {
+			return nil, fmt.Errorf("line %d: track %d repeated", line, num)
+		}
+		m := trackMeta{title: cell("title"), artist: cell("artist"),
+			encoder: trackEncoder{codec: cell("codec"), compression: -1, bitrate: cell("bitrate")}}
+		if c := cell("compression"); c != "" {
+			if m.encoder.compression, err = strconv.Atoi(c); err != nil || m.encoder.compression < 0 {
+				return nil, fmt.Errorf("line %d: wrong compression '%v'", line, c)
+			}
+		}
+		meta[num] = m
+	}
+}
+
+// doCmdSplit cuts cue audio file to track files with ffmpeg, or WAV file to
+// WAV tracks without it in native mode.
+func doCmdSplit(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		outDir       string
+		ext          string
+		cover        string
+		loudnorm     bool
+		lufs         float64
+		jobs         int
+		metaFilePath string
+		encoder      trackEncoder
+		meta         map[int]trackMeta
+		filter       trackFilter
+		cueRd        io.ReadCloser
+		end          Timestamp
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&outDir, "o", ".", "output directory or file name template like "+
+		"'{{.Album}}/{{.Num}} - {{.Title}}.flac'")
+	fl.StringVar(&ext, "ext", "", "output file extension, by -codec or same as input by default")
+	fl.StringVar(&encoder.codec, "codec", "", "ffmpeg audio encoder like flac or libopus")
+	fl.IntVar(&encoder.compression, "compression", -1, "encoder compression level, like 8 for flac")
+	fl.StringVar(&encoder.bitrate, "b:a", "", "encoder bitrate like 160k")
+	fl.StringVar(&metaFilePath, "meta", "",
+		"CSV of track,title,artist,codec,compression,bitrate overriding tracks")
+	fl.StringVar(&cover, "cover", "", "cover art image embedded in tracks, default is cue REM COVER")
+	fl.BoolVar(&loudnorm, "loudnorm", false, "normalize track loudness with EBU R128 two-pass loudnorm")
+	fl.Float64Var(&lufs, "lufs", defaultLoudness, "loudnorm target integrated loudness")
+	fl.IntVar(&jobs, "jobs", 1, "number of ffmpeg processes run at once")
+	addProbeFlags(fl)
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 1 {
+		panic("Expected one audio file")
+	}
+	audioFilePath := fl.Arg(0)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	native := useNativeSplit()
+	if native {
+		switch {
+		case !strings.EqualFold(filepath.Ext(audioFilePath), ".wav"):
+			panic("Split without ffmpeg cuts only WAV files")
+		case loudnorm || cover != "" || encoder != (trackEncoder{compression: -1}):
+			panic("Options -loudnorm, -cover, -codec, -compression and -b:a require ffmpeg")
+		}
+	}
+	if encoder.compression < -1 {
+		panic(fmt.Sprintf("Wrong compression level: %d", encoder.compression))
+	}
+	if metaFilePath != "" {
+		f := openInput(metaFilePath)
+		meta, err = readTrackMeta(f)
+		f.Close()
+		if err != nil {
+			panic("Wrong track metadata " + inputName(f) + ": " + err.Error())
+		}
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	sheet := parseCue(cueRd, cueAudioFile)
+	if cover == "" && sheet.cover != "" {
+		cover = sheet.cover
+		if !filepath.IsAbs(cover) && !isStdio(cueFilePath) {
+			cover = filepath.Join(filepath.Dir(cueFilePath), cover)
+		}
+		if native {
+			logWarningMessage("cover art is not embedded in tracks split without ffmpeg")
+			cover = ""
+		}
+	}
+	end, err = getMediaDuration(audioFilePath)
+	panicIfError(err)
+	track := cueTrackLengths(sheet.label, end)
+	label, track := filter.apply(sheet.label), filter.applyLengths(sheet.label, track)
+	enc := make([]trackEncoder, len(label))
+	trackExt := make([]string, len(label))
+	for i, l := range label {
+		enc[i] = encoder
+		if m, ok := meta[l.num]; ok {
+			label[i].title = cmp.Or(m.title, l.title)
+			label[i].performer = cmp.Or(m.artist, l.performer)
+			enc[i] = encoder.override(m.encoder)
+		}
+		trackExt[i] = cmp.Or(ext, codecExt[enc[i].codec], filepath.Ext(audioFilePath))
+		if native && (enc[i] != encoder || !strings.EqualFold(trackExt[i], ".wav")) {
+			panic(fmt.Sprintf("Track %d: split without ffmpeg writes only WAV tracks", l.num))
+		}
+	}
+	path := make([]string, len(label))
+	if isNameTemplate(outDir) {
+		tmpl, err := parseNameTemplate(outDir)
+		if err != nil {
+			panic("Wrong output name template: " + err.Error())
+		}
+		for i, l := range label {
+			name := outputName{Album: sheet.title, Performer: cmp.Or(l.performer, sheet.performer),
+				Disc: strconv.Itoa(cueAudioFile + 1), File: fileTitle(sheet.file),
+				Num: fmt.Sprintf("%02d", l.num), Title: l.title}
+			if path[i], err = name.path(tmpl); err != nil {
+				panic("Wrong output name template: " + err.Error())
+			}
+			if filepath.Ext(path[i]) == "" {
+				path[i] += trackExt[i]
+			}
+			if j := slices.Index(path[:i], path[i]); j >= 0 {
+				panic(fmt.Sprintf("Tracks %d and %d have the same output file: %v",
+					label[j].num, l.num, path[i]))
+			}
+		}
+	} else {
+		if err = os.MkdirAll(outDir, 0777); err != nil {
+			panic("Cannot create output directory: " + err.Error())
+		}
+		for i, l := range label {
+			path[i] = filepath.Join(outDir, safePathName(fmt.Sprintf("%02d %v%v", l.num,
+				safeFileName(l.title), trackExt[i])))
+		}
+	}
+	for i := range path {
+		path[i] = createToolOutput(path[i])
+	}
+
+	err = runJobs(jobs, len(label), func(i int) (err error) {
+		l := label[i]
+		if path[i] == "" {
+			return
+		}
+		if native {
+			tag := map[string]string{"title": l.title,
+				"track": fmt.Sprintf("%d/%d", l.num, len(sheet.label))}
+			if sheet.title != "" {
+				tag["album"] = sheet.title
+			}
+			if p := cmp.Or(l.performer, sheet.performer); p != "" {
+				tag["artist"] = p
+			}
+			if err = splitWav(audioFilePath, path[i], l.start, track[i].duration, tag); err != nil {
+				return fmt.Errorf("track %d: %w", l.num, err)
+			}
+			return
+		}
+		args := []string{
+			"-hide_banner",
+			"-v", "error",
+			"-y",
+			// input options, output -ss and -t would cut the cover stream too
+			"-ss", l.start.String(),
+			"-t", formatTimeSec(track[i].duration),
+			"-i", audioFilePath,
+		}
+		if cover != "" {
+			args = append(args, "-i", cover)
+		}
+		args = append(args,
+			"-map", "0:a",
+			"-map_metadata", "-1",
+			"-metadata", "title="+l.title,
+			"-metadata", fmt.Sprintf("track=%d/%d", l.num, len(sheet.label)))
+		if cover != "" {
+			args = append(args,
+				"-map", "1:v",
+				"-c:v", "copy",
+				"-disposition:v:0", "attached_pic")
+		}
+		if sheet.title != "" {
+			args = append(args, "-metadata", "album="+sheet.title)
+		}
+		if p := cmp.Or(l.performer, sheet.performer); p != "" {
+			args = append(args, "-metadata", "artist="+p)
+		}
+		if loudnorm {
+			af, err := loudnormFilter(audioFilePath, l.start, track[i].duration, lufs)
+			if err != nil {
+				return fmt.Errorf("track %d: %w", l.num, err)
+			}
+			args = append(args, "-af", af)
+		}
+		args = append(args, enc[i].args()...)
+		if deterministic {
+			args = append(args, "-fflags", "+bitexact", "-flags:a", "+bitexact")
+		}
+		if _, err = runCommand("ffmpeg", append(args, path[i])...); err != nil {
+			return fmt.Errorf("track %d: ffmpeg: %w", l.num, err)
+		}
+		return
+	})
+	if err != nil {
+		panic("Cannot split tracks: " + err.Error())
+	}
+}
+
+// loudnormFilter measures track loudness with the first loudnorm pass and
+// returns the second pass filter.
+func loudnormFilter(filePath string, start, dur Timestamp, lufs float64) (string, error) {
+	var (
+		out   []byte
+		stats loudnormStats
+		err   error
+	)
+
+	target := fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v",
+		strconv.FormatFloat(lufs, 'f', -1, 64), loudnormTP, loudnormLRA)
+	_, out, err = runCommandInput(nil, "ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		// seeking as the second pass does, so both see the same samples
+		"-ss", formatTimeSec(start),
+		"-t", formatTimeSec(dur),
+		"-i", filePath,
+		"-map", "0:a",
+		"-af", target+":print_format=json",
+		"-f", "null", "-")
+	if err != nil {
+		return "", fmt.Errorf("loudnorm: ffmpeg: %w", err)
+	}
+	i, j := strings.LastIndexByte(string(out), '{'), strings.LastIndexByte(string(out), '}')
+	if i < 0 || j < i {
+		return "", errors.New("loudnorm: no measurement in ffmpeg output")
+	}
+	if err = json.Unmarshal(out[i:j+1], &stats); err != nil {
+		return "", fmt.Errorf("loudnorm: %w", err)
+	}
+	return fmt.Sprintf("%v:measured_I=%v:measured_TP=%v:measured_LRA=%v:"+
+		"measured_thresh=%v:offset=%v:linear=true", target, stats.InputI,
+		stats.InputTP, stats.InputLRA, stats.InputThresh, stats.Offset), nil
+}
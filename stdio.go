@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// stdioPath as input or output file path means stdin or stdout.
+	stdioPath = "-"
+	// gzipExt is extension of compressed input and output files.
+	gzipExt = ".gz"
+	// stdinName is stdin name in messages.
+	stdinName = "<stdin>"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func isStdio(path string) bool {
+	return path == "" || path == stdioPath
+}
+
+// gzipReader closes both gzip reader and file.
+type gzipReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (r gzipReader) Close() error {
+	r.Reader.Close()
+	return r.f.Close()
+}
+
+func (r gzipReader) Name() string { return r.f.Name() }
+
+// namedReader is input read to memory, keeping its file name for messages.
+type namedReader struct {
+	*bytes.Reader
+	name string
+}
+
+func newNamedReader(data []byte, path string) namedReader {
+	if isStdio(path) {
+		path = stdinName
+	}
+	return namedReader{bytes.NewReader(data), path}
+}
+
+func (r namedReader) Name() string { return r.name }
+
+// inputName returns file name of input for messages.
+func inputName(r io.Reader) string {
+	if n, ok := r.(interface{ Name() string }); ok && n.Name() != os.Stdin.Name() {
+		return n.Name()
+	}
+	return stdinName
+}
+
+// gzipWriter flushes gzip stream and closes file.
+type gzipWriter struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (w gzipWriter) Close() error {
+	err := w.Writer.Close()
+	if e := w.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// openInput opens input file, or stdin for empty path or "-". Files with .gz
+// extension are decompressed, and "archive.zip!name" is read from zip archive.
+func openInput(path string) io.ReadCloser {
+	if isStdio(path) {
+		return io.NopCloser(os.Stdin)
+	}
+	if _, _, ok := splitZipPath(path); ok {
+		r, err := openZipMember(path)
+		if err != nil {
+			panic("Cannot open input file: " + err.Error())
+		}
+		return r
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		panic("Cannot open input file: " + err.Error())
+	}
+	if !strings.HasSuffix(path, gzipExt) {
+		return f
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		panic("Cannot open input file: " + path + ": " + err.Error())
+	}
+	return gzipReader{zr, f}
+}
+
+// Existing output files are overwritten with the global -f option and kept
+// with -n. Otherwise overwriting is confirmed on terminal or is an error.
+var forceOverwrite, noOverwrite bool
+
+// overwritePrompt reads answers to overwrite questions from terminal.
+var overwritePrompt struct {
+	sync.Mutex
+	rd *bufio.Reader
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// mayWrite reports whether output file may be written: it does not exist,
+// overwriting is forced or confirmed. Kept files are warned about.
+func mayWrite(path string) bool {
+	if forceOverwrite {
+		return true
+	}
+	if !outputExists(path) {
+		return true
+	}
+	if noOverwrite {
+		logWarningMessage("kept existing file '" + path + "'")
+		return false
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		panic("Output file exists, use -f to overwrite or -n to keep it: " + path)
+	}
+
+	overwritePrompt.Lock()
+	defer overwritePrompt.Unlock()
+	if overwritePrompt.rd == nil {
+		overwritePrompt.rd = bufio.NewReader(os.Stdin)
+	}
+	fmt.Fprintf(os.Stderr, "Overwrite '%v'? [y/N] ", path)
+	answer, _ := overwritePrompt.rd.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	logWarningMessage("kept existing file '" + path + "'")
+	return false
+}
+
+// outputExists reports whether output file or zip archive member exists.
+func outputExists(path string) bool {
+	if _, _, ok := splitZipPath(path); ok {
+		return zipMemberExists(path)
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// pendingOutput is temporary output file replacing path on commit.
+type pendingOutput struct {
+	f *os.File
+	// f or compressing writer closed on commit, nil if f is closed
+	w    io.WriteCloser
+	path string
+}
+
+// pendingOutputs are outputs of the running command.
+var pendingOutputs []pendingOutput
+
+// commitActions run after outputs of the running command are committed, like
+// catalog records of the outputs.
+var commitActions []func() error
+
+// afterCommit runs f once outputs replace existing files, so nothing is
+// recorded about outputs of a failed command.
+func afterCommit(f func() error) {
+	commitActions = append(commitActions, f)
+}
+
+// commitOutputs replaces output files with written temporary files, keeping
+// mode of existing files. Zip archive members are written to temporary
+// archives first, so no output is replaced unless all are written.
+func commitOutputs() {
+	var (
+		err     error
+		zipped  []pendingOutput
+		replace []pendingOutput
+	)
+
+	out, actions := pendingOutputs, commitActions
+	commitActions = nil
+	for _, p := range out {
+		if p.w != nil {
+			if e := p.w.Close(); err == nil {
+				err = e
+			}
+		}
+		if _, _, ok := splitZipPath(p.path); ok {
+			zipped = append(zipped, p)
+		} else {
+			replace = append(replace, p)
+		}
+	}
+	if err == nil {
+		var archives []pendingOutput
+		archives, err = writeZipArchives(zipped)
+		pendingOutputs = append(pendingOutputs, archives...)
+		replace = append(replace, archives...)
+	}
+	if err != nil {
+		discardOutputs()
+		panic("Cannot write output file: " + err.Error())
+	}
+	for i, p := range replace {
+		// replaced file keeps its mode, as zip archives do
+		if st, e := os.Stat(p.path); e == nil {
+			err = os.Chmod(p.f.Name(), st.Mode().Perm())
+		}
+		if err == nil {
+			err = os.Rename(p.f.Name(), p.path)
+		}
+		if err != nil {
+			pendingOutputs = append(zipped, replace[i:]...)
+			discardOutputs()
+			panic("Cannot write output file: " + err.Error())
+		}
+	}
+	pendingOutputs = zipped
+	discardOutputs()
+	for _, f := range actions {
+		panicIfError(f())
+	}
+}
+
+// discardOutputs removes temporary files of a failed command, keeping
+// existing output files intact.
+func discardOutputs() {
+	for _, p := range pendingOutputs {
+		p.f.Close()
+		os.Remove(p.f.Name())
+	}
+	pendingOutputs, commitActions = nil, nil
+}
+
+// createToolOutput creates output file written by external tool and returns
+// its temporary path, replacing path on commit, or empty path if existing
+// file is kept. The temporary path keeps the extension of path, which tools
+// like ffmpeg choose output format by.
+func createToolOutput(path string) string {
+	if _, _, ok := splitZipPath(path); isStdio(path) || ok || strings.HasSuffix(path, gzipExt) {
+		panic("Output file cannot be stdout, compressed or in zip archive: " + path)
+	}
+	if !mayWrite(path) {
+		return ""
+	}
+	tmp := fmt.Sprintf("%v.%d.tmp%v", path, os.Getpid(), filepath.Ext(path))
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err == nil {
+		pendingOutputs = append(pendingOutputs, pendingOutput{f, nil, path})
+		err = f.Close()
+	}
+	if err != nil {
+		panic("Cannot create output file: " + err.Error())
+	}
+	return tmp
+}
+
+// createOutput creates output file, or returns stdout for empty path or "-".
+// Files with .gz extension are compressed. Output to kept existing file is
+// discarded. Closing the output ends writing; the file is closed on commit,
+// which fails if it cannot be written in full.
+func createOutput(path string) io.WriteCloser {
+	if !isStdio(path) && !mayWrite(path) {
+		return nopWriteCloser{io.Discard}
+	}
+	return replaceOutput(path)
+}
+
+// replaceOutput is createOutput replacing existing file without asking, for
+// files edited in place.
+func replaceOutput(path string) io.WriteCloser {
+	var w io.WriteCloser
+
+	if isStdio(path) {
+		w = nopWriteCloser{os.Stdout}
+	} else {
+		// written to temporary file until the command succeeds
+		var (
+			f   *os.File
+			err error
+		)
+		tmp := fmt.Sprintf("%v.%d.tmp", path, os.Getpid())
+		if archive, _, ok := splitZipPath(path); ok {
+			// members of one archive written apart
+			tmp = fmt.Sprintf("%v.%d.%d.tmp", archive, os.Getpid(), len(pendingOutputs))
+		}
+		f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			panic("Cannot create output file: " + err.Error())
+		}
+		w = f
+		if strings.HasSuffix(path, gzipExt) {
+			w = gzipWriter{gzip.NewWriter(f), f}
+		}
+		pendingOutputs = append(pendingOutputs, pendingOutput{f, w, path})
+		w = nopWriteCloser{w}
+	}
+	if timings {
+		w = timedWriter{w}
+	}
+	return w
+}
+
+// rereadInput is input read more than once. Files are opened again for every
+// pass, so only stdin is kept in memory, and only if it is read again.
+type rereadInput struct {
+	path  string
+	stdin []byte
+	read  bool
+}
+
+func newRereadInput(path string, again bool) *rereadInput {
+	in := &rereadInput{path: path}
+	if isStdio(path) && again {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			panic("Cannot read input file: " + err.Error())
+		}
+		in.stdin = data
+	}
+	return in
+}
+
+// open opens input for the next pass.
+func (in *rereadInput) open() io.ReadCloser {
+	switch {
+	case !isStdio(in.path):
+		return openInput(in.path)
+	case in.stdin != nil:
+		return io.NopCloser(newNamedReader(in.stdin, in.path))
+	case in.read:
+		panic("Cannot read stdin again")
+	}
+	in.read = true
+	return openInput(in.path)
+}
+
+// cueFiles returns FILE names of cue input.
+func (in *rereadInput) cueFiles() []string {
+	r := in.open()
+	defer r.Close()
+	return parseCueFiles(r)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitOutputsKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	defer func() { pendingOutputs = nil }()
+	for _, c := range []struct {
+		name string
+		mode os.FileMode
+	}{
+		{"private.cue", 0600},
+		{"script.txt", 0750},
+	} {
+		path := filepath.Join(dir, c.name)
+		if err := os.WriteFile(path, []byte("old\n"), c.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, c.mode); err != nil {
+			t.Fatal(err)
+		}
+		w := replaceOutput(path)
+		io.WriteString(w, "new\n")
+		w.Close()
+		commitOutputs()
+		st, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != c.mode {
+			t.Errorf("%v mode = %v, want %v", c.name, st.Mode().Perm(), c.mode)
+		}
+		if b, _ := os.ReadFile(path); string(b) != "new\n" {
+			t.Errorf("%v = %q, want new content", c.name, b)
+		}
+	}
+}
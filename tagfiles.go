@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type fileTag struct {
+	name, value string
+}
+
+func doCmdTagFiles(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		printTags     bool
+		jobs          int
+		trackFilePath []string
+		sheet         cueSheet
+		tag           [][]fileTag
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.BoolVar(&printTags, "print", false, "print cuetag-style tags instead of tagging files")
+	fl.IntVar(&jobs, "jobs", 1, "number of tagging processes run at once")
+	parseFlags(fl, arg[1:])
+	trackFilePath = fl.Args()
+	if len(trackFilePath) == 0 {
+		panic("No input track(s)")
+	}
+	if cueFilePath == "" {
+		panic("No input cue file")
+	}
+
+	f := openInput(cueFilePath)
+	sheet = parseCue(f, cueAudioFile)
+	f.Close()
+	if len(sheet.label) != len(trackFilePath) {
+		panic(fmt.Sprintf("Cue has %d tracks, but %d files given",
+			len(sheet.label), len(trackFilePath)))
+	}
+
+	tag = cueTags(sheet)
+	if printTags {
+		writeTags(os.Stdout, trackFilePath, tag)
+		return
+	}
+	err = runJobs(jobs, len(trackFilePath), func(i int) error {
+		return tagFile(trackFilePath[i], tag[i])
+	})
+	panicIfError(err)
+}
+
+// cueTags returns tags per track in the same set as cuetools' cuetag.
+func cueTags(sheet cueSheet) (tag [][]fileTag) {
+	total := strconv.Itoa(len(sheet.label))
+	for i, l := range sheet.label {
+		performer := l.performer
+		if performer == "" {
+			performer = sheet.performer
+		}
+		tag = append(tag, []fileTag{
+			{"TITLE", l.title},
+			{"ARTIST", performer},
+			{"ALBUM", sheet.title},
+			{"TRACKNUMBER", strconv.Itoa(i + 1)},
+			{"TRACKTOTAL", total},
+			{"ISRC", l.isrc},
+		})
+	}
+	return
+}
+
+func writeTags(w io.Writer, trackFilePath []string, tag [][]fileTag) {
+	if outputFormat == outputJSON {
+		js := make([]jsonFileTags, 0, len(trackFilePath))
+		for i, path := range trackFilePath {
+			t := make(map[string]string)
+			for _, ft := range tag[i] {
+				t[ft.name] = ft.value
+			}
+			js = append(js, jsonFileTags{path, t})
+		}
+		writeJSON(w, js)
+		return
+	}
+	for i, path := range trackFilePath {
+		_, err := fmt.Fprintln(w, path)
+		panicIfError(err)
+		for _, t := range tag[i] {
+			_, err = fmt.Fprintf(w, "\t%v=%v\n", t.name, t.value)
+			panicIfError(err)
+		}
+	}
+}
+
+// tagFile writes tags with metaflac for FLAC and mid3v2 for MP3 files.
+func tagFile(filePath string, tag []fileTag) (err error) {
+	var args []string
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".flac":
+		for _, t := range tag {
+			args = append(args, "--remove-tag="+t.name)
+		}
+		for _, t := range tag {
+			if t.value != "" {
+				args = append(args, "--set-tag="+t.name+"="+t.value)
+			}
+		}
+		args = append(args, filePath)
+		if _, err = runCommand("metaflac", args...); err != nil {
+			err = fmt.Errorf("tag '%v': metaflac: %w", filePath, err)
+		}
+	case ".mp3":
+		v := make(map[string]string)
+		for _, t := range tag {
+			v[t.name] = t.value
+		}
+		// empty values delete their frames, like metaflac removes tags
+		var del []string
+		for _, f := range []struct{ opt, frame, value string }{
+			{"-t", "TIT2", v["TITLE"]},
+			{"-a", "TPE1", v["ARTIST"]},
+			{"-A", "TALB", v["ALBUM"]},
+			{"-T", "TRCK", strings.Trim(v["TRACKNUMBER"]+"/"+v["TRACKTOTAL"], "/")},
+			{"--TSRC", "TSRC", v["ISRC"]},
+		} {
+			if f.value == "" {
+				del = append(del, f.frame)
+			} else {
+				args = append(args, f.opt, f.value)
+			}
+		}
+		if del != nil {
+			args = append(args, "--delete-frames="+strings.Join(del, ","))
+		}
+		args = append(args, filePath)
+		if _, err = runCommand("mid3v2", args...); err != nil {
+			err = fmt.Errorf("tag '%v': mid3v2: %w", filePath, err)
+		}
+	default:
+		err = fmt.Errorf("tag '%v': unsupported file type", filePath)
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timestamp is time in microseconds, the unit of all track times. Its methods
+// convert to CD frames, samples and milliseconds with explicit Rounding, so
+// scale factors are not repeated in every converter.
+type Timestamp int64
+
+// Rounding is direction of conversion to coarser units, the same for negative
+// times: RoundDown is toward minus infinity.
+type Rounding int
+
+const (
+	RoundDown Rounding = iota
+	RoundNearest
+	RoundUp
+)
+
+// ParseTimestamp parses time in seconds, or cue MM:SS:FF if it contains
+// colons, like times of command options.
+func ParseTimestamp(s string) (Timestamp, error) {
+	return parseTime(s)
+}
+
+// scale returns t in units of which perSec make a second.
+func (t Timestamp) scale(perSec int64, r Rounding) int64 {
+	n := int64(t) * perSec
+	switch r {
+	case RoundNearest:
+		n += uSecInSecond / 2
+	case RoundUp:
+		n += uSecInSecond - 1
+	}
+	return floorDiv(n, uSecInSecond)
+}
+
+// floorDiv returns a/b rounded toward minus infinity, for positive b.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
+
+// Seconds returns t in whole seconds.
+func (t Timestamp) Seconds(r Rounding) int64 {
+	return t.scale(1, r)
+}
+
+// Frames returns t in CD frames.
+func (t Timestamp) Frames(r Rounding) int64 {
+	return t.scale(cdFramesPerSecond, r)
+}
+
+// Samples returns t in samples of rate.
+func (t Timestamp) Samples(rate int64, r Rounding) int64 {
+	return t.scale(rate, r)
+}
+
+// Millis returns t in milliseconds.
+func (t Timestamp) Millis(r Rounding) int64 {
+	return t.scale(1000, r)
+}
+
+// AlignToFrame rounds t to the nearest CD frame, 588 sample boundary.
+func (t Timestamp) AlignToFrame() Timestamp {
+	return FramesTime(t.Frames(RoundNearest))
+}
+
+// Add returns t+d, or error if it overflows maximum duration or is negative.
+// d is truncated to microseconds.
+func (t Timestamp) Add(d time.Duration) (Timestamp, error) {
+	u := Timestamp(d / time.Microsecond)
+	if (u > 0 && t > maxDuration-u) || t+u < 0 {
+		return 0, fmt.Errorf("time %v%+v is out of range", t, d)
+	}
+	return t + u, nil
+}
+
+// Sub returns duration from u to t.
+func (t Timestamp) Sub(u Timestamp) time.Duration {
+	return t.Duration() - u.Duration()
+}
+
+// Duration returns t as time.Duration.
+func (t Timestamp) Duration() time.Duration {
+	return time.Duration(t) * time.Microsecond
+}
+
+// CueTime formats t as cue MM:SS:FF, rounding down to frame.
+func (t Timestamp) CueTime() string {
+	return newMSF(t.Frames(RoundDown)).String()
+}
+
+func (t Timestamp) String() string {
+	return formatTimeSec(t)
+}
+
+// FramesTime returns time of CD frames, rounded up so that Frames gives the
+// same frames back.
+func FramesTime(frames int64) Timestamp {
+	return Timestamp(-floorDiv(-frames*uSecInSecond, cdFramesPerSecond))
+}
+
+// SamplesTime returns time of samples of rate, rounded down.
+func SamplesTime(samples, rate int64) Timestamp {
+	return Timestamp(floorDiv(samples*uSecInSecond, rate))
+}
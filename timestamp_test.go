@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampFrames(t *testing.T) {
+	for _, c := range []struct {
+		t                 Timestamp
+		down, nearest, up int64
+		aligned           Timestamp
+	}{
+		{0, 0, 0, 0, 0},
+		{1, 0, 0, 1, 0},
+		{13333, 0, 1, 1, 13334},
+		{13334, 1, 1, 2, 13334},
+		{20000, 1, 2, 2, 26667},
+		{uSecInSecond, 75, 75, 75, uSecInSecond},
+		{uSecInSecond + 6666, 75, 75, 76, uSecInSecond},
+		{uSecInSecond + 6667, 75, 76, 76, uSecInSecond + 13334},
+		{3600 * uSecInSecond, 270000, 270000, 270000, 3600 * uSecInSecond},
+	} {
+		if n := c.t.Frames(RoundDown); n != c.down {
+			t.Errorf("%d.Frames(RoundDown) = %d, want %d", c.t, n, c.down)
+		}
+		if n := c.t.Frames(RoundNearest); n != c.nearest {
+			t.Errorf("%d.Frames(RoundNearest) = %d, want %d", c.t, n, c.nearest)
+		}
+		if n := c.t.Frames(RoundUp); n != c.up {
+			t.Errorf("%d.Frames(RoundUp) = %d, want %d", c.t, n, c.up)
+		}
+		if a := c.t.AlignToFrame(); a != c.aligned {
+			t.Errorf("%d.AlignToFrame() = %d, want %d", c.t, a, c.aligned)
+		}
+	}
+}
+
+func TestFramesTimeRoundTrip(t *testing.T) {
+	for f := range int64(100 * cdFramesPerSecond) {
+		ts := FramesTime(f)
+		for _, r := range []Rounding{RoundDown, RoundNearest} {
+			if n := ts.Frames(r); n != f {
+				t.Fatalf("FramesTime(%d).Frames(%v) = %d", f, r, n)
+			}
+		}
+		if a := ts.AlignToFrame(); a != ts {
+			t.Fatalf("FramesTime(%d).AlignToFrame() = %d, want %d", f, a, ts)
+		}
+		if s := ts.CueTime(); s != newMSF(f).String() {
+			t.Fatalf("FramesTime(%d).CueTime() = %v", f, s)
+		}
+		if p, err := parseCueTime(newMSF(f).String()); err != nil || Timestamp(p) != ts {
+			t.Fatalf("parseCueTime(%v) = %d, %v, want %d", newMSF(f), p, err, ts)
+		}
+	}
+}
+
+func TestSamplesTimeRoundTrip(t *testing.T) {
+	for _, rate := range []int64{cdSampleRate, 48000, 96000} {
+		for s := range rate {
+			if n := SamplesTime(s, rate).Samples(rate, RoundUp); n != s {
+				t.Fatalf("SamplesTime(%d, %d).Samples(RoundUp) = %d", s, rate, n)
+			}
+		}
+	}
+}
+
+func TestTimestampNegative(t *testing.T) {
+	for _, c := range []struct {
+		t                 Timestamp
+		down, nearest, up int64
+	}{
+		{-1, -1, 0, 0},
+		{-500000, -1, 0, 0},
+		{-500001, -1, -1, 0},
+		{-700000, -1, -1, 0},
+		{-1000000, -1, -1, -1},
+		{-1500000, -2, -1, -1},
+	} {
+		for _, r := range []struct {
+			r    Rounding
+			want int64
+		}{{RoundDown, c.down}, {RoundNearest, c.nearest}, {RoundUp, c.up}} {
+			if n := c.t.Seconds(r.r); n != r.want {
+				t.Errorf("%d.Seconds(%v) = %d, want %d", c.t, r.r, n, r.want)
+			}
+		}
+	}
+	if f := FramesTime(-1); f.Frames(RoundDown) != -1 || f.Frames(RoundNearest) != -1 {
+		t.Errorf("FramesTime(-1) = %d", f)
+	}
+}
+
+func TestTimestampArithmetic(t *testing.T) {
+	ts, err := ParseTimestamp("01:02:03")
+	if err != nil || ts != 62*uSecInSecond+40000 {
+		t.Fatalf("ParseTimestamp = %d, %v", ts, err)
+	}
+	u, err := ts.Add(-2 * time.Second)
+	if err != nil || ts.Sub(u) != 2*time.Second || u.Duration() != 60040*time.Millisecond {
+		t.Errorf("Add(-2s) = %v, %v", u, err)
+	}
+	if _, err = ts.Add(-time.Hour); err == nil {
+		t.Errorf("Add(-1h) succeeded")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timings is set by the global -timings option.
+var timings bool
+
+// timingStats collects time spent in phases for -timings summary.
+var timingStats struct {
+	sync.Mutex
+	start    time.Time
+	probe    []fileTiming
+	command  map[string]time.Duration
+	commands int
+	write    time.Duration
+}
+
+type fileTiming struct {
+	path string
+	d    time.Duration
+}
+
+// addProbeTiming adds file probe time since start.
+func addProbeTiming(path string, start time.Time) {
+	if !timings {
+		return
+	}
+	timingStats.Lock()
+	timingStats.probe = append(timingStats.probe, fileTiming{path, time.Since(start)})
+	timingStats.Unlock()
+}
+
+// addCommandTiming adds external command run time since start.
+func addCommandTiming(name string, start time.Time) {
+	if !timings {
+		return
+	}
+	timingStats.Lock()
+	if timingStats.command == nil {
+		timingStats.command = make(map[string]time.Duration)
+	}
+	timingStats.command[filepath.Base(name)] += time.Since(start)
+	timingStats.commands++
+	timingStats.Unlock()
+}
+
+// timedWriter adds time of writes to -timings write time.
+type timedWriter struct {
+	io.WriteCloser
+}
+
+func (w timedWriter) Write(p []byte) (int, error) {
+	t := time.Now()
+	n, err := w.WriteCloser.Write(p)
+	timingStats.Lock()
+	timingStats.write += time.Since(t)
+	timingStats.Unlock()
+	return n, err
+}
+
+// logTimings logs -timings summary line by line, so it is JSON lines with
+// -log-format json.
+func logTimings() {
+	var b strings.Builder
+
+	writeTimings(&b)
+	logLines(b.String())
+}
+
+// writeTimings writes -timings summary.
+func writeTimings(w io.Writer) {
+	var total time.Duration
+
+	timingStats.Lock()
+	defer timingStats.Unlock()
+	fmt.Fprintln(w, "Timings:")
+	for _, p := range timingStats.probe {
+		fmt.Fprintf(w, "  probe    %10v  %v\n", p.d.Round(time.Microsecond), p.path)
+	}
+	for _, d := range timingStats.command {
+		total += d
+	}
+	fmt.Fprintf(w, "  commands %10v  %d run(s)", total.Round(time.Microsecond),
+		timingStats.commands)
+	for _, name := range slices.Sorted(maps.Keys(timingStats.command)) {
+		fmt.Fprintf(w, ", %v %v", name, timingStats.command[name].Round(time.Microsecond))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  write    %10v\n", timingStats.write.Round(time.Microsecond))
+	fmt.Fprintf(w, "  total    %10v\n", time.Since(timingStats.start).Round(time.Microsecond))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	bracketJunkRe = regexp.MustCompile(`\s*(\[[^\]]*\]|\{[^}]*\})`)
+	spaceRe       = regexp.MustCompile(`\s+`)
+)
+
+// titleSmallWords are not capitalized by -title-case inside a title.
+var titleSmallWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "for": true, "in": true, "nor": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "vs": true,
+}
+
+// titleOptions control titles made from file names.
+type titleOptions struct {
+	denum      bool
+	underscore bool
+	collapse   bool
+	brackets   bool
+	titleCase  bool
+	norm       string // normNFC, normNFD or empty
+	num        numFormat
+	dedup      bool
+}
+
+func (opt *titleOptions) addFlags(fl *flag.FlagSet) {
+	fl.BoolVar(&opt.denum, "denum", false, "remove track numbers from file names")
+	fl.BoolVar(&opt.underscore, "underscores", false, "replace underscores with spaces")
+	fl.BoolVar(&opt.collapse, "collapse-space", false, "collapse and trim whitespace")
+	fl.BoolVar(&opt.brackets, "strip-brackets", false, "remove [...] and {...} parts")
+	fl.BoolVar(&opt.titleCase, "title-case", false, "capitalize words")
+	fl.BoolFunc("nfc", "compose accented letters", func(string) error {
+		opt.norm = normNFC
+		return nil
+	})
+	fl.BoolFunc("nfd", "decompose accented letters", func(string) error {
+		opt.norm = normNFD
+		return nil
+	})
+	fl.Func("num-format", "number format of titles without text, like %02d. or vinyl:6,5",
+		func(s string) (err error) {
+			opt.num, err = parseNumFormat(s, defaultNumDigits)
+			return
+		})
+	fl.BoolVar(&opt.dedup, "dedup", false, "append (2), (3)... to duplicate titles")
+	fl.BoolFunc("clean", "enable all title cleanups", func(string) error {
+		opt.underscore, opt.collapse, opt.brackets, opt.titleCase = true, true, true, true
+		return nil
+	})
+}
+
+func (opt *titleOptions) clean(title string) string {
+	title = normalize(title, opt.norm)
+	if opt.underscore {
+		title = strings.ReplaceAll(title, "_", " ")
+	}
+	if opt.brackets {
+		title = bracketJunkRe.ReplaceAllString(title, "")
+	}
+	if opt.collapse {
+		title = strings.TrimSpace(spaceRe.ReplaceAllString(title, " "))
+	}
+	if opt.titleCase {
+		title = toTitleCase(title)
+	}
+	return title
+}
+
+// dedupTitles finds titles differing only in letter case. They are warned
+// about, rejected with -strict or numbered with -dedup.
+func (opt *titleOptions) dedupTitles(title []string) {
+	seen := make(map[string]int)
+	for i, t := range title {
+		k := strings.ToLower(t)
+		seen[k]++
+		switch {
+		case seen[k] == 1:
+		case opt.dedup:
+			title[i] = fmt.Sprintf("%v (%d)", t, seen[k])
+		case strictCue:
+			panic(fmt.Sprintf("Duplicate track title %q, use -dedup", t))
+		case seen[k] == 2:
+			logWarningMessage(fmt.Sprintf("duplicate track title %q", t))
+		}
+	}
+}
+
+// toTitleCase capitalizes the first letter of every word except small words
+// in the middle of title. Words with capitals after the first letter, like
+// acronyms, are kept as is.
+func toTitleCase(title string) string {
+	word := strings.Split(title, " ")
+	for i, w := range word {
+		r, n := utf8.DecodeRuneInString(w)
+		if n == 0 || strings.IndexFunc(w[n:], unicode.IsUpper) >= 0 {
+			continue
+		}
+		if i > 0 && i < len(word)-1 && titleSmallWords[strings.ToLower(w)] {
+			word[i] = strings.ToLower(w)
+			continue
+		}
+		word[i] = string(unicode.ToUpper(r)) + w[n:]
+	}
+	return strings.Join(word, " ")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// translitTab maps capital letters to Latin, lowercase letters are mapped
+// through their capitals. Cyrillic follows common passport-style
+// romanization, Greek follows ELOT 743.
+var translitTab = map[rune]string{
+	// Cyrillic
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Д': "D", 'Е': "E", 'Ё': "Yo",
+	'Ж': "Zh", 'З': "Z", 'И': "I", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M",
+	'Н': "N", 'О': "O", 'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U",
+	'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch",
+	'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu", 'Я': "Ya",
+	'Є': "Ye", 'І': "I", 'Ї': "Yi", 'Ґ': "G", 'Ў': "U",
+	'Ђ': "Dj", 'Ј': "J", 'Љ': "Lj", 'Њ': "Nj", 'Ћ': "C", 'Џ': "Dz",
+	// Greek
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I",
+	'Θ': "Th", 'Ι': "I", 'Κ': "K", 'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X",
+	'Ο': "O", 'Π': "P", 'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y", 'Φ': "F",
+	'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+	// Latin letters without decomposition
+	'Æ': "AE", 'Ø': "O", 'Œ': "OE", 'Þ': "Th", 'Ð': "D", 'Ł': "L", 'Đ': "D",
+	'Ħ': "H", 'Ŧ': "T", 'ß': "ss", 'ı': "i",
+	// punctuation
+	'‐': "-", '–': "-", '—': "-", '‘': "'", '’': "'", '“': `"`, '”': `"`,
+	'«': `"`, '»': `"`, '…': "...",
+}
+
+// transliterate converts Cyrillic and Greek letters to Latin, removes
+// diacritics and replaces typographic punctuation. Other characters are kept.
+func transliterate(s string) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		if t, ok := translitRune(r); ok {
+			b.WriteString(t)
+			continue
+		}
+		d, ok := unicodeDecomp[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		for _, r := range d {
+			if unicode.Is(unicode.Mn, r) {
+				continue
+			}
+			if t, ok := translitRune(r); ok {
+				b.WriteString(t)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+func translitRune(r rune) (string, bool) {
+	if t, ok := translitTab[r]; ok {
+		return t, true
+	}
+	if u := unicode.ToUpper(r); u != r {
+		if t, ok := translitTab[u]; ok {
+			return strings.ToLower(t), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,424 @@
+package main
+
+// unicodeDecomp holds canonical decompositions (NFD) of precomposed Latin,
+// Greek and Cyrillic letters and kana from Unicode 14.0.0. Hangul syllables
+// are decomposed algorithmically.
+var unicodeDecomp = map[rune]string{
+	0x00c0: "A\u0300", 0x00c1: "A\u0301", 0x00c2: "A\u0302", 0x00c3: "A\u0303",
+	0x00c4: "A\u0308", 0x00c5: "A\u030a", 0x00c7: "C\u0327", 0x00c8: "E\u0300",
+	0x00c9: "E\u0301", 0x00ca: "E\u0302", 0x00cb: "E\u0308", 0x00cc: "I\u0300",
+	0x00cd: "I\u0301", 0x00ce: "I\u0302", 0x00cf: "I\u0308", 0x00d1: "N\u0303",
+	0x00d2: "O\u0300", 0x00d3: "O\u0301", 0x00d4: "O\u0302", 0x00d5: "O\u0303",
+	0x00d6: "O\u0308", 0x00d9: "U\u0300", 0x00da: "U\u0301", 0x00db: "U\u0302",
+	0x00dc: "U\u0308", 0x00dd: "Y\u0301", 0x00e0: "a\u0300", 0x00e1: "a\u0301",
+	0x00e2: "a\u0302", 0x00e3: "a\u0303", 0x00e4: "a\u0308", 0x00e5: "a\u030a",
+	0x00e7: "c\u0327", 0x00e8: "e\u0300", 0x00e9: "e\u0301", 0x00ea: "e\u0302",
+	0x00eb: "e\u0308", 0x00ec: "i\u0300", 0x00ed: "i\u0301", 0x00ee: "i\u0302",
+	0x00ef: "i\u0308", 0x00f1: "n\u0303", 0x00f2: "o\u0300", 0x00f3: "o\u0301",
+	0x00f4: "o\u0302", 0x00f5: "o\u0303", 0x00f6: "o\u0308", 0x00f9: "u\u0300",
+	0x00fa: "u\u0301", 0x00fb: "u\u0302", 0x00fc: "u\u0308", 0x00fd: "y\u0301",
+	0x00ff: "y\u0308", 0x0100: "A\u0304", 0x0101: "a\u0304", 0x0102: "A\u0306",
+	0x0103: "a\u0306", 0x0104: "A\u0328", 0x0105: "a\u0328", 0x0106: "C\u0301",
+	0x0107: "c\u0301", 0x0108: "C\u0302", 0x0109: "c\u0302", 0x010a: "C\u0307",
+	0x010b: "c\u0307", 0x010c: "C\u030c", 0x010d: "c\u030c", 0x010e: "D\u030c",
+	0x010f: "d\u030c", 0x0112: "E\u0304", 0x0113: "e\u0304", 0x0114: "E\u0306",
+	0x0115: "e\u0306", 0x0116: "E\u0307", 0x0117: "e\u0307", 0x0118: "E\u0328",
+	0x0119: "e\u0328", 0x011a: "E\u030c", 0x011b: "e\u030c", 0x011c: "G\u0302",
+	0x011d: "g\u0302", 0x011e: "G\u0306", 0x011f: "g\u0306", 0x0120: "G\u0307",
+	0x0121: "g\u0307", 0x0122: "G\u0327", 0x0123: "g\u0327", 0x0124: "H\u0302",
+	0x0125: "h\u0302", 0x0128: "I\u0303", 0x0129: "i\u0303", 0x012a: "I\u0304",
+	0x012b: "i\u0304", 0x012c: "I\u0306", 0x012d: "i\u0306", 0x012e: "I\u0328",
+	0x012f: "i\u0328", 0x0130: "I\u0307", 0x0134: "J\u0302", 0x0135: "j\u0302",
+	0x0136: "K\u0327", 0x0137: "k\u0327", 0x0139: "L\u0301", 0x013a: "l\u0301",
+	0x013b: "L\u0327", 0x013c: "l\u0327", 0x013d: "L\u030c", 0x013e: "l\u030c",
+	0x0143: "N\u0301", 0x0144: "n\u0301", 0x0145: "N\u0327", 0x0146: "n\u0327",
+	0x0147: "N\u030c", 0x0148: "n\u030c", 0x014c: "O\u0304", 0x014d: "o\u0304",
+	0x014e: "O\u0306", 0x014f: "o\u0306", 0x0150: "O\u030b", 0x0151: "o\u030b",
+	0x0154: "R\u0301", 0x0155: "r\u0301", 0x0156: "R\u0327", 0x0157: "r\u0327",
+	0x0158: "R\u030c", 0x0159: "r\u030c", 0x015a: "S\u0301", 0x015b: "s\u0301",
+	0x015c: "S\u0302", 0x015d: "s\u0302", 0x015e: "S\u0327", 0x015f: "s\u0327",
+	0x0160: "S\u030c", 0x0161: "s\u030c", 0x0162: "T\u0327", 0x0163: "t\u0327",
+	0x0164: "T\u030c", 0x0165: "t\u030c", 0x0168: "U\u0303", 0x0169: "u\u0303",
+	0x016a: "U\u0304", 0x016b: "u\u0304", 0x016c: "U\u0306", 0x016d: "u\u0306",
+	0x016e: "U\u030a", 0x016f: "u\u030a", 0x0170: "U\u030b", 0x0171: "u\u030b",
+	0x0172: "U\u0328", 0x0173: "u\u0328", 0x0174: "W\u0302", 0x0175: "w\u0302",
+	0x0176: "Y\u0302", 0x0177: "y\u0302", 0x0178: "Y\u0308", 0x0179: "Z\u0301",
+	0x017a: "z\u0301", 0x017b: "Z\u0307", 0x017c: "z\u0307", 0x017d: "Z\u030c",
+	0x017e: "z\u030c", 0x01a0: "O\u031b", 0x01a1: "o\u031b", 0x01af: "U\u031b",
+	0x01b0: "u\u031b", 0x01cd: "A\u030c", 0x01ce: "a\u030c", 0x01cf: "I\u030c",
+	0x01d0: "i\u030c", 0x01d1: "O\u030c", 0x01d2: "o\u030c", 0x01d3: "U\u030c",
+	0x01d4: "u\u030c", 0x01d5: "U\u0308\u0304", 0x01d6: "u\u0308\u0304",
+	0x01d7: "U\u0308\u0301", 0x01d8: "u\u0308\u0301", 0x01d9: "U\u0308\u030c",
+	0x01da: "u\u0308\u030c", 0x01db: "U\u0308\u0300", 0x01dc: "u\u0308\u0300",
+	0x01de: "A\u0308\u0304", 0x01df: "a\u0308\u0304", 0x01e0: "A\u0307\u0304",
+	0x01e1: "a\u0307\u0304", 0x01e2: "\u00c6\u0304", 0x01e3: "\u00e6\u0304",
+	0x01e6: "G\u030c", 0x01e7: "g\u030c", 0x01e8: "K\u030c", 0x01e9: "k\u030c",
+	0x01ea: "O\u0328", 0x01eb: "o\u0328", 0x01ec: "O\u0328\u0304",
+	0x01ed: "o\u0328\u0304", 0x01ee: "\u01b7\u030c", 0x01ef: "\u0292\u030c",
+	0x01f0: "j\u030c", 0x01f4: "G\u0301", 0x01f5: "g\u0301", 0x01f8: "N\u0300",
+	0x01f9: "n\u0300", 0x01fa: "A\u030a\u0301", 0x01fb: "a\u030a\u0301",
+	0x01fc: "\u00c6\u0301", 0x01fd: "\u00e6\u0301", 0x01fe: "\u00d8\u0301",
+	0x01ff: "\u00f8\u0301", 0x0200: "A\u030f", 0x0201: "a\u030f",
+	0x0202: "A\u0311", 0x0203: "a\u0311", 0x0204: "E\u030f", 0x0205: "e\u030f",
+	0x0206: "E\u0311", 0x0207: "e\u0311", 0x0208: "I\u030f", 0x0209: "i\u030f",
+	0x020a: "I\u0311", 0x020b: "i\u0311", 0x020c: "O\u030f", 0x020d: "o\u030f",
+	0x020e: "O\u0311", 0x020f: "o\u0311", 0x0210: "R\u030f", 0x0211: "r\u030f",
+	0x0212: "R\u0311", 0x0213: "r\u0311", 0x0214: "U\u030f", 0x0215: "u\u030f",
+	0x0216: "U\u0311", 0x0217: "u\u0311", 0x0218: "S\u0326", 0x0219: "s\u0326",
+	0x021a: "T\u0326", 0x021b: "t\u0326", 0x021e: "H\u030c", 0x021f: "h\u030c",
+	0x0226: "A\u0307", 0x0227: "a\u0307", 0x0228: "E\u0327", 0x0229: "e\u0327",
+	0x022a: "O\u0308\u0304", 0x022b: "o\u0308\u0304", 0x022c: "O\u0303\u0304",
+	0x022d: "o\u0303\u0304", 0x022e: "O\u0307", 0x022f: "o\u0307",
+	0x0230: "O\u0307\u0304", 0x0231: "o\u0307\u0304", 0x0232: "Y\u0304",
+	0x0233: "y\u0304", 0x0374: "\u02b9", 0x037e: ";", 0x0385: "\u00a8\u0301",
+	0x0386: "\u0391\u0301", 0x0387: "\u00b7", 0x0388: "\u0395\u0301",
+	0x0389: "\u0397\u0301", 0x038a: "\u0399\u0301", 0x038c: "\u039f\u0301",
+	0x038e: "\u03a5\u0301", 0x038f: "\u03a9\u0301", 0x0390: "\u03b9\u0308\u0301",
+	0x03aa: "\u0399\u0308", 0x03ab: "\u03a5\u0308", 0x03ac: "\u03b1\u0301",
+	0x03ad: "\u03b5\u0301", 0x03ae: "\u03b7\u0301", 0x03af: "\u03b9\u0301",
+	0x03b0: "\u03c5\u0308\u0301", 0x03ca: "\u03b9\u0308", 0x03cb: "\u03c5\u0308",
+	0x03cc: "\u03bf\u0301", 0x03cd: "\u03c5\u0301", 0x03ce: "\u03c9\u0301",
+	0x03d3: "\u03d2\u0301", 0x03d4: "\u03d2\u0308", 0x0400: "\u0415\u0300",
+	0x0401: "\u0415\u0308", 0x0403: "\u0413\u0301", 0x0407: "\u0406\u0308",
+	0x040c: "\u041a\u0301", 0x040d: "\u0418\u0300", 0x040e: "\u0423\u0306",
+	0x0419: "\u0418\u0306", 0x0439: "\u0438\u0306", 0x0450: "\u0435\u0300",
+	0x0451: "\u0435\u0308", 0x0453: "\u0433\u0301", 0x0457: "\u0456\u0308",
+	0x045c: "\u043a\u0301", 0x045d: "\u0438\u0300", 0x045e: "\u0443\u0306",
+	0x0476: "\u0474\u030f", 0x0477: "\u0475\u030f", 0x04c1: "\u0416\u0306",
+	0x04c2: "\u0436\u0306", 0x04d0: "\u0410\u0306", 0x04d1: "\u0430\u0306",
+	0x04d2: "\u0410\u0308", 0x04d3: "\u0430\u0308", 0x04d6: "\u0415\u0306",
+	0x04d7: "\u0435\u0306", 0x04da: "\u04d8\u0308", 0x04db: "\u04d9\u0308",
+	0x04dc: "\u0416\u0308", 0x04dd: "\u0436\u0308", 0x04de: "\u0417\u0308",
+	0x04df: "\u0437\u0308", 0x04e2: "\u0418\u0304", 0x04e3: "\u0438\u0304",
+	0x04e4: "\u0418\u0308", 0x04e5: "\u0438\u0308", 0x04e6: "\u041e\u0308",
+	0x04e7: "\u043e\u0308", 0x04ea: "\u04e8\u0308", 0x04eb: "\u04e9\u0308",
+	0x04ec: "\u042d\u0308", 0x04ed: "\u044d\u0308", 0x04ee: "\u0423\u0304",
+	0x04ef: "\u0443\u0304", 0x04f0: "\u0423\u0308", 0x04f1: "\u0443\u0308",
+	0x04f2: "\u0423\u030b", 0x04f3: "\u0443\u030b", 0x04f4: "\u0427\u0308",
+	0x04f5: "\u0447\u0308", 0x04f8: "\u042b\u0308", 0x04f9: "\u044b\u0308",
+	0x1e00: "A\u0325", 0x1e01: "a\u0325", 0x1e02: "B\u0307", 0x1e03: "b\u0307",
+	0x1e04: "B\u0323", 0x1e05: "b\u0323", 0x1e06: "B\u0331", 0x1e07: "b\u0331",
+	0x1e08: "C\u0327\u0301", 0x1e09: "c\u0327\u0301", 0x1e0a: "D\u0307",
+	0x1e0b: "d\u0307", 0x1e0c: "D\u0323", 0x1e0d: "d\u0323", 0x1e0e: "D\u0331",
+	0x1e0f: "d\u0331", 0x1e10: "D\u0327", 0x1e11: "d\u0327", 0x1e12: "D\u032d",
+	0x1e13: "d\u032d", 0x1e14: "E\u0304\u0300", 0x1e15: "e\u0304\u0300",
+	0x1e16: "E\u0304\u0301", 0x1e17: "e\u0304\u0301", 0x1e18: "E\u032d",
+	0x1e19: "e\u032d", 0x1e1a: "E\u0330", 0x1e1b: "e\u0330",
+	0x1e1c: "E\u0327\u0306", 0x1e1d: "e\u0327\u0306", 0x1e1e: "F\u0307",
+	0x1e1f: "f\u0307", 0x1e20: "G\u0304", 0x1e21: "g\u0304", 0x1e22: "H\u0307",
+	0x1e23: "h\u0307", 0x1e24: "H\u0323", 0x1e25: "h\u0323", 0x1e26: "H\u0308",
+	0x1e27: "h\u0308", 0x1e28: "H\u0327", 0x1e29: "h\u0327", 0x1e2a: "H\u032e",
+	0x1e2b: "h\u032e", 0x1e2c: "I\u0330", 0x1e2d: "i\u0330",
+	0x1e2e: "I\u0308\u0301", 0x1e2f: "i\u0308\u0301", 0x1e30: "K\u0301",
+	0x1e31: "k\u0301", 0x1e32: "K\u0323", 0x1e33: "k\u0323", 0x1e34: "K\u0331",
+	0x1e35: "k\u0331", 0x1e36: "L\u0323", 0x1e37: "l\u0323",
+	0x1e38: "L\u0323\u0304", 0x1e39: "l\u0323\u0304", 0x1e3a: "L\u0331",
+	0x1e3b: "l\u0331", 0x1e3c: "L\u032d", 0x1e3d: "l\u032d", 0x1e3e: "M\u0301",
+	0x1e3f: "m\u0301", 0x1e40: "M\u0307", 0x1e41: "m\u0307", 0x1e42: "M\u0323",
+	0x1e43: "m\u0323", 0x1e44: "N\u0307", 0x1e45: "n\u0307", 0x1e46: "N\u0323",
+	0x1e47: "n\u0323", 0x1e48: "N\u0331", 0x1e49: "n\u0331", 0x1e4a: "N\u032d",
+	0x1e4b: "n\u032d", 0x1e4c: "O\u0303\u0301", 0x1e4d: "o\u0303\u0301",
+	0x1e4e: "O\u0303\u0308", 0x1e4f: "o\u0303\u0308", 0x1e50: "O\u0304\u0300",
+	0x1e51: "o\u0304\u0300", 0x1e52: "O\u0304\u0301", 0x1e53: "o\u0304\u0301",
+	0x1e54: "P\u0301", 0x1e55: "p\u0301", 0x1e56: "P\u0307", 0x1e57: "p\u0307",
+	0x1e58: "R\u0307", 0x1e59: "r\u0307", 0x1e5a: "R\u0323", 0x1e5b: "r\u0323",
+	0x1e5c: "R\u0323\u0304", 0x1e5d: "r\u0323\u0304", 0x1e5e: "R\u0331",
+	0x1e5f: "r\u0331", 0x1e60: "S\u0307", 0x1e61: "s\u0307", 0x1e62: "S\u0323",
+	0x1e63: "s\u0323", 0x1e64: "S\u0301\u0307", 0x1e65: "s\u0301\u0307",
+	0x1e66: "S\u030c\u0307", 0x1e67: "s\u030c\u0307", 0x1e68: "S\u0323\u0307",
+	0x1e69: "s\u0323\u0307", 0x1e6a: "T\u0307", 0x1e6b: "t\u0307",
+	0x1e6c: "T\u0323", 0x1e6d: "t\u0323", 0x1e6e: "T\u0331", 0x1e6f: "t\u0331",
+	0x1e70: "T\u032d", 0x1e71: "t\u032d", 0x1e72: "U\u0324", 0x1e73: "u\u0324",
+	0x1e74: "U\u0330", 0x1e75: "u\u0330", 0x1e76: "U\u032d", 0x1e77: "u\u032d",
+	0x1e78: "U\u0303\u0301", 0x1e79: "u\u0303\u0301", 0x1e7a: "U\u0304\u0308",
+	0x1e7b: "u\u0304\u0308", 0x1e7c: "V\u0303", 0x1e7d: "v\u0303",
+	0x1e7e: "V\u0323", 0x1e7f: "v\u0323", 0x1e80: "W\u0300", 0x1e81: "w\u0300",
+	0x1e82: "W\u0301", 0x1e83: "w\u0301", 0x1e84: "W\u0308", 0x1e85: "w\u0308",
+	0x1e86: "W\u0307", 0x1e87: "w\u0307", 0x1e88: "W\u0323", 0x1e89: "w\u0323",
+	0x1e8a: "X\u0307", 0x1e8b: "x\u0307", 0x1e8c: "X\u0308", 0x1e8d: "x\u0308",
+	0x1e8e: "Y\u0307", 0x1e8f: "y\u0307", 0x1e90: "Z\u0302", 0x1e91: "z\u0302",
+	0x1e92: "Z\u0323", 0x1e93: "z\u0323", 0x1e94: "Z\u0331", 0x1e95: "z\u0331",
+	0x1e96: "h\u0331", 0x1e97: "t\u0308", 0x1e98: "w\u030a", 0x1e99: "y\u030a",
+	0x1e9b: "\u017f\u0307", 0x1ea0: "A\u0323", 0x1ea1: "a\u0323",
+	0x1ea2: "A\u0309", 0x1ea3: "a\u0309", 0x1ea4: "A\u0302\u0301",
+	0x1ea5: "a\u0302\u0301", 0x1ea6: "A\u0302\u0300", 0x1ea7: "a\u0302\u0300",
+	0x1ea8: "A\u0302\u0309", 0x1ea9: "a\u0302\u0309", 0x1eaa: "A\u0302\u0303",
+	0x1eab: "a\u0302\u0303", 0x1eac: "A\u0323\u0302", 0x1ead: "a\u0323\u0302",
+	0x1eae: "A\u0306\u0301", 0x1eaf: "a\u0306\u0301", 0x1eb0: "A\u0306\u0300",
+	0x1eb1: "a\u0306\u0300", 0x1eb2: "A\u0306\u0309", 0x1eb3: "a\u0306\u0309",
+	0x1eb4: "A\u0306\u0303", 0x1eb5: "a\u0306\u0303", 0x1eb6: "A\u0323\u0306",
+	0x1eb7: "a\u0323\u0306", 0x1eb8: "E\u0323", 0x1eb9: "e\u0323",
+	0x1eba: "E\u0309", 0x1ebb: "e\u0309", 0x1ebc: "E\u0303", 0x1ebd: "e\u0303",
+	0x1ebe: "E\u0302\u0301", 0x1ebf: "e\u0302\u0301", 0x1ec0: "E\u0302\u0300",
+	0x1ec1: "e\u0302\u0300", 0x1ec2: "E\u0302\u0309", 0x1ec3: "e\u0302\u0309",
+	0x1ec4: "E\u0302\u0303", 0x1ec5: "e\u0302\u0303", 0x1ec6: "E\u0323\u0302",
+	0x1ec7: "e\u0323\u0302", 0x1ec8: "I\u0309", 0x1ec9: "i\u0309",
+	0x1eca: "I\u0323", 0x1ecb: "i\u0323", 0x1ecc: "O\u0323", 0x1ecd: "o\u0323",
+	0x1ece: "O\u0309", 0x1ecf: "o\u0309", 0x1ed0: "O\u0302\u0301",
+	0x1ed1: "o\u0302\u0301", 0x1ed2: "O\u0302\u0300", 0x1ed3: "o\u0302\u0300",
+	0x1ed4: "O\u0302\u0309", 0x1ed5: "o\u0302\u0309", 0x1ed6: "O\u0302\u0303",
+	0x1ed7: "o\u0302\u0303", 0x1ed8: "O\u0323\u0302", 0x1ed9: "o\u0323\u0302",
+	0x1eda: "O\u031b\u0301", 0x1edb: "o\u031b\u0301", 0x1edc: "O\u031b\u0300",
+	0x1edd: "o\u031b\u0300", 0x1ede: "O\u031b\u0309", 0x1edf: "o\u031b\u0309",
+	0x1ee0: "O\u031b\u0303", 0x1ee1: "o\u031b\u0303", 0x1ee2: "O\u031b\u0323",
+	0x1ee3: "o\u031b\u0323", 0x1ee4: "U\u0323", 0x1ee5: "u\u0323",
+	0x1ee6: "U\u0309", 0x1ee7: "u\u0309", 0x1ee8: "U\u031b\u0301",
+	0x1ee9: "u\u031b\u0301", 0x1eea: "U\u031b\u0300", 0x1eeb: "u\u031b\u0300",
+	0x1eec: "U\u031b\u0309", 0x1eed: "u\u031b\u0309", 0x1eee: "U\u031b\u0303",
+	0x1eef: "u\u031b\u0303", 0x1ef0: "U\u031b\u0323", 0x1ef1: "u\u031b\u0323",
+	0x1ef2: "Y\u0300", 0x1ef3: "y\u0300", 0x1ef4: "Y\u0323", 0x1ef5: "y\u0323",
+	0x1ef6: "Y\u0309", 0x1ef7: "y\u0309", 0x1ef8: "Y\u0303", 0x1ef9: "y\u0303",
+	0x1f00: "\u03b1\u0313", 0x1f01: "\u03b1\u0314", 0x1f02: "\u03b1\u0313\u0300",
+	0x1f03: "\u03b1\u0314\u0300", 0x1f04: "\u03b1\u0313\u0301",
+	0x1f05: "\u03b1\u0314\u0301", 0x1f06: "\u03b1\u0313\u0342",
+	0x1f07: "\u03b1\u0314\u0342", 0x1f08: "\u0391\u0313", 0x1f09: "\u0391\u0314",
+	0x1f0a: "\u0391\u0313\u0300", 0x1f0b: "\u0391\u0314\u0300",
+	0x1f0c: "\u0391\u0313\u0301", 0x1f0d: "\u0391\u0314\u0301",
+	0x1f0e: "\u0391\u0313\u0342", 0x1f0f: "\u0391\u0314\u0342",
+	0x1f10: "\u03b5\u0313", 0x1f11: "\u03b5\u0314", 0x1f12: "\u03b5\u0313\u0300",
+	0x1f13: "\u03b5\u0314\u0300", 0x1f14: "\u03b5\u0313\u0301",
+	0x1f15: "\u03b5\u0314\u0301", 0x1f18: "\u0395\u0313", 0x1f19: "\u0395\u0314",
+	0x1f1a: "\u0395\u0313\u0300", 0x1f1b: "\u0395\u0314\u0300",
+	0x1f1c: "\u0395\u0313\u0301", 0x1f1d: "\u0395\u0314\u0301",
+	0x1f20: "\u03b7\u0313", 0x1f21: "\u03b7\u0314", 0x1f22: "\u03b7\u0313\u0300",
+	0x1f23: "\u03b7\u0314\u0300", 0x1f24: "\u03b7\u0313\u0301",
+	0x1f25: "\u03b7\u0314\u0301", 0x1f26: "\u03b7\u0313\u0342",
+	0x1f27: "\u03b7\u0314\u0342", 0x1f28: "\u0397\u0313", 0x1f29: "\u0397\u0314",
+	0x1f2a: "\u0397\u0313\u0300", 0x1f2b: "\u0397\u0314\u0300",
+	0x1f2c: "\u0397\u0313\u0301", 0x1f2d: "\u0397\u0314\u0301",
+	0x1f2e: "\u0397\u0313\u0342", 0x1f2f: "\u0397\u0314\u0342",
+	0x1f30: "\u03b9\u0313", 0x1f31: "\u03b9\u0314", 0x1f32: "\u03b9\u0313\u0300",
+	0x1f33: "\u03b9\u0314\u0300", 0x1f34: "\u03b9\u0313\u0301",
+	0x1f35: "\u03b9\u0314\u0301", 0x1f36: "\u03b9\u0313\u0342",
+	0x1f37: "\u03b9\u0314\u0342", 0x1f38: "\u0399\u0313", 0x1f39: "\u0399\u0314",
+	0x1f3a: "\u0399\u0313\u0300", 0x1f3b: "\u0399\u0314\u0300",
+	0x1f3c: "\u0399\u0313\u0301", 0x1f3d: "\u0399\u0314\u0301",
+	0x1f3e: "\u0399\u0313\u0342", 0x1f3f: "\u0399\u0314\u0342",
+	0x1f40: "\u03bf\u0313", 0x1f41: "\u03bf\u0314", 0x1f42: "\u03bf\u0313\u0300",
+	0x1f43: "\u03bf\u0314\u0300", 0x1f44: "\u03bf\u0313\u0301",
+	0x1f45: "\u03bf\u0314\u0301", 0x1f48: "\u039f\u0313", 0x1f49: "\u039f\u0314",
+	0x1f4a: "\u039f\u0313\u0300", 0x1f4b: "\u039f\u0314\u0300",
+	0x1f4c: "\u039f\u0313\u0301", 0x1f4d: "\u039f\u0314\u0301",
+	0x1f50: "\u03c5\u0313", 0x1f51: "\u03c5\u0314", 0x1f52: "\u03c5\u0313\u0300",
+	0x1f53: "\u03c5\u0314\u0300", 0x1f54: "\u03c5\u0313\u0301",
+	0x1f55: "\u03c5\u0314\u0301", 0x1f56: "\u03c5\u0313\u0342",
+	0x1f57: "\u03c5\u0314\u0342", 0x1f59: "\u03a5\u0314",
+	0x1f5b: "\u03a5\u0314\u0300", 0x1f5d: "\u03a5\u0314\u0301",
+	0x1f5f: "\u03a5\u0314\u0342", 0x1f60: "\u03c9\u0313", 0x1f61: "\u03c9\u0314",
+	0x1f62: "\u03c9\u0313\u0300", 0x1f63: "\u03c9\u0314\u0300",
+	0x1f64: "\u03c9\u0313\u0301", 0x1f65: "\u03c9\u0314\u0301",
+	0x1f66: "\u03c9\u0313\u0342", 0x1f67: "\u03c9\u0314\u0342",
+	0x1f68: "\u03a9\u0313", 0x1f69: "\u03a9\u0314", 0x1f6a: "\u03a9\u0313\u0300",
+	0x1f6b: "\u03a9\u0314\u0300", 0x1f6c: "\u03a9\u0313\u0301",
+	0x1f6d: "\u03a9\u0314\u0301", 0x1f6e: "\u03a9\u0313\u0342",
+	0x1f6f: "\u03a9\u0314\u0342", 0x1f70: "\u03b1\u0300", 0x1f71: "\u03b1\u0301",
+	0x1f72: "\u03b5\u0300", 0x1f73: "\u03b5\u0301", 0x1f74: "\u03b7\u0300",
+	0x1f75: "\u03b7\u0301", 0x1f76: "\u03b9\u0300", 0x1f77: "\u03b9\u0301",
+	0x1f78: "\u03bf\u0300", 0x1f79: "\u03bf\u0301", 0x1f7a: "\u03c5\u0300",
+	0x1f7b: "\u03c5\u0301", 0x1f7c: "\u03c9\u0300", 0x1f7d: "\u03c9\u0301",
+	0x1f80: "\u03b1\u0313\u0345", 0x1f81: "\u03b1\u0314\u0345",
+	0x1f82: "\u03b1\u0313\u0300\u0345", 0x1f83: "\u03b1\u0314\u0300\u0345",
+	0x1f84: "\u03b1\u0313\u0301\u0345", 0x1f85: "\u03b1\u0314\u0301\u0345",
+	0x1f86: "\u03b1\u0313\u0342\u0345", 0x1f87: "\u03b1\u0314\u0342\u0345",
+	0x1f88: "\u0391\u0313\u0345", 0x1f89: "\u0391\u0314\u0345",
+	0x1f8a: "\u0391\u0313\u0300\u0345", 0x1f8b: "\u0391\u0314\u0300\u0345",
+	0x1f8c: "\u0391\u0313\u0301\u0345", 0x1f8d: "\u0391\u0314\u0301\u0345",
+	0x1f8e: "\u0391\u0313\u0342\u0345", 0x1f8f: "\u0391\u0314\u0342\u0345",
+	0x1f90: "\u03b7\u0313\u0345", 0x1f91: "\u03b7\u0314\u0345",
+	0x1f92: "\u03b7\u0313\u0300\u0345", 0x1f93: "\u03b7\u0314\u0300\u0345",
+	0x1f94: "\u03b7\u0313\u0301\u0345", 0x1f95: "\u03b7\u0314\u0301\u0345",
+	0x1f96: "\u03b7\u0313\u0342\u0345", 0x1f97: "\u03b7\u0314\u0342\u0345",
+	0x1f98: "\u0397\u0313\u0345", 0x1f99: "\u0397\u0314\u0345",
+	0x1f9a: "\u0397\u0313\u0300\u0345", 0x1f9b: "\u0397\u0314\u0300\u0345",
+	0x1f9c: "\u0397\u0313\u0301\u0345", 0x1f9d: "\u0397\u0314\u0301\u0345",
+	0x1f9e: "\u0397\u0313\u0342\u0345", 0x1f9f: "\u0397\u0314\u0342\u0345",
+	0x1fa0: "\u03c9\u0313\u0345", 0x1fa1: "\u03c9\u0314\u0345",
+	0x1fa2: "\u03c9\u0313\u0300\u0345", 0x1fa3: "\u03c9\u0314\u0300\u0345",
+	0x1fa4: "\u03c9\u0313\u0301\u0345", 0x1fa5: "\u03c9\u0314\u0301\u0345",
+	0x1fa6: "\u03c9\u0313\u0342\u0345", 0x1fa7: "\u03c9\u0314\u0342\u0345",
+	0x1fa8: "\u03a9\u0313\u0345", 0x1fa9: "\u03a9\u0314\u0345",
+	0x1faa: "\u03a9\u0313\u0300\u0345", 0x1fab: "\u03a9\u0314\u0300\u0345",
+	0x1fac: "\u03a9\u0313\u0301\u0345", 0x1fad: "\u03a9\u0314\u0301\u0345",
+	0x1fae: "\u03a9\u0313\u0342\u0345", 0x1faf: "\u03a9\u0314\u0342\u0345",
+	0x1fb0: "\u03b1\u0306", 0x1fb1: "\u03b1\u0304", 0x1fb2: "\u03b1\u0300\u0345",
+	0x1fb3: "\u03b1\u0345", 0x1fb4: "\u03b1\u0301\u0345", 0x1fb6: "\u03b1\u0342",
+	0x1fb7: "\u03b1\u0342\u0345", 0x1fb8: "\u0391\u0306", 0x1fb9: "\u0391\u0304",
+	0x1fba: "\u0391\u0300", 0x1fbb: "\u0391\u0301", 0x1fbc: "\u0391\u0345",
+	0x1fbe: "\u03b9", 0x1fc1: "\u00a8\u0342", 0x1fc2: "\u03b7\u0300\u0345",
+	0x1fc3: "\u03b7\u0345", 0x1fc4: "\u03b7\u0301\u0345", 0x1fc6: "\u03b7\u0342",
+	0x1fc7: "\u03b7\u0342\u0345", 0x1fc8: "\u0395\u0300", 0x1fc9: "\u0395\u0301",
+	0x1fca: "\u0397\u0300", 0x1fcb: "\u0397\u0301", 0x1fcc: "\u0397\u0345",
+	0x1fcd: "\u1fbf\u0300", 0x1fce: "\u1fbf\u0301", 0x1fcf: "\u1fbf\u0342",
+	0x1fd0: "\u03b9\u0306", 0x1fd1: "\u03b9\u0304", 0x1fd2: "\u03b9\u0308\u0300",
+	0x1fd3: "\u03b9\u0308\u0301", 0x1fd6: "\u03b9\u0342",
+	0x1fd7: "\u03b9\u0308\u0342", 0x1fd8: "\u0399\u0306", 0x1fd9: "\u0399\u0304",
+	0x1fda: "\u0399\u0300", 0x1fdb: "\u0399\u0301", 0x1fdd: "\u1ffe\u0300",
+	0x1fde: "\u1ffe\u0301", 0x1fdf: "\u1ffe\u0342", 0x1fe0: "\u03c5\u0306",
+	0x1fe1: "\u03c5\u0304", 0x1fe2: "\u03c5\u0308\u0300",
+	0x1fe3: "\u03c5\u0308\u0301", 0x1fe4: "\u03c1\u0313", 0x1fe5: "\u03c1\u0314",
+	0x1fe6: "\u03c5\u0342", 0x1fe7: "\u03c5\u0308\u0342", 0x1fe8: "\u03a5\u0306",
+	0x1fe9: "\u03a5\u0304", 0x1fea: "\u03a5\u0300", 0x1feb: "\u03a5\u0301",
+	0x1fec: "\u03a1\u0314", 0x1fed: "\u00a8\u0300", 0x1fee: "\u00a8\u0301",
+	0x1fef: "`", 0x1ff2: "\u03c9\u0300\u0345", 0x1ff3: "\u03c9\u0345",
+	0x1ff4: "\u03c9\u0301\u0345", 0x1ff6: "\u03c9\u0342",
+	0x1ff7: "\u03c9\u0342\u0345", 0x1ff8: "\u039f\u0300", 0x1ff9: "\u039f\u0301",
+	0x1ffa: "\u03a9\u0300", 0x1ffb: "\u03a9\u0301", 0x1ffc: "\u03a9\u0345",
+	0x1ffd: "\u00b4",
+	// kana with dakuten and handakuten
+	0x304c: "\u304b\u3099", 0x304e: "\u304d\u3099", 0x3050: "\u304f\u3099",
+	0x3052: "\u3051\u3099", 0x3054: "\u3053\u3099", 0x3056: "\u3055\u3099",
+	0x3058: "\u3057\u3099", 0x305a: "\u3059\u3099", 0x305c: "\u305b\u3099",
+	0x305e: "\u305d\u3099", 0x3060: "\u305f\u3099", 0x3062: "\u3061\u3099",
+	0x3065: "\u3064\u3099", 0x3067: "\u3066\u3099", 0x3069: "\u3068\u3099",
+	0x3070: "\u306f\u3099", 0x3071: "\u306f\u309a", 0x3073: "\u3072\u3099",
+	0x3074: "\u3072\u309a", 0x3076: "\u3075\u3099", 0x3077: "\u3075\u309a",
+	0x3079: "\u3078\u3099", 0x307a: "\u3078\u309a", 0x307c: "\u307b\u3099",
+	0x307d: "\u307b\u309a", 0x3094: "\u3046\u3099", 0x309e: "\u309d\u3099",
+	0x30ac: "\u30ab\u3099", 0x30ae: "\u30ad\u3099", 0x30b0: "\u30af\u3099",
+	0x30b2: "\u30b1\u3099", 0x30b4: "\u30b3\u3099", 0x30b6: "\u30b5\u3099",
+	0x30b8: "\u30b7\u3099", 0x30ba: "\u30b9\u3099", 0x30bc: "\u30bb\u3099",
+	0x30be: "\u30bd\u3099", 0x30c0: "\u30bf\u3099", 0x30c2: "\u30c1\u3099",
+	0x30c5: "\u30c4\u3099", 0x30c7: "\u30c6\u3099", 0x30c9: "\u30c8\u3099",
+	0x30d0: "\u30cf\u3099", 0x30d1: "\u30cf\u309a", 0x30d3: "\u30d2\u3099",
+	0x30d4: "\u30d2\u309a", 0x30d6: "\u30d5\u3099", 0x30d7: "\u30d5\u309a",
+	0x30d9: "\u30d8\u3099", 0x30da: "\u30d8\u309a", 0x30dc: "\u30db\u3099",
+	0x30dd: "\u30db\u309a", 0x30f4: "\u30a6\u3099", 0x30f7: "\u30ef\u3099",
+	0x30f8: "\u30f0\u3099", 0x30f9: "\u30f1\u3099", 0x30fa: "\u30f2\u3099",
+	0x30fe: "\u30fd\u3099",
+}
+
+// unicodeCombining holds ranges of canonical combining classes other than
+// zero from Unicode 14.0.0, sorted by code point.
+var unicodeCombining = []struct {
+	lo, hi rune
+	class  uint8
+}{
+	{0x0300, 0x0314, 230}, {0x0315, 0x0315, 232}, {0x0316, 0x0319, 220},
+	{0x031a, 0x031a, 232}, {0x031b, 0x031b, 216}, {0x031c, 0x0320, 220},
+	{0x0321, 0x0322, 202}, {0x0323, 0x0326, 220}, {0x0327, 0x0328, 202},
+	{0x0329, 0x0333, 220}, {0x0334, 0x0338, 1}, {0x0339, 0x033c, 220},
+	{0x033d, 0x0344, 230}, {0x0345, 0x0345, 240}, {0x0346, 0x0346, 230},
+	{0x0347, 0x0349, 220}, {0x034a, 0x034c, 230}, {0x034d, 0x034e, 220},
+	{0x0350, 0x0352, 230}, {0x0353, 0x0356, 220}, {0x0357, 0x0357, 230},
+	{0x0358, 0x0358, 232}, {0x0359, 0x035a, 220}, {0x035b, 0x035b, 230},
+	{0x035c, 0x035c, 233}, {0x035d, 0x035e, 234}, {0x035f, 0x035f, 233},
+	{0x0360, 0x0361, 234}, {0x0362, 0x0362, 233}, {0x0363, 0x036f, 230},
+	{0x0483, 0x0487, 230}, {0x0591, 0x0591, 220}, {0x0592, 0x0595, 230},
+	{0x0596, 0x0596, 220}, {0x0597, 0x0599, 230}, {0x059a, 0x059a, 222},
+	{0x059b, 0x059b, 220}, {0x059c, 0x05a1, 230}, {0x05a2, 0x05a7, 220},
+	{0x05a8, 0x05a9, 230}, {0x05aa, 0x05aa, 220}, {0x05ab, 0x05ac, 230},
+	{0x05ad, 0x05ad, 222}, {0x05ae, 0x05ae, 228}, {0x05af, 0x05af, 230},
+	{0x05b0, 0x05b0, 10}, {0x05b1, 0x05b1, 11}, {0x05b2, 0x05b2, 12},
+	{0x05b3, 0x05b3, 13}, {0x05b4, 0x05b4, 14}, {0x05b5, 0x05b5, 15},
+	{0x05b6, 0x05b6, 16}, {0x05b7, 0x05b7, 17}, {0x05b8, 0x05b8, 18},
+	{0x05b9, 0x05ba, 19}, {0x05bb, 0x05bb, 20}, {0x05bc, 0x05bc, 21},
+	{0x05bd, 0x05bd, 22}, {0x05bf, 0x05bf, 23}, {0x05c1, 0x05c1, 24},
+	{0x05c2, 0x05c2, 25}, {0x05c4, 0x05c4, 230}, {0x05c5, 0x05c5, 220},
+	{0x05c7, 0x05c7, 18}, {0x0610, 0x0617, 230}, {0x0618, 0x0618, 30},
+	{0x0619, 0x0619, 31}, {0x061a, 0x061a, 32}, {0x064b, 0x064b, 27},
+	{0x064c, 0x064c, 28}, {0x064d, 0x064d, 29}, {0x064e, 0x064e, 30},
+	{0x064f, 0x064f, 31}, {0x0650, 0x0650, 32}, {0x0651, 0x0651, 33},
+	{0x0652, 0x0652, 34}, {0x0653, 0x0654, 230}, {0x0655, 0x0656, 220},
+	{0x0657, 0x065b, 230}, {0x065c, 0x065c, 220}, {0x065d, 0x065e, 230},
+	{0x065f, 0x065f, 220}, {0x0670, 0x0670, 35}, {0x06d6, 0x06dc, 230},
+	{0x06df, 0x06e2, 230}, {0x06e3, 0x06e3, 220}, {0x06e4, 0x06e4, 230},
+	{0x06e7, 0x06e8, 230}, {0x06ea, 0x06ea, 220}, {0x06eb, 0x06ec, 230},
+	{0x06ed, 0x06ed, 220}, {0x0711, 0x0711, 36}, {0x0730, 0x0730, 230},
+	{0x0731, 0x0731, 220}, {0x0732, 0x0733, 230}, {0x0734, 0x0734, 220},
+	{0x0735, 0x0736, 230}, {0x0737, 0x0739, 220}, {0x073a, 0x073a, 230},
+	{0x073b, 0x073c, 220}, {0x073d, 0x073d, 230}, {0x073e, 0x073e, 220},
+	{0x073f, 0x0741, 230}, {0x0742, 0x0742, 220}, {0x0743, 0x0743, 230},
+	{0x0744, 0x0744, 220}, {0x0745, 0x0745, 230}, {0x0746, 0x0746, 220},
+	{0x0747, 0x0747, 230}, {0x0748, 0x0748, 220}, {0x0749, 0x074a, 230},
+	{0x07eb, 0x07f1, 230}, {0x07f2, 0x07f2, 220}, {0x07f3, 0x07f3, 230},
+	{0x07fd, 0x07fd, 220}, {0x0816, 0x0819, 230}, {0x081b, 0x0823, 230},
+	{0x0825, 0x0827, 230}, {0x0829, 0x082d, 230}, {0x0859, 0x085b, 220},
+	{0x0898, 0x0898, 230}, {0x0899, 0x089b, 220}, {0x089c, 0x089f, 230},
+	{0x08ca, 0x08ce, 230}, {0x08cf, 0x08d3, 220}, {0x08d4, 0x08e1, 230},
+	{0x08e3, 0x08e3, 220}, {0x08e4, 0x08e5, 230}, {0x08e6, 0x08e6, 220},
+	{0x08e7, 0x08e8, 230}, {0x08e9, 0x08e9, 220}, {0x08ea, 0x08ec, 230},
+	{0x08ed, 0x08ef, 220}, {0x08f0, 0x08f0, 27}, {0x08f1, 0x08f1, 28},
+	{0x08f2, 0x08f2, 29}, {0x08f3, 0x08f5, 230}, {0x08f6, 0x08f6, 220},
+	{0x08f7, 0x08f8, 230}, {0x08f9, 0x08fa, 220}, {0x08fb, 0x08ff, 230},
+	{0x093c, 0x093c, 7}, {0x094d, 0x094d, 9}, {0x0951, 0x0951, 230},
+	{0x0952, 0x0952, 220}, {0x0953, 0x0954, 230}, {0x09bc, 0x09bc, 7},
+	{0x09cd, 0x09cd, 9}, {0x09fe, 0x09fe, 230}, {0x0a3c, 0x0a3c, 7},
+	{0x0a4d, 0x0a4d, 9}, {0x0abc, 0x0abc, 7}, {0x0acd, 0x0acd, 9},
+	{0x0b3c, 0x0b3c, 7}, {0x0b4d, 0x0b4d, 9}, {0x0bcd, 0x0bcd, 9},
+	{0x0c3c, 0x0c3c, 7}, {0x0c4d, 0x0c4d, 9}, {0x0c55, 0x0c55, 84},
+	{0x0c56, 0x0c56, 91}, {0x0cbc, 0x0cbc, 7}, {0x0ccd, 0x0ccd, 9},
+	{0x0d3b, 0x0d3c, 9}, {0x0d4d, 0x0d4d, 9}, {0x0dca, 0x0dca, 9},
+	{0x0e38, 0x0e39, 103}, {0x0e3a, 0x0e3a, 9}, {0x0e48, 0x0e4b, 107},
+	{0x0eb8, 0x0eb9, 118}, {0x0eba, 0x0eba, 9}, {0x0ec8, 0x0ecb, 122},
+	{0x0f18, 0x0f19, 220}, {0x0f35, 0x0f35, 220}, {0x0f37, 0x0f37, 220},
+	{0x0f39, 0x0f39, 216}, {0x0f71, 0x0f71, 129}, {0x0f72, 0x0f72, 130},
+	{0x0f74, 0x0f74, 132}, {0x0f7a, 0x0f7d, 130}, {0x0f80, 0x0f80, 130},
+	{0x0f82, 0x0f83, 230}, {0x0f84, 0x0f84, 9}, {0x0f86, 0x0f87, 230},
+	{0x0fc6, 0x0fc6, 220}, {0x1037, 0x1037, 7}, {0x1039, 0x103a, 9},
+	{0x108d, 0x108d, 220}, {0x135d, 0x135f, 230}, {0x1714, 0x1715, 9},
+	{0x1734, 0x1734, 9}, {0x17d2, 0x17d2, 9}, {0x17dd, 0x17dd, 230},
+	{0x18a9, 0x18a9, 228}, {0x1939, 0x1939, 222}, {0x193a, 0x193a, 230},
+	{0x193b, 0x193b, 220}, {0x1a17, 0x1a17, 230}, {0x1a18, 0x1a18, 220},
+	{0x1a60, 0x1a60, 9}, {0x1a75, 0x1a7c, 230}, {0x1a7f, 0x1a7f, 220},
+	{0x1ab0, 0x1ab4, 230}, {0x1ab5, 0x1aba, 220}, {0x1abb, 0x1abc, 230},
+	{0x1abd, 0x1abd, 220}, {0x1abf, 0x1ac0, 220}, {0x1ac1, 0x1ac2, 230},
+	{0x1ac3, 0x1ac4, 220}, {0x1ac5, 0x1ac9, 230}, {0x1aca, 0x1aca, 220},
+	{0x1acb, 0x1ace, 230}, {0x1b34, 0x1b34, 7}, {0x1b44, 0x1b44, 9},
+	{0x1b6b, 0x1b6b, 230}, {0x1b6c, 0x1b6c, 220}, {0x1b6d, 0x1b73, 230},
+	{0x1baa, 0x1bab, 9}, {0x1be6, 0x1be6, 7}, {0x1bf2, 0x1bf3, 9},
+	{0x1c37, 0x1c37, 7}, {0x1cd0, 0x1cd2, 230}, {0x1cd4, 0x1cd4, 1},
+	{0x1cd5, 0x1cd9, 220}, {0x1cda, 0x1cdb, 230}, {0x1cdc, 0x1cdf, 220},
+	{0x1ce0, 0x1ce0, 230}, {0x1ce2, 0x1ce8, 1}, {0x1ced, 0x1ced, 220},
+	{0x1cf4, 0x1cf4, 230}, {0x1cf8, 0x1cf9, 230}, {0x1dc0, 0x1dc1, 230},
+	{0x1dc2, 0x1dc2, 220}, {0x1dc3, 0x1dc9, 230}, {0x1dca, 0x1dca, 220},
+	{0x1dcb, 0x1dcc, 230}, {0x1dcd, 0x1dcd, 234}, {0x1dce, 0x1dce, 214},
+	{0x1dcf, 0x1dcf, 220}, {0x1dd0, 0x1dd0, 202}, {0x1dd1, 0x1df5, 230},
+	{0x1df6, 0x1df6, 232}, {0x1df7, 0x1df8, 228}, {0x1df9, 0x1df9, 220},
+	{0x1dfa, 0x1dfa, 218}, {0x1dfb, 0x1dfb, 230}, {0x1dfc, 0x1dfc, 233},
+	{0x1dfd, 0x1dfd, 220}, {0x1dfe, 0x1dfe, 230}, {0x1dff, 0x1dff, 220},
+	{0x20d0, 0x20d1, 230}, {0x20d2, 0x20d3, 1}, {0x20d4, 0x20d7, 230},
+	{0x20d8, 0x20da, 1}, {0x20db, 0x20dc, 230}, {0x20e1, 0x20e1, 230},
+	{0x20e5, 0x20e6, 1}, {0x20e7, 0x20e7, 230}, {0x20e8, 0x20e8, 220},
+	{0x20e9, 0x20e9, 230}, {0x20ea, 0x20eb, 1}, {0x20ec, 0x20ef, 220},
+	{0x20f0, 0x20f0, 230}, {0x2cef, 0x2cf1, 230}, {0x2d7f, 0x2d7f, 9},
+	{0x2de0, 0x2dff, 230}, {0x302a, 0x302a, 218}, {0x302b, 0x302b, 228},
+	{0x302c, 0x302c, 232}, {0x302d, 0x302d, 222}, {0x302e, 0x302f, 224},
+	{0x3099, 0x309a, 8}, {0xa66f, 0xa66f, 230}, {0xa674, 0xa67d, 230},
+	{0xa69e, 0xa69f, 230}, {0xa6f0, 0xa6f1, 230}, {0xa806, 0xa806, 9},
+	{0xa82c, 0xa82c, 9}, {0xa8c4, 0xa8c4, 9}, {0xa8e0, 0xa8f1, 230},
+	{0xa92b, 0xa92d, 220}, {0xa953, 0xa953, 9}, {0xa9b3, 0xa9b3, 7},
+	{0xa9c0, 0xa9c0, 9}, {0xaab0, 0xaab0, 230}, {0xaab2, 0xaab3, 230},
+	{0xaab4, 0xaab4, 220}, {0xaab7, 0xaab8, 230}, {0xaabe, 0xaabf, 230},
+	{0xaac1, 0xaac1, 230}, {0xaaf6, 0xaaf6, 9}, {0xabed, 0xabed, 9},
+	{0xfb1e, 0xfb1e, 26}, {0xfe20, 0xfe26, 230}, {0xfe27, 0xfe2d, 220},
+	{0xfe2e, 0xfe2f, 230}, {0x101fd, 0x101fd, 220}, {0x102e0, 0x102e0, 220},
+	{0x10376, 0x1037a, 230}, {0x10a0d, 0x10a0d, 220}, {0x10a0f, 0x10a0f, 230},
+	{0x10a38, 0x10a38, 230}, {0x10a39, 0x10a39, 1}, {0x10a3a, 0x10a3a, 220},
+	{0x10a3f, 0x10a3f, 9}, {0x10ae5, 0x10ae5, 230}, {0x10ae6, 0x10ae6, 220},
+	{0x10d24, 0x10d27, 230}, {0x10eab, 0x10eac, 230}, {0x10f46, 0x10f47, 220},
+	{0x10f48, 0x10f4a, 230}, {0x10f4b, 0x10f4b, 220}, {0x10f4c, 0x10f4c, 230},
+	{0x10f4d, 0x10f50, 220}, {0x10f82, 0x10f82, 230}, {0x10f83, 0x10f83, 220},
+	{0x10f84, 0x10f84, 230}, {0x10f85, 0x10f85, 220}, {0x11046, 0x11046, 9},
+	{0x11070, 0x11070, 9}, {0x1107f, 0x1107f, 9}, {0x110b9, 0x110b9, 9},
+	{0x110ba, 0x110ba, 7}, {0x11100, 0x11102, 230}, {0x11133, 0x11134, 9},
+	{0x11173, 0x11173, 7}, {0x111c0, 0x111c0, 9}, {0x111ca, 0x111ca, 7},
+	{0x11235, 0x11235, 9}, {0x11236, 0x11236, 7}, {0x112e9, 0x112e9, 7},
+	{0x112ea, 0x112ea, 9}, {0x1133b, 0x1133c, 7}, {0x1134d, 0x1134d, 9},
+	{0x11366, 0x1136c, 230}, {0x11370, 0x11374, 230}, {0x11442, 0x11442, 9},
+	{0x11446, 0x11446, 7}, {0x1145e, 0x1145e, 230}, {0x114c2, 0x114c2, 9},
+	{0x114c3, 0x114c3, 7}, {0x115bf, 0x115bf, 9}, {0x115c0, 0x115c0, 7},
+	{0x1163f, 0x1163f, 9}, {0x116b6, 0x116b6, 9}, {0x116b7, 0x116b7, 7},
+	{0x1172b, 0x1172b, 9}, {0x11839, 0x11839, 9}, {0x1183a, 0x1183a, 7},
+	{0x1193d, 0x1193e, 9}, {0x11943, 0x11943, 7}, {0x119e0, 0x119e0, 9},
+	{0x11a34, 0x11a34, 9}, {0x11a47, 0x11a47, 9}, {0x11a99, 0x11a99, 9},
+	{0x11c3f, 0x11c3f, 9}, {0x11d42, 0x11d42, 7}, {0x11d44, 0x11d45, 9},
+	{0x11d97, 0x11d97, 9}, {0x16af0, 0x16af4, 1}, {0x16b30, 0x16b36, 230},
+	{0x16ff0, 0x16ff1, 6}, {0x1bc9e, 0x1bc9e, 1}, {0x1d165, 0x1d166, 216},
+	{0x1d167, 0x1d169, 1}, {0x1d16d, 0x1d16d, 226}, {0x1d16e, 0x1d172, 216},
+	{0x1d17b, 0x1d182, 220}, {0x1d185, 0x1d189, 230}, {0x1d18a, 0x1d18b, 220},
+	{0x1d1aa, 0x1d1ad, 230}, {0x1d242, 0x1d244, 230}, {0x1e000, 0x1e006, 230},
+	{0x1e008, 0x1e018, 230}, {0x1e01b, 0x1e021, 230}, {0x1e023, 0x1e024, 230},
+	{0x1e026, 0x1e02a, 230}, {0x1e130, 0x1e136, 230}, {0x1e2ae, 0x1e2ae, 230},
+	{0x1e2ec, 0x1e2ef, 230}, {0x1e8d0, 0x1e8d6, 220}, {0x1e944, 0x1e949, 230},
+	{0x1e94a, 0x1e94a, 7},
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultTolerance is one CD frame rounded up.
+const defaultTolerance = (uSecInSecond + cdFramesPerSecond - 1) / cdFramesPerSecond
+
+func doCmdVerifyTimes(arg []string) {
+	var (
+		cueFilePath   string
+		cueAudioFile  int
+		audioFilePath string
+		tolerance     string
+		tol           Timestamp
+		trackFilePath []string
+		track         []trackLength
+		bad           int
+		err           error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&audioFilePath, "audio", "", "cue audio file to verify the last track")
+	addProbeFlags(fl)
+	fl.StringVar(&tolerance, "tol", "", "allowed difference in seconds, one CD frame by default")
+	parseFlags(fl, arg[1:])
+	trackFilePath = fl.Args()
+	if len(trackFilePath) == 0 {
+		panic("No input track(s)")
+	}
+	if cueFilePath == "" {
+		panic("No input cue file")
+	}
+	tol = defaultTolerance
+	if tolerance != "" {
+		tol, err = parseTimeSec(tolerance)
+		if err != nil || tol < 0 {
+			panic("Wrong tolerance: " + tolerance)
+		}
+	}
+
+	f := openInput(cueFilePath)
+	label := parseCue(f, cueAudioFile).label
+	f.Close()
+	if len(label) != len(trackFilePath) {
+		panic(fmt.Sprintf("Cue has %d tracks, but %d files given",
+			len(label), len(trackFilePath)))
+	}
+	end := Timestamp(-1)
+	if audioFilePath != "" {
+		end, err = getMediaDuration(audioFilePath)
+		panicIfError(err)
+	}
+	track = cueTrackLengths(label, end)
+
+	bad = verifyTrackLengths(os.Stdout, track, trackFilePath, tol)
+	if bad > 0 {
+		panic(fmt.Sprintf("%d track(s) mismatch", bad))
+	}
+}
+
+// verifyTrackLengths compares expected track lengths with probed file
+// durations and returns the number of mismatches.
+func verifyTrackLengths(w io.Writer, track []trackLength, trackFilePath []string,
+	tol Timestamp) (bad int) {
+	var js []jsonVerify
+
+	for i, t := range track {
+		var status string
+
+		if t.duration < 0 {
+			if outputFormat == outputJSON {
+				js = append(js, jsonVerify{Track: trackFilePath[i], Status: "SKIP"})
+				continue
+			}
+			_, err := fmt.Fprintf(w, "%02d  SKIP      %v\n", i+1, trackFilePath[i])
+			panicIfError(err)
+			continue
+		}
+		d, err := getMediaDuration(trackFilePath[i])
+		panicIfError(err)
+		diff := d - t.duration
+		if abs(diff) > tol {
+			status = "MISMATCH"
+			bad++
+		} else {
+			status = "OK"
+		}
+		if outputFormat == outputJSON {
+			expected, actual := jsonTime(t.duration), jsonTime(d)
+			js = append(js, jsonVerify{trackFilePath[i], status, &expected, &actual})
+			continue
+		}
+		_, err = fmt.Fprintf(w, "%02d  %-8v  %v  expected %v, actual %v, diff %v\n",
+			i+1, status, trackFilePath[i],
+			formatTimeSec(t.duration), formatTimeSec(d), formatTimeSec(diff))
+		panicIfError(err)
+	}
+	if outputFormat == outputJSON {
+		writeJSON(w, js)
+	}
+	return
+}
+
+// doCmdVerifyMerge checks merged audio file against the tracks it was joined
+// from: its duration is the sum of track durations, and every cue index is
+// inside it.
+func doCmdVerifyMerge(arg []string) {
+	var (
+		cueFilePath    string
+		cueAudioFile   int
+		mergedFilePath string
+		tolerance      string
+		tol            Timestamp
+		sum            Timestamp
+		bad            int
+		js             jsonVerifyMerge
+		err            error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&mergedFilePath, "m", "", "merged audio file")
+	fl.StringVar(&tolerance, "tol", "", "allowed difference in seconds, one CD frame per track by default")
+	addProbeFlags(fl)
+	parseFlags(fl, arg[1:])
+	trackFilePath := fl.Args()
+	if len(trackFilePath) == 0 {
+		panic("No input track(s)")
+	}
+	if mergedFilePath == "" {
+		panic("No merged audio file")
+	}
+	tol = defaultTolerance * Timestamp(len(trackFilePath))
+	if tolerance != "" {
+		tol, err = parseTimeSec(tolerance)
+		if err != nil || tol < 0 {
+			panic("Wrong tolerance: " + tolerance)
+		}
+	}
+
+	f := openInput(cueFilePath)
+	label := parseCue(f, cueAudioFile).label
+	f.Close()
+	for _, path := range trackFilePath {
+		d, err := getMediaDuration(path)
+		panicIfError(err)
+		sum += d
+	}
+	merged, err := getMediaDuration(mergedFilePath)
+	panicIfError(err)
+
+	js.Merged = jsonVerify{Track: mergedFilePath, Status: "OK"}
+	if abs(merged-sum) > tol {
+		js.Merged.Status = "MISMATCH"
+		bad++
+	}
+	expected, actual := jsonTime(sum), jsonTime(merged)
+	js.Merged.Expected, js.Merged.Actual = &expected, &actual
+	for _, l := range label {
+		index := []Timestamp{l.index00, l.start}
+		for i, t := range append(index, l.subindex...) {
+			if t < 0 {
+				continue
+			}
+			x := jsonMergeIndex{Track: l.num, Index: i, Time: jsonTime(t), Status: "OK"}
+			if t >= merged {
+				x.Status = "OUTSIDE"
+				bad++
+			}
+			js.Indexes = append(js.Indexes, x)
+		}
+	}
+
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, js)
+	} else {
+		_, err = fmt.Fprintf(os.Stdout, "    %-8v  %v  expected %v (%d tracks), actual %v, diff %v\n",
+			js.Merged.Status, mergedFilePath, formatTimeSec(sum), len(trackFilePath),
+			formatTimeSec(merged), formatTimeSec(merged-sum))
+		panicIfError(err)
+		for _, x := range js.Indexes {
+			_, err = fmt.Fprintf(os.Stdout, "%02d  %-8v  INDEX %02d %v\n",
+				x.Track, x.Status, x.Index, formatTimeSec(Timestamp(x.Time)))
+			panicIfError(err)
+		}
+	}
+	if bad > 0 {
+		panic(fmt.Sprintf("Merged file does not match tracks: %d problem(s)", bad))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+)
+
+// version is release version, set with -ldflags "-X main.version=v1.2.0".
+// Module version from build info is used otherwise.
+var version string
+
+// optionalTools are external tools some commands need.
+var optionalTools = []string{"ffprobe", "ffmpeg", "sqlite3", "fpcalc"}
+
+type jsonVersion struct {
+	Version  string            `json:"version"`
+	Revision string            `json:"revision,omitempty"`
+	Time     string            `json:"time,omitempty"`
+	Modified bool              `json:"modified,omitempty"`
+	Go       string            `json:"go"`
+	Tools    map[string]string `json:"tools"`
+	Native   []string          `json:"native_formats"`
+}
+
+// doCmdVersion prints version, VCS revision and available features.
+func doCmdVersion(arg []string) {
+	var err error
+
+	if len(arg) > 1 {
+		panic("No arguments expected")
+	}
+	v := jsonVersion{Version: version, Tools: make(map[string]string), Native: nativeFormats}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if v.Version == "" {
+			v.Version = bi.Main.Version
+		}
+		v.Go = bi.GoVersion
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				v.Revision = s.Value
+			case "vcs.time":
+				v.Time = s.Value
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+	if v.Version == "" {
+		v.Version = "(devel)"
+	}
+	for _, t := range optionalTools {
+		v.Tools[t], _ = exec.LookPath(t)
+	}
+
+	if outputFormat == outputJSON {
+		writeJSON(os.Stdout, v)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "cue-maker %v\n", v.Version)
+	if v.Revision != "" {
+		fmt.Fprintf(&b, "revision: %v %v", v.Revision, v.Time)
+		if v.Modified {
+			b.WriteString(" (modified)")
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "go: %v\n", v.Go)
+	for _, t := range optionalTools {
+		fmt.Fprintf(&b, "%v: %v\n", t, cmp.Or(v.Tools[t], "not found"))
+	}
+	fmt.Fprintf(&b, "native probing: %v\n", strings.Join(nativeFormats, " "))
+	_, err = os.Stdout.WriteString(b.String())
+	panicIfError(err)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var vorbisChapterRe = regexp.MustCompile(`(?i)^CHAPTER(\d+)(NAME)?$`)
+
+// doCmdVorbisChapters writes cue tracks as CHAPTERxxx and CHAPTERxxxNAME
+// Vorbis comments, or with -import makes cue from such comments in text
+// file or Ogg/Opus/FLAC file tags.
+func doCmdVorbisChapters(arg []string) {
+	var (
+		cueFilePath  string
+		cueAudioFile int
+		outFilePath  string
+		importPath   string
+		filter       trackFilter
+		cueRd        io.ReadCloser
+		outWr        io.WriteCloser
+		err          error
+	)
+
+	fl := newFlagSet()
+	fl.StringVar(&cueFilePath, "i", "", "input cue file path")
+	fl.IntVar(&cueAudioFile, "a", 0, "input cue audio file index starting at 0")
+	fl.StringVar(&outFilePath, "o", "", "output file path")
+	fl.StringVar(&importPath, "import", "", "make cue from comments file or audio file tags")
+	filter.addFlags(fl)
+	parseFlags(fl, arg[1:])
+	if fl.NArg() != 0 {
+		panic("No arguments expected")
+	}
+
+	outWr = createOutput(outFilePath)
+	defer outWr.Close()
+	if importPath != "" {
+		var (
+			opt   = cueOptions{numStart: defaultNumStart, filePath: filePathBase}
+			tag   map[string]string
+			chap  []chapter
+			title []string
+			start []Timestamp
+		)
+		if isAudioFile(importPath) {
+			tag, err = getVorbisComments(importPath)
+			opt.fileName = importPath
+		} else {
+			f := openInput(importPath)
+			tag, err = readVorbisComments(f)
+			f.Close()
+		}
+		panicIfError(err)
+		if chap, err = parseVorbisChapters(tag); err != nil {
+			panic("Wrong chapters: " + err.Error())
+		}
+		if len(chap) == 0 {
+			panic("No chapters found")
+		}
+		if len(chap) > maxCueTracks {
+			panic(fmt.Sprintf("Cue sheet supports at most %d tracks", maxCueTracks))
+		}
+		for _, c := range chap {
+			start, title = append(start, c.start), append(title, c.title)
+		}
+		opt.title = tag["TITLE"]
+		if opt.title == "" {
+			opt.title = fileTitle(importPath)
+		}
+		writeCue(outWr, &opt, title, start, 0)
+		return
+	}
+
+	cueRd = openInput(cueFilePath)
+	defer cueRd.Close()
+	writeVorbisChapters(outWr, cueRd, cueAudioFile, filter.matcher())
+}
+
+// writeVorbisChapters writes Vorbis chapter comments of matching cue tracks.
+// Chapters are written as the cue is parsed, for thousands of tracks.
+func writeVorbisChapters(outWr io.Writer, cue io.Reader, cueAudioFile int,
+	match func(l cueLabel) bool) {
+	w := bufio.NewWriter(outWr)
+	n := 0
+	scanCue(cue, cueAudioFile, func(_ *cueSheet, l cueLabel) {
+		if !match(l) {
+			return
+		}
+		n++
+		_, err := fmt.Fprintf(w, "CHAPTER%03d=%v\nCHAPTER%03dNAME=%v\n",
+			n, formatVorbisTime(l.start), n, l.title)
+		panicIfError(err)
+	})
+	if n == 0 {
+		panic("No tracks selected")
+	}
+	panicIfError(w.Flush())
+}
+
+func isAudioFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg", ".oga", ".opus", ".flac":
+		return true
+	}
+	return false
+}
+
+// formatVorbisTime formats time as HH:MM:SS.mmm.
+func formatVorbisTime(t Timestamp) string {
+	ms := t.Millis(RoundDown)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// parseVorbisTime parses [[HH:]MM:]SS[.fff] time.
+func parseVorbisTime(s string) (t Timestamp, err error) {
+	var minutes Timestamp
+
+	field := strings.Split(s, ":")
+	if len(field) > 3 {
+		return 0, fmt.Errorf("wrong time '%v'", s)
+	}
+	for _, f := range field[:len(field)-1] {
+		n, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("wrong time '%v'", s)
+		}
+		minutes = minutes*60 + Timestamp(n)
+	}
+	if t, err = parseToolTime(field[len(field)-1]); err != nil || t < 0 {
+		return 0, fmt.Errorf("wrong time '%v'", s)
+	}
+	return t + minutes*60*uSecInSecond, nil
+}
+
+// readVorbisComments reads NAME=value lines. Names are converted to upper
+// case.
+func readVorbisComments(r io.Reader) (tag map[string]string, err error) {
+	tag = make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), "="); ok {
+			tag[strings.ToUpper(strings.TrimSpace(k))] = v
+		}
+	}
+	return tag, sc.Err()
+}
+
+// getVorbisComments returns format and first audio stream tags of file with
+// upper case names, read without ffprobe in native mode.
+func getVorbisComments(filePath string) (tag map[string]string, err error) {
+	var (
+		out []byte
+		js  struct {
+			Streams []struct {
+				Tags map[string]string `json:"tags"`
+			} `json:"streams"`
+			Format struct {
+				Tags map[string]string `json:"tags"`
+			} `json:"format"`
+		}
+	)
+
+	if useNativeProbe() {
+		native, err := getNativeTags(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("get vorbis comments: %w", err)
+		}
+		tag = make(map[string]string)
+		for k, v := range native {
+			tag[strings.ToUpper(k)] = v
+		}
+		return tag, nil
+	}
+	out, err = runCommand("ffprobe",
+		"-hide_banner",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-select_streams", "a:0",
+		"-show_entries", "stream_tags:format_tags",
+		"-i", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("get vorbis comments: ffprobe: %w", err)
+	}
+	if err = json.Unmarshal(out, &js); err != nil {
+		return nil, fmt.Errorf("get vorbis comments: %w", err)
+	}
+	tag = make(map[string]string)
+	for k, v := range js.Format.Tags {
+		tag[strings.ToUpper(k)] = v
+	}
+	for _, s := range js.Streams {
+		for k, v := range s.Tags {
+			tag[strings.ToUpper(k)] = v
+		}
+	}
+	return
+}
+
+// parseVorbisChapters returns chapters from CHAPTERxxx comments ordered by
+// number. Chapters without name get their number as title.
+func parseVorbisChapters(tag map[string]string) (chap []chapter, err error) {
+	var (
+		num   []int
+		start = make(map[int]Timestamp)
+		name  = make(map[int]string)
+	)
+
+	for k, v := range tag {
+		m := vorbisChapterRe.FindStringSubmatch(k)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if m[2] != "" {
+			name[n] = v
+			continue
+		}
+		if start[n], err = parseVorbisTime(strings.TrimSpace(v)); err != nil {
+			return nil, fmt.Errorf("%v: %w", k, err)
+		}
+		num = append(num, n)
+	}
+	slices.Sort(num)
+	for _, n := range num {
+		title := name[n]
+		if title == "" {
+			title = strconv.Itoa(n)
+		}
+		chap = append(chap, chapter{start: start[n], title: title})
+	}
+	return
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func BenchmarkVorbisChapters(b *testing.B) {
+	cue := benchCue(10000)
+	match := (&trackFilter{}).matcher()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(cue)))
+	for range b.N {
+		writeVorbisChapters(io.Discard, bytes.NewReader(cue), 0, match)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a YAML line without indentation and comment.
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+// yamlParser parses the YAML subset of job files.
+type yamlParser struct {
+	line []yamlLine
+	i    int
+}
+
+// parseYAML parses the YAML subset used by job files into values like those
+// encoding/json decodes into any: block mappings and sequences, flow [] and
+// {} collections, quoted and plain scalars, and comments. Plain scalars other
+// than true, false and null stay strings, since options are passed to
+// commands as written: 01 stays 01. Anchors, tags, multi-line scalars and
+// multiple documents are not supported.
+func parseYAML(data []byte) (any, error) {
+	var p yamlParser
+
+	for i, s := range strings.Split(string(data), "\n") {
+		s = strings.TrimRight(stripYAMLComment(strings.TrimRight(s, "\r")), " \t")
+		text := strings.TrimLeft(s, " ")
+		if text == "" || (len(p.line) == 0 && text == "---") {
+			continue
+		}
+		if text == "---" || text == "..." {
+			return nil, fmt.Errorf("line %d: one document expected", i+1)
+		}
+		if text[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tab in indentation", i+1)
+		}
+		p.line = append(p.line, yamlLine{n: i + 1, indent: len(s) - len(text), text: text})
+	}
+	if len(p.line) == 0 {
+		return nil, nil
+	}
+	v, err := p.node(p.line[0].indent)
+	if err == nil && p.i < len(p.line) {
+		err = fmt.Errorf("line %d: wrong indentation", p.line[p.i].n)
+	}
+	return v, err
+}
+
+// stripYAMLComment removes # comment starting the line or following a space
+// outside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// node parses block node of lines at indent.
+func (p *yamlParser) node(indent int) (any, error) {
+	l := p.line[p.i]
+	switch {
+	case isYAMLSeqItem(l.text):
+		return p.sequence(indent)
+	case yamlKeyEnd(l.text) >= 0:
+		return p.mapping(indent)
+	}
+	p.i++
+	v, err := parseYAMLValue(l.text)
+	if err != nil {
+		err = fmt.Errorf("line %d: %w", l.n, err)
+	}
+	return v, err
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	seq := []any{}
+	for p.i < len(p.line) && p.line[p.i].indent == indent && isYAMLSeqItem(p.line[p.i].text) {
+		l := &p.line[p.i]
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.i++
+			if p.i == len(p.line) || p.line[p.i].indent <= indent {
+				seq = append(seq, nil)
+				continue
+			}
+		} else {
+			// the item continues the line as a node indented after "- "
+			l.indent += len(l.text) - len(rest)
+			l.text = rest
+		}
+		v, err := p.node(p.line[p.i].indent)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+	}
+	return seq, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.i < len(p.line) && p.line[p.i].indent == indent {
+		l := p.line[p.i]
+		end := yamlKeyEnd(l.text)
+		if end < 0 || isYAMLSeqItem(l.text) {
+			return nil, fmt.Errorf("line %d: expected key", l.n)
+		}
+		k, err := parseYAMLValue(l.text[:end])
+		key, ok := k.(string)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("line %d: wrong key", l.n)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key '%v'", l.n, key)
+		}
+		p.i++
+		var v any
+		if rest := strings.TrimSpace(l.text[end+1:]); rest != "" {
+			if v, err = parseYAMLValue(rest); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.n, err)
+			}
+		} else if p.i < len(p.line) {
+			// sequence of a key may be as indented as the key
+			if next := p.line[p.i]; next.indent > indent ||
+				(next.indent == indent && isYAMLSeqItem(next.text)) {
+				if v, err = p.node(next.indent); err != nil {
+					return nil, err
+				}
+			}
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func isYAMLSeqItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// yamlKeyEnd returns index of colon ending mapping key of s, or -1.
+func yamlKeyEnd(s string) int {
+	i := 0
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		if i = yamlQuoteEnd(s); i < 0 {
+			return -1
+		}
+	}
+	if s != "" && (s[0] == '[' || s[0] == '{') {
+		return -1
+	}
+	for ; i < len(s); i++ {
+		if s[i] == ':' && (i == len(s)-1 || s[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlQuoteEnd returns index after quoted scalar starting s, or -1.
+func yamlQuoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[0] == '"' && s[i] == '\\':
+			i++
+		case s[i] == s[0] && s[0] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == s[0]:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseYAMLValue parses scalar or flow collection taking all of s.
+func parseYAMLValue(s string) (any, error) {
+	f := yamlFlow{s: s}
+	v, err := f.value(false)
+	if err == nil && strings.TrimSpace(f.s[f.i:]) != "" {
+		err = fmt.Errorf("unexpected '%v'", strings.TrimSpace(f.s[f.i:]))
+	}
+	return v, err
+}
+
+// yamlFlow parses flow value.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+// value parses value, which in flow collection ends at comma or bracket.
+func (f *yamlFlow) value(inFlow bool) (any, error) {
+	f.skipSpace()
+	if f.i == len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.collection(']')
+	case '{':
+		return f.collection('}')
+	case '"', '\'':
+		return f.quoted()
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported '%c'", f.s[f.i])
+	}
+	start := f.i
+	for f.i < len(f.s) && !(inFlow && strings.IndexByte(",]}", f.s[f.i]) >= 0) &&
+		!(inFlow && f.s[f.i] == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ')) {
+		f.i++
+	}
+	switch s := strings.TrimSpace(f.s[start:f.i]); s {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~", "":
+		return nil, nil
+	default:
+		return s, nil
+	}
+}
+
+func (f *yamlFlow) quoted() (any, error) {
+	end := yamlQuoteEnd(f.s[f.i:])
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	s := f.s[f.i : f.i+end]
+	f.i += end
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	v, err := strconv.Unquote(s)
+	if err != nil {
+		return nil, fmt.Errorf("wrong string %v", s)
+	}
+	return v, nil
+}
+
+// collection parses flow sequence or mapping ending at end.
+func (f *yamlFlow) collection(end byte) (any, error) {
+	seq, m := []any{}, map[string]any{}
+	f.i++
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == end {
+			f.i++
+			break
+		}
+		v, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if end == '}' {
+			key, ok := v.(string)
+			if !ok || f.i == len(f.s) || f.s[f.i] != ':' {
+				return nil, fmt.Errorf("expected key")
+			}
+			f.i++
+			if v, err = f.value(true); err != nil {
+				return nil, err
+			}
+			m[key] = v
+			f.skipSpace()
+		} else {
+			seq = append(seq, v)
+		}
+		switch {
+		case f.i == len(f.s):
+			return nil, fmt.Errorf("expected '%c'", end)
+		case f.s[f.i] == ',':
+			f.i++
+		case f.s[f.i] != end:
+			return nil, fmt.Errorf("unexpected '%c'", f.s[f.i])
+		}
+	}
+	if end == '}' {
+		return m, nil
+	}
+	return seq, nil
+}
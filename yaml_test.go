@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want any
+	}{
+		{"", nil},
+		{"a: 1\nb: true\nc:\nd: ~\n", map[string]any{"a": "1", "b": true, "c": nil, "d": nil}},
+		{"# comment\n---\nk: 'it''s' # note\nq: \"a\\tb #c\"\n",
+			map[string]any{"k": "it's", "q": "a\tb #c"}},
+		{"l:\n- a\n-  b\n- \nm: x", map[string]any{"l": []any{"a", "b", nil}, "m": "x"}},
+		{"- k: v\n  n: 01\n- - x\n  - y\n", []any{map[string]any{"k": "v", "n": "01"},
+			[]any{"x", "y"}}},
+		{"o: {i: a.cue, f: [1, \"2\"], e: {}}\n", map[string]any{
+			"o": map[string]any{"i": "a.cue", "f": []any{"1", "2"}, "e": map[string]any{}}}},
+		{"url: http://x/y\n", map[string]any{"url": "http://x/y"}},
+	} {
+		got, err := parseYAML([]byte(c.in))
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseYAML(%q) = %#v, %v, want %#v", c.in, got, err, c.want)
+		}
+	}
+	for _, in := range []string{
+		"a: 1\na: 2", "a:\n  b: 1\n c: 2", "a: [1, 2", "a: \"x", "a: &x 1",
+		"- a\nb: 1", "a: 1\n---\nb: 2", "\ta: 1",
+	} {
+		if v, err := parseYAML([]byte(in)); err == nil {
+			t.Errorf("parseYAML(%q) = %#v, want error", in, v)
+		}
+	}
+}
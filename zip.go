@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	zipExt = ".zip"
+	// zipSep separates zip archive path and member name, like
+	// "cues.zip!disc1.cue".
+	zipSep = "!"
+)
+
+// zipEpoch is modification time of members written with -deterministic, the
+// earliest time zip holds.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// splitZipPath returns archive path and member name of path inside zip
+// archive.
+func splitZipPath(path string) (archive, member string, ok bool) {
+	i := strings.Index(strings.ToLower(path), zipExt+zipSep)
+	if i < 0 {
+		return "", "", false
+	}
+	archive, member = path[:i+len(zipExt)], path[i+len(zipExt)+len(zipSep):]
+	return archive, member, member != ""
+}
+
+// fileDir returns directory of file, or of its zip archive, that relative
+// paths in the file start from.
+func fileDir(path string) string {
+	if archive, _, ok := splitZipPath(path); ok {
+		path = archive
+	}
+	return filepath.Dir(path)
+}
+
+// zipMemberReader closes both archive member and archive.
+type zipMemberReader struct {
+	io.ReadCloser
+	zr   *zip.ReadCloser
+	name string
+}
+
+func (r zipMemberReader) Close() error {
+	r.ReadCloser.Close()
+	return r.zr.Close()
+}
+
+func (r zipMemberReader) Name() string { return r.name }
+
+// openZipMember opens member of zip archive for reading.
+func openZipMember(path string) (io.ReadCloser, error) {
+	archive, member, _ := splitZipPath(path)
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := zr.Open(member)
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return zipMemberReader{f, zr, path}, nil
+}
+
+// zipMemberExists reports whether member of zip archive exists.
+func zipMemberExists(path string) bool {
+	archive, member, _ := splitZipPath(path)
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+	_, err = fs.Stat(zr, member)
+	return err == nil
+}
+
+// zipMemberMode is file mode of written archive members.
+const zipMemberMode = 0644
+
+// writeZipArchives writes members to temporary archives, replacing members
+// of the same name and keeping the others, and returns the archives pending
+// to replace the existing ones.
+func writeZipArchives(out []pendingOutput) (tmp []pendingOutput, err error) {
+	var archives []string
+
+	member := make(map[string][]pendingOutput)
+	for _, p := range out {
+		archive, _, _ := splitZipPath(p.path)
+		if member[archive] == nil {
+			archives = append(archives, archive)
+		}
+		member[archive] = append(member[archive], p)
+	}
+	for _, archive := range archives {
+		f, err := writeZipMembers(archive, member[archive])
+		if f != nil {
+			tmp = append(tmp, pendingOutput{f: f, path: archive})
+		}
+		if err != nil {
+			return tmp, err
+		}
+	}
+	return
+}
+
+// writeZipMembers writes temporary archive of existing archive members and
+// out members. The temporary file keeps mode of existing archive.
+func writeZipMembers(archive string, out []pendingOutput) (f *os.File, err error) {
+	f, err = os.OpenFile(fmt.Sprintf("%v.%d.tmp", archive, os.Getpid()),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if st, err := os.Stat(archive); err == nil {
+		if err = f.Chmod(st.Mode().Perm()); err != nil {
+			return f, err
+		}
+	}
+	zw := zip.NewWriter(f)
+	replaced := make(map[string]bool)
+	for _, p := range out {
+		_, name, _ := splitZipPath(p.path)
+		replaced[name] = true
+	}
+	zr, err := zip.OpenReader(archive)
+	switch {
+	case err == nil:
+		defer zr.Close()
+		for _, m := range zr.File {
+			if !replaced[m.Name] {
+				if err = zw.Copy(m); err != nil {
+					return f, err
+				}
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return f, err
+	}
+	for _, p := range out {
+		if err = addZipMember(zw, p); err != nil {
+			return f, err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return f, err
+	}
+	return f, f.Close()
+}
+
+// addZipMember adds written temporary file to archive.
+func addZipMember(zw *zip.Writer, p pendingOutput) error {
+	_, name, _ := splitZipPath(p.path)
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+	if deterministic {
+		h.Modified = zipEpoch
+	}
+	h.SetMode(zipMemberMode)
+	w, err := zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(p.f.Name())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}